@@ -10,10 +10,24 @@ import (
 
 var memoryUsage = utils.NewMemoryUsage()
 
+// healthAction - the application is healthy while it answers, sources whose results drift from their schema
+// are reported as degraded without failing the check
 func (a *Application) healthAction(w http.ResponseWriter, r *http.Request) {
+	sources := map[string]interface{}{}
+	statuses, err := a.sourceStatuses()
+	if err != nil {
+		panic(err)
+	}
+	for source, status := range statuses {
+		sources[source] = map[string]interface{}{
+			"status":          status.Status,
+			"degraded_fields": status.DegradedFields,
+		}
+	}
 	w.Header().Set("Content-Type", "application/json")
-	err := json.NewEncoder(w).Encode(map[string]string{
-		"status": "OK",
+	err = json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "OK",
+		"sources": sources,
 	})
 	if err != nil {
 		panic(err)
@@ -36,6 +50,7 @@ func (a *Application) statusAction(w http.ResponseWriter, r *http.Request) {
 		"queue-status": a.queue.QueueStatus(),
 		"goroutines":   runtime.NumGoroutine(),
 		"memory-usage": memoryUsage.Get().Alloc,
+		"cache":        a.cache.Stats(),
 	})
 	if err != nil {
 		panic(err)
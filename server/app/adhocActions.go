@@ -0,0 +1,108 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"go-scrape-this/server/app/jobs"
+	"go-scrape-this/server/app/scrape"
+	"go-scrape-this/server/app/scrape/har"
+	"net/http"
+	"time"
+)
+
+const adhocScrapeJobType = "adhoc-scrape"
+
+type adhocScrapeRequest struct {
+	URL          string `json:"url"`
+	WaitSelector string `json:"wait_selector"`
+	Script       string `json:"script"`
+	Timeout      int    `json:"timeout"`
+	Wait         int    `json:"wait"`
+	Har          bool   `json:"har"`
+}
+
+// adhocScrapeRunner - runs the user script without retries, the result is refused when its JSON encoding
+// exceeds the output budget
+func (a *Application) adhocScrapeRunner(adhoc scrape.AdhocScrape, timeout time.Duration, capture bool) jobs.AttachingRunner {
+	return func(logger *zerolog.Logger, _ uuid.UUID, attach jobs.Attach) (map[string]interface{}, error) {
+		logger.Info().Str("url", adhoc.URL).Msg("running ad-hoc scrape")
+		options := scrape.RunOptions{
+			Timeout:      timeout,
+			AllowedHosts: a.adhocHosts,
+		}
+		if capture {
+			options.HAR = har.NewRecorder(a.harOptions)
+		}
+		result, err := scrape.RunAdhoc(adhoc, options)
+		if options.HAR != nil {
+			a.attachHAR(logger, attach, options.HAR, 1)
+		}
+		if err != nil {
+			return nil, err
+		}
+		encoded, err := json.Marshal(result)
+		if err != nil {
+			return nil, err
+		}
+		if len(encoded) > a.adhocMaxOutput {
+			return nil, fmt.Errorf("result of %d bytes exceeds the output budget of %d bytes", len(encoded), a.adhocMaxOutput)
+		}
+		return result, nil
+	}
+}
+
+// adhocScrapeAction - scrapes a page of an allowed host with a script given in the request, the scrape runs
+// through the job queue in a browser of its own
+func (a *Application) adhocScrapeAction(w http.ResponseWriter, r *http.Request) {
+	var request adhocScrapeRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	adhoc, err := scrape.NewAdhocScrape(request.URL, request.WaitSelector, request.Script, a.adhocHosts)
+	if errors.Is(err, scrape.ErrHostNotAllowed) {
+		errorResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if err != nil {
+		errorResponse(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if request.Timeout < 0 {
+		errorResponse(w, http.StatusUnprocessableEntity, "timeout must not be negative")
+		return
+	}
+	timeout := a.adhocTimeout
+	if request.Timeout > 0 && time.Second*time.Duration(request.Timeout) < timeout {
+		timeout = time.Second * time.Duration(request.Timeout)
+	}
+
+	input := adhoc.ToMap()
+	input["timeout"] = int(timeout.Seconds())
+	if user, ok := requestUser(r); ok {
+		input["user"] = user.Username
+	}
+	if request.Har {
+		input["har"] = true
+	}
+	job, err := a.jobs.SubmitAttaching(adhocScrapeJobType, input, a.adhocScrapeRunner(adhoc, timeout, request.Har))
+	if err != nil {
+		submitErrorResponse(w, err)
+		return
+	}
+	if request.Wait > 0 {
+		wait := time.Second * time.Duration(request.Wait)
+		if wait > a.maxLookupWait {
+			wait = a.maxLookupWait
+		}
+		job, _, err = a.jobs.Wait(job.ID, wait)
+		if err != nil {
+			panic(err)
+		}
+	}
+	jobResponse(w, job)
+}
@@ -5,16 +5,29 @@ import (
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"github.com/rs/zerolog"
+	"go-scrape-this/server/app/cache"
 	"go-scrape-this/server/app/database"
 	"go-scrape-this/server/app/database/models"
+	"go-scrape-this/server/app/drift"
+	"go-scrape-this/server/app/jobs"
 	"go-scrape-this/server/app/middleware"
 	"go-scrape-this/server/app/queue"
+	"go-scrape-this/server/app/schedule"
+	"go-scrape-this/server/app/scrape"
+	"go-scrape-this/server/app/scrape/fixture"
+	"go-scrape-this/server/app/scrape/har"
+	"go-scrape-this/server/app/scrape/recipe"
+	"go-scrape-this/server/app/scrape/warc"
+	"go-scrape-this/server/app/search"
+	"go-scrape-this/server/app/storage"
 	"go-scrape-this/server/app/utils"
+	"go-scrape-this/server/app/webhook"
 	goLog "log"
 	"net/http"
 	"os"
 	"runtime"
 	"strconv"
+	"sync"
 	"time"
 )
 
@@ -36,12 +49,51 @@ func (h recoveryHandlerLogger) Println(v ...interface{}) {
 }
 
 type Application struct {
-	logger       *LoggingHandler
-	server       http.Server
-	db           database.Database
-	queue        *queue.Queue
-	version      string
-	shutdownWait time.Duration
+	logger        *LoggingHandler
+	server        http.Server
+	db            database.Database
+	queue         *queue.Queue
+	jobs          *jobs.Tracker
+	cache         *cache.Cache
+	webhooks      *webhook.Dispatcher
+	drift         *drift.Monitor
+	search        *search.Index
+	version       string
+	shutdownWait  time.Duration
+	scrapeTimeout time.Duration
+	maxLookupWait time.Duration
+	scrapeRetry   jobs.RetryPolicy
+	recordDir     string
+	replayArchive *fixture.Archive
+	maxImportSize int64
+	maxImportRows int
+	harOptions    har.Options
+
+	archives       storage.Storage
+	archiveLookups bool
+	archiveOptions warc.Options
+
+	adhocHosts     recipe.HostAllowlist
+	adhocTimeout   time.Duration
+	adhocMaxOutput int
+
+	watchlistTicker      *schedule.Ticker
+	minWatchlistInterval int
+	maxWatchlistChecks   int
+
+	crawlTicker       *schedule.Ticker
+	crawlFrontierLock sync.Mutex
+	maxCrawlInFlight  int
+	maxCrawlPages     int
+	crawlBatchSize    int
+
+	exportTicker    *schedule.Ticker
+	exportDir       string
+	exportRetention time.Duration
+	maxExportRows   int
+
+	stopping context.Context
+	stop     context.CancelFunc
 }
 
 func NewApplication(version string, filesystem http.FileSystem) *Application {
@@ -52,6 +104,48 @@ func NewApplication(version string, filesystem http.FileSystem) *Application {
 	httpAddressEnv := utils.ReadStringEnv("HTTP_ADDR", "0.0.0.0:8080")
 	workerAmountEnv := utils.ReadIntEnv("MAX_QUEUE_WORKERS", runtime.NumCPU())
 	shutdownWaitEnv := utils.ReadIntEnv("SHUTDOWN_WAIT", 60)
+	maxPendingJobsEnv := utils.ReadIntEnv("MAX_PENDING_JOBS", 20000)
+	scrapeTimeoutEnv := utils.ReadIntEnv("SCRAPE_TIMEOUT", 120)
+	scrapeMaxAttemptsEnv := utils.ReadIntEnv("SCRAPE_MAX_ATTEMPTS", 3)
+	scrapeRetryBackoffEnv := utils.ReadIntEnv("SCRAPE_RETRY_BACKOFF", 5)
+	maxLookupWaitEnv := utils.ReadIntEnv("LOOKUP_MAX_WAIT", 10)
+	maxImportSizeEnv := utils.ReadIntEnv("IMPORT_MAX_SIZE", 10485760)
+	maxImportRowsEnv := utils.ReadIntEnv("IMPORT_MAX_ROWS", 10000)
+	cacheTtlEnv := utils.ReadIntEnv("CACHE_TTL", 21600)
+	dmrCacheTtlEnv := utils.ReadIntEnv("CACHE_TTL_DMR", cacheTtlEnv)
+	watchlistCheckIntervalEnv := utils.ReadIntEnv("WATCHLIST_CHECK_INTERVAL", 60)
+	minWatchlistIntervalEnv := utils.ReadIntEnv("WATCHLIST_MIN_INTERVAL", 300)
+	maxWatchlistChecksEnv := utils.ReadIntEnv("WATCHLIST_MAX_CHECKS", 50)
+	webhookWorkersEnv := utils.ReadIntEnv("WEBHOOK_WORKERS", 2)
+	webhookMaxAttemptsEnv := utils.ReadIntEnv("WEBHOOK_MAX_ATTEMPTS", 5)
+	webhookBackoffEnv := utils.ReadIntEnv("WEBHOOK_BACKOFF", 5)
+	webhookTimeoutEnv := utils.ReadIntEnv("WEBHOOK_TIMEOUT", 10)
+	webhookAllowPrivateEnv := utils.ReadBoolEnv("WEBHOOK_ALLOW_PRIVATE", false)
+	recipeDirEnv := utils.ReadStringEnv("RECIPE_DIR", "")
+	profilesFileEnv := utils.ReadStringEnv("BROWSER_PROFILES", "")
+	recordDirEnv := utils.ReadStringEnv("SCRAPE_RECORD_DIR", "")
+	replayFileEnv := utils.ReadStringEnv("SCRAPE_REPLAY", "")
+	driftWindowEnv := utils.ReadIntEnv("SCHEMA_DRIFT_WINDOW", 86400)
+	driftThresholdEnv := utils.ReadIntEnv("SCHEMA_DRIFT_THRESHOLD", 50)
+	driftMinChecksEnv := utils.ReadIntEnv("SCHEMA_DRIFT_MIN_CHECKS", 10)
+	adhocHostsEnv := utils.ReadStringEnv("ADHOC_ALLOWED_HOSTS", "")
+	adhocTimeoutEnv := utils.ReadIntEnv("ADHOC_TIMEOUT", 30)
+	adhocMaxOutputEnv := utils.ReadIntEnv("ADHOC_MAX_OUTPUT", 1048576)
+	crawlCheckIntervalEnv := utils.ReadIntEnv("CRAWL_CHECK_INTERVAL", 5)
+	maxCrawlInFlightEnv := utils.ReadIntEnv("CRAWL_MAX_IN_FLIGHT", 10)
+	maxCrawlPagesEnv := utils.ReadIntEnv("CRAWL_MAX_PAGES", 10000)
+	crawlBatchSizeEnv := utils.ReadIntEnv("CRAWL_BATCH_SIZE", workerAmountEnv)
+	exportDirEnv := utils.ReadStringEnv("EXPORT_DIR", "exports")
+	exportRetentionEnv := utils.ReadIntEnv("EXPORT_RETENTION", 86400)
+	maxExportRowsEnv := utils.ReadIntEnv("EXPORT_MAX_ROWS", 10000)
+	harMaxEntriesEnv := utils.ReadIntEnv("HAR_MAX_ENTRIES", 1000)
+	harMaxBodySizeEnv := utils.ReadIntEnv("HAR_MAX_BODY_SIZE", 262144)
+	harMaxSizeEnv := utils.ReadIntEnv("HAR_MAX_SIZE", 10485760)
+	harRedactHeadersEnv := utils.ReadStringEnv("HAR_REDACT_HEADERS", "")
+	archiveStorageEnv := utils.ReadStringEnv("ARCHIVE_STORAGE", "file")
+	archiveDirEnv := utils.ReadStringEnv("ARCHIVE_DIR", "archives")
+	archiveLookupsEnv := utils.ReadBoolEnv("ARCHIVE_VEHICLE_LOOKUPS", false)
+	archiveMaxSizeEnv := utils.ReadIntEnv("ARCHIVE_MAX_SIZE", 52428800)
 
 	dbType, err := database.ParseDatabaseType(utils.ReadStringEnv("DATABASE_TYPE", database.SQLITE.String()))
 	if err != nil {
@@ -69,6 +163,38 @@ func NewApplication(version string, filesystem http.FileSystem) *Application {
 		dsn = utils.ReadStringEnv("DATABASE_DSN", "scraper.db")
 	}
 
+	if len(recipeDirEnv) > 0 {
+		err = scrape.LoadRecipes(recipeDirEnv)
+		if err != nil {
+			loggingHandler.Default().Fatal().Msgf("failed to load recipes: \"%v\"", err)
+		}
+	}
+	if len(profilesFileEnv) > 0 {
+		err = scrape.LoadProfiles(profilesFileEnv)
+		if err != nil {
+			loggingHandler.Default().Fatal().Msgf("failed to load browser profiles: \"%v\"", err)
+		}
+	}
+	var replayArchive *fixture.Archive
+	if len(replayFileEnv) > 0 {
+		replayArchive, err = fixture.Open(replayFileEnv)
+		if err != nil {
+			loggingHandler.Default().Fatal().Msgf("failed to open replay fixture: \"%v\"", err)
+		}
+		loggingHandler.Default().Warn().Str("file", replayFileEnv).Msg("scrapes are replayed from a fixture")
+	}
+	if len(recordDirEnv) > 0 {
+		err = os.MkdirAll(recordDirEnv, 0755)
+		if err != nil {
+			loggingHandler.Default().Fatal().Msgf("failed to create record directory: \"%v\"", err)
+		}
+	}
+
+	err = os.MkdirAll(exportDirEnv, 0755)
+	if err != nil {
+		loggingHandler.Default().Fatal().Msgf("failed to create export directory: \"%v\"", err)
+	}
+
 	db, err := database.NewDatabase(dbType, dsn, loggingHandler.LoggerFromContext("database"))
 	if err != nil {
 		loggingHandler.Default().Fatal().Msgf("failed to connect to database: \"%v\"", err)
@@ -76,11 +202,50 @@ func NewApplication(version string, filesystem http.FileSystem) *Application {
 
 	shutdownWait := time.Second * time.Duration(shutdownWaitEnv)
 
+	stopping, stop := context.WithCancel(context.Background())
 	a := &Application{
-		version:      version,
-		logger:       loggingHandler,
-		shutdownWait: shutdownWait,
-		db:           db,
+		version:       version,
+		stopping:      stopping,
+		stop:          stop,
+		logger:        loggingHandler,
+		shutdownWait:  shutdownWait,
+		scrapeTimeout: time.Second * time.Duration(scrapeTimeoutEnv),
+		maxLookupWait: time.Second * time.Duration(maxLookupWaitEnv),
+		scrapeRetry: jobs.RetryPolicy{
+			MaxAttempts: scrapeMaxAttemptsEnv,
+			Backoff:     time.Second * time.Duration(scrapeRetryBackoffEnv),
+		},
+		recordDir:     recordDirEnv,
+		replayArchive: replayArchive,
+		maxImportSize: int64(maxImportSizeEnv),
+		maxImportRows: maxImportRowsEnv,
+		harOptions: har.Options{
+			MaxEntries:  harMaxEntriesEnv,
+			MaxBodySize: harMaxBodySizeEnv,
+			MaxSize:     harMaxSizeEnv,
+			Redact:      har.ParseRedactRules(harRedactHeadersEnv),
+			Version:     version,
+		},
+
+		archiveLookups: archiveLookupsEnv,
+		archiveOptions: warc.Options{
+			MaxSize:  archiveMaxSizeEnv,
+			Software: "go-scrape-this/" + version,
+		},
+
+		adhocHosts:     recipe.ParseHostAllowlist(adhocHostsEnv),
+		adhocTimeout:   time.Second * time.Duration(adhocTimeoutEnv),
+		adhocMaxOutput: adhocMaxOutputEnv,
+
+		minWatchlistInterval: minWatchlistIntervalEnv,
+		maxWatchlistChecks:   maxWatchlistChecksEnv,
+		maxCrawlInFlight:     maxCrawlInFlightEnv,
+		maxCrawlPages:        maxCrawlPagesEnv,
+		crawlBatchSize:       crawlBatchSizeEnv,
+		exportDir:            exportDirEnv,
+		exportRetention:      time.Second * time.Duration(exportRetentionEnv),
+		maxExportRows:        maxExportRowsEnv,
+		db:                   db,
 		queue: queue.NewQueue(
 			workerAmountEnv,
 			shutdownWait,
@@ -98,6 +263,41 @@ func NewApplication(version string, filesystem http.FileSystem) *Application {
 			),
 		},
 	}
+	a.jobs = jobs.NewTracker(a.Database(), a.queue, maxPendingJobsEnv)
+	a.archives, err = storage.New(archiveStorageEnv, storage.Config{Dir: archiveDirEnv, DB: a.Database()})
+	if err != nil {
+		loggingHandler.Default().Fatal().Msgf("failed to create archive storage: \"%v\"", err)
+	}
+	a.cache = cache.NewCache(a.Database(), time.Second*time.Duration(cacheTtlEnv), map[string]time.Duration{
+		scrape.DmrSource: time.Second * time.Duration(dmrCacheTtlEnv),
+	})
+	a.webhooks = webhook.NewDispatcher(
+		a.Database(),
+		webhookWorkersEnv,
+		webhookMaxAttemptsEnv,
+		time.Second*time.Duration(webhookBackoffEnv),
+		time.Second*time.Duration(webhookTimeoutEnv),
+		webhookAllowPrivateEnv,
+		loggingHandler.LoggerFromContext("webhook"),
+	)
+	a.drift = drift.NewMonitor(
+		a.Database(),
+		time.Second*time.Duration(driftWindowEnv),
+		float64(driftThresholdEnv)/100,
+		driftMinChecksEnv,
+	)
+	a.drift.OnChange(a.alertSourceDrift)
+	a.search = search.NewIndex(a.Database())
+	a.jobs.OnFinish(a.cacheVehicleLookup)
+	a.jobs.OnFinish(a.checkLookupSchema)
+	a.jobs.OnFinish(a.recordVehicleHistory)
+	a.jobs.OnFinish(a.detectWatchlistChanges)
+	a.jobs.OnFinish(a.recordCrawlPage)
+	a.jobs.OnFinish(a.storeScrapeResult)
+	a.jobs.OnFinish(a.publishJobEvent)
+	a.watchlistTicker = schedule.NewTicker(time.Second*time.Duration(watchlistCheckIntervalEnv), a.checkWatchlists)
+	a.crawlTicker = schedule.NewTicker(time.Second*time.Duration(crawlCheckIntervalEnv), a.checkCrawls)
+	a.exportTicker = schedule.NewTicker(time.Hour, a.pruneExports)
 	a.initHandlers(filesystem)
 	a.initMiddleware()
 	return a
@@ -128,26 +328,62 @@ func (a *Application) Start() {
 	if err != nil {
 		a.DefaultLogger().Fatal().Msgf("failed to run database migrations: %v\n", err)
 	}
+	err = a.search.Setup()
+	if err != nil {
+		a.DefaultLogger().Warn().Err(err).Msg("failed to set up the full-text search index")
+	}
+	interrupted, err := a.jobs.FailInterrupted()
+	if err != nil {
+		a.DefaultLogger().Error().Msgf("failed to fail interrupted jobs: %v\n", err)
+	} else if interrupted > 0 {
+		a.DefaultLogger().Warn().Int64("jobs", interrupted).Msg("marked interrupted jobs as failed")
+	}
+	result := a.Database().Connection().
+		Model(&models.WatchlistVehicle{}).
+		Where("pending_job_id IS NOT NULL").
+		Update("pending_job_id", nil)
+	if result.Error != nil {
+		a.DefaultLogger().Error().Msgf("failed to reset pending watchlist checks: %v\n", result.Error)
+	}
+	result = a.Database().Connection().
+		Model(&models.CrawlURL{}).
+		Where("status = ?", models.CrawlURLProcessing).
+		Update("status", models.CrawlURLQueued)
+	if result.Error != nil {
+		a.DefaultLogger().Error().Msgf("failed to requeue interrupted crawl pages: %v\n", result.Error)
+	}
 	go func() {
 		if err := a.Server().ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			a.DefaultLogger().Fatal().Msgf("failed to start application server: %v\n", err)
 		}
 	}()
+	a.webhooks.Start()
 	a.queue.Start()
+	a.watchlistTicker.Start()
+	a.crawlTicker.Start()
+	a.exportTicker.Start()
 	a.DefaultLogger().Info().Msg("http server started")
 	db := a.Database().Connection()
 	rootUser, err := models.NewUser("root", "root")
 	if err != nil {
 		a.DefaultLogger().Error().Msgf("failed to create root user: %v\n", err)
 	}
+	rootUser.Admin = true
 	var user models.User
-	result := db.Where(models.User{Username: "root"}).Attrs(rootUser).FirstOrCreate(&user)
+	result = db.Where(models.User{Username: "root"}).Attrs(rootUser).FirstOrCreate(&user)
 	if result.Error != nil {
 		a.DefaultLogger().Error().Msgf("failed to create root user: %v\n", result.Error.Error())
 	} else {
 		if result.RowsAffected > 0 {
 			a.DefaultLogger().Info().Interface("User", user).Msg("root user created")
 		}
+		// root users created before users could be admins are promoted
+		if !user.Admin {
+			result = db.Model(&user).Update("admin", true)
+			if result.Error != nil {
+				a.DefaultLogger().Error().Msgf("failed to make root user admin: %v\n", result.Error.Error())
+			}
+		}
 	}
 }
 
@@ -158,7 +394,12 @@ func (a *Application) Stop() {
 	if err != nil {
 		a.DefaultLogger().Fatal().Msgf("http server shutdown threw errors: %v\n", err)
 	}
+	a.watchlistTicker.Stop()
+	a.crawlTicker.Stop()
+	a.exportTicker.Stop()
+	a.stop()
 	a.queue.Stop()
+	a.webhooks.Stop()
 	a.DefaultLogger().Info().Msg("http server stopped")
 }
 
@@ -173,6 +414,60 @@ func (a *Application) initHandlers(filesystem http.FileSystem) {
 
 	r.HandleFunc("/api/users", a.userListAction).Methods("GET")
 
+	r.HandleFunc("/api/jobs/{id}", a.jobAction).Methods("GET")
+	r.HandleFunc("/api/jobs/{id}/diagnostics", a.jobDiagnosticsAction).Methods("GET")
+	r.HandleFunc("/api/jobs/{id}/har", a.jobHARAction).Methods("GET")
+	r.HandleFunc("/api/jobs/{id}/blocking", a.jobBlockingAction).Methods("GET")
+
+	r.HandleFunc("/api/lookups/vehicle", a.vehicleLookupAction).Methods("POST")
+	r.HandleFunc("/api/vehicles/{search_type}/{value}/history", a.vehicleHistoryAction).Methods("GET")
+	r.HandleFunc("/api/vehicles/{plate}/diff", a.vehicleDiffAction).Methods("GET")
+	r.HandleFunc("/api/vehicles/{search_type}/{value}/archives", a.vehicleArchiveListAction).Methods("GET")
+	r.HandleFunc("/api/archives/{id}/download", a.archiveDownloadAction).Methods("GET")
+
+	r.HandleFunc("/api/scrapes/adhoc", a.requireAdmin(a.adhocScrapeAction)).Methods("POST")
+
+	r.HandleFunc("/api/recipes", a.recipeListAction).Methods("GET")
+	r.HandleFunc("/api/recipes/{name}", a.recipeAction).Methods("GET")
+	r.HandleFunc("/api/profiles", a.profileListAction).Methods("GET")
+	r.HandleFunc("/api/sources/{source}/drift", a.sourceDriftAction).Methods("GET")
+	r.HandleFunc("/api/sources/{source}/diff", a.sourceDiffAction).Methods("GET")
+
+	r.HandleFunc("/api/imports/vehicles", a.vehicleImportAction).Methods("POST")
+	r.HandleFunc("/api/imports/{id}", a.importAction).Methods("GET")
+	r.HandleFunc("/api/imports/{id}/result", a.importResultAction).Methods("GET")
+
+	r.HandleFunc("/api/watchlists", a.watchlistListAction).Methods("GET")
+	r.HandleFunc("/api/watchlists", a.watchlistCreateAction).Methods("POST")
+	r.HandleFunc("/api/watchlists/{id}", a.watchlistAction).Methods("GET")
+	r.HandleFunc("/api/watchlists/{id}", a.watchlistDeleteAction).Methods("DELETE")
+	r.HandleFunc("/api/watchlists/{id}/vehicles", a.watchlistVehicleAddAction).Methods("POST")
+	r.HandleFunc("/api/watchlists/{id}/vehicles/{vehicle}", a.watchlistVehicleDeleteAction).Methods("DELETE")
+	r.HandleFunc("/api/watchlists/{id}/changes", a.watchlistChangesAction).Methods("GET")
+	r.HandleFunc("/api/watchlists/{id}/vehicles/{vehicle}/changes", a.watchlistChangesAction).Methods("GET")
+
+	r.HandleFunc("/api/crawls", a.crawlListAction).Methods("GET")
+	r.HandleFunc("/api/crawls", a.requireAdmin(a.crawlCreateAction)).Methods("POST")
+	r.HandleFunc("/api/crawls/{id}", a.crawlAction).Methods("GET")
+	r.HandleFunc("/api/crawls/{id}/pause", a.requireAdmin(a.crawlPauseAction)).Methods("POST")
+	r.HandleFunc("/api/crawls/{id}/resume", a.requireAdmin(a.crawlResumeAction)).Methods("POST")
+	r.HandleFunc("/api/crawls/{id}/urls", a.crawlURLListAction).Methods("GET")
+	r.HandleFunc("/api/crawls/{id}/sources", a.requireAdmin(a.crawlSourceAction)).Methods("POST")
+
+	r.HandleFunc("/api/results", a.resultSearchAction).Methods("GET")
+	r.HandleFunc("/api/results/{id}", a.resultAction).Methods("GET")
+
+	r.HandleFunc("/api/exports", a.exportCreateAction).Methods("POST")
+	r.HandleFunc("/api/exports/results", a.exportResultsAction).Methods("GET")
+	r.HandleFunc("/api/exports/{id}/download", a.exportDownloadAction).Methods("GET")
+
+	r.HandleFunc("/api/webhooks", a.webhookListAction).Methods("GET")
+	r.HandleFunc("/api/webhooks", a.requireAdmin(a.webhookCreateAction)).Methods("POST")
+	r.HandleFunc("/api/webhooks/{id}", a.webhookAction).Methods("GET")
+	r.HandleFunc("/api/webhooks/{id}", a.requireAdmin(a.webhookDeleteAction)).Methods("DELETE")
+	r.HandleFunc("/api/webhooks/{id}/deliveries", a.webhookDeliveriesAction).Methods("GET")
+	r.HandleFunc("/api/webhooks/{id}/test", a.requireAdmin(a.webhookTestAction)).Methods("POST")
+
 	r.PathPrefix("/").Handler(middleware.StaticFileHandler{
 		Filesystem: filesystem,
 	})
@@ -182,7 +477,9 @@ func (a *Application) initHandlers(filesystem http.FileSystem) {
 func (a *Application) initMiddleware() {
 	h := a.Server().Handler
 
-	h = handlers.ContentTypeHandler(h, allowedContentTypes...)
+	h = middleware.ContentTypeHandler(h, allowedContentTypes, map[string][]string{
+		"/api/imports/vehicles": importContentTypes,
+	})
 
 	if utils.ReadBoolEnv("BEHIND_REVERSE_PROXY", false) {
 		h = handlers.ProxyHeaders(h)
@@ -0,0 +1,186 @@
+package app
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+	"go-scrape-this/server/app/database/models"
+	"go-scrape-this/server/app/scrape"
+	"go-scrape-this/server/app/scrape/warc"
+	"go-scrape-this/server/app/storage"
+	"go-scrape-this/server/app/utils"
+	"gorm.io/gorm"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+)
+
+const warcContentType = "application/warc"
+
+// archiveVehicleLookup - stores the exchanges of a lookup attempt as a WARC file through the storage backend,
+// failing to do so does not fail the lookup
+func (a *Application) archiveVehicleLookup(logger *zerolog.Logger, jobID uuid.UUID, query scrape.VehicleQuery, archiver *warc.Archiver, result map[string]interface{}, attempt int) {
+	capturedAt := time.Now().UTC()
+	replayed := a.replayArchive != nil
+	info := warc.Info{
+		JobID: jobID.String(),
+		Fields: []warc.Header{
+			{Name: "recipe", Value: scrape.DmrVehicleRecipe},
+			{Name: "search-type", Value: query.SearchType.String()},
+			{Name: "value", Value: query.Value},
+		},
+		Metadata: map[string]interface{}{
+			"job_id":      jobID.String(),
+			"source":      scrape.DmrSource,
+			"query":       query.ToMap(),
+			"attempt":     attempt,
+			"captured_at": capturedAt,
+			"result":      result,
+		},
+	}
+	if replayed {
+		// the responses come from the fixture, not from the source
+		info.Fields = append(info.Fields, warc.Header{Name: "replayed", Value: "true"})
+		info.Metadata["replayed"] = true
+	}
+	var data bytes.Buffer
+	records, err := archiver.Write(&data, info)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to write WARC archive")
+		return
+	}
+	key := fmt.Sprintf(
+		"%s/%s/%s/%s-%s-%d.warc.gz",
+		scrape.DmrSource,
+		query.SearchType.String(),
+		query.Value,
+		capturedAt.Format("20060102T150405Z"),
+		jobID.String(),
+		attempt,
+	)
+	err = a.archives.Put(key, data.Bytes())
+	if err != nil {
+		logger.Error().Err(err).Str("key", key).Msg("failed to store WARC archive")
+		return
+	}
+	sum := sha256.Sum256(data.Bytes())
+	stored := models.Archive{
+		JobID:      jobID,
+		Source:     scrape.DmrSource,
+		SearchType: query.SearchType.String(),
+		Value:      query.Value,
+		Vin:        scrape.VehicleVin(result),
+		Storage:    a.archives.Name(),
+		StorageKey: key,
+		Size:       int64(data.Len()),
+		Records:    records,
+		Replayed:   replayed,
+		Sha256:     hex.EncodeToString(sum[:]),
+		CapturedAt: capturedAt,
+	}
+	err = a.Database().Connection().Create(&stored).Error
+	if err != nil {
+		logger.Error().Err(err).Str("key", key).Msg("failed to record WARC archive")
+		return
+	}
+	logger.Info().Str("key", key).Int("records", records).Msg("stored WARC archive")
+}
+
+// vehicleArchiveListAction - lists the archives of the lookups of a vehicle, newest first, optionally captured
+// between "from" and "to"
+func (a *Application) vehicleArchiveListAction(w http.ResponseWriter, r *http.Request) {
+	searchType, err := scrape.ParseSearchType(mux.Vars(r)["search_type"])
+	if err != nil {
+		errorResponse(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	value, err := searchType.Normalize(mux.Vars(r)["value"])
+	if err != nil {
+		errorResponse(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	from, err := parseExportTime(r.URL.Query().Get("from"), false)
+	if err != nil {
+		errorResponse(w, http.StatusUnprocessableEntity, "invalid from: "+err.Error())
+		return
+	}
+	to, err := parseExportTime(r.URL.Query().Get("to"), true)
+	if err != nil {
+		errorResponse(w, http.StatusUnprocessableEntity, "invalid to: "+err.Error())
+		return
+	}
+	limit := utils.GetQueryIntOption(r, "limit", 50)
+	offset := utils.GetQueryIntOption(r, "offset", 0)
+	if limit > 500 {
+		limit = 500
+	}
+	vehicle := a.Database().Connection().Where("search_type = ? AND value = ?", searchType.String(), value)
+	if searchType == scrape.VIN {
+		// archives of lookups by other search types are found by the VIN they were scraped with
+		vehicle = vehicle.Or("vin = ?", value)
+	}
+	query := a.Database().Connection().Model(&models.Archive{}).Where("source = ?", scrape.DmrSource).Where(vehicle)
+	if from != nil {
+		query = query.Where("captured_at >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("captured_at <= ?", *to)
+	}
+	var archives []models.Archive
+	var count int64
+	result := query.Count(&count)
+	if result.Error != nil {
+		panic(result.Error)
+	}
+	result = query.Order("captured_at DESC").Order("id DESC").Limit(limit).Offset(offset).Find(&archives)
+	if result.Error != nil {
+		panic(result.Error)
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"data":   archives,
+		"total":  count,
+		"count":  len(archives),
+		"offset": offset,
+		"limit":  limit,
+	})
+}
+
+// archiveDownloadAction - downloads the WARC file of an archive
+func (a *Application) archiveDownloadAction(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "invalid archive id")
+		return
+	}
+	var archive models.Archive
+	result := a.Database().Connection().First(&archive, id)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		errorResponse(w, http.StatusNotFound, "archive not found")
+		return
+	}
+	if result.Error != nil {
+		panic(result.Error)
+	}
+	if archive.Storage != a.archives.Name() {
+		errorResponse(w, http.StatusGone, "archive is kept by the \""+archive.Storage+"\" storage backend")
+		return
+	}
+	file, err := a.archives.Open(archive.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		errorResponse(w, http.StatusGone, "archive file is missing")
+		return
+	}
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+	w.Header().Set("Content-Type", warcContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", path.Base(archive.StorageKey)))
+	http.ServeContent(w, r, "", archive.CapturedAt, file)
+}
@@ -0,0 +1,58 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"go-scrape-this/server/app/database/models"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+type contextKey string
+
+const userContextKey contextKey = "user"
+
+// requireAdmin - only lets requests authenticated as an admin user through, the credentials are given with
+// basic auth and the user is available to the handler with requestUser
+func (a *Application) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := a.authenticate(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="go-scrape-this", charset="UTF-8"`)
+			errorResponse(w, http.StatusUnauthorized, "authentication required")
+			return
+		}
+		if !user.Admin {
+			errorResponse(w, http.StatusForbidden, "admin permission required")
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), userContextKey, user)))
+	}
+}
+
+// authenticate - finds the user of the basic auth credentials of the request
+func (a *Application) authenticate(r *http.Request) (models.User, bool) {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return models.User{}, false
+	}
+	var user models.User
+	result := a.Database().Connection().Where(models.User{Username: username}).First(&user)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return models.User{}, false
+	}
+	if result.Error != nil {
+		panic(result.Error)
+	}
+	valid, err := user.Password.ComparePlainText(password)
+	if err != nil || !valid {
+		return models.User{}, false
+	}
+	return user, true
+}
+
+// requestUser - the user the request was authenticated as
+func requestUser(r *http.Request) (models.User, bool) {
+	user, ok := r.Context().Value(userContextKey).(models.User)
+	return user, ok
+}
@@ -0,0 +1,72 @@
+package app
+
+import (
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"go-scrape-this/server/app/database/models"
+	"go-scrape-this/server/app/scrape/recipe"
+	"net/http"
+)
+
+// recordBlocking - stores the requests blocked by an attempt of a job, attempts blocking nothing are not stored
+// and failing to store them does not fail the scrape
+func (a *Application) recordBlocking(logger *zerolog.Logger, jobID uuid.UUID, recipeName string, counter *recipe.BlockCounter, attempt int) {
+	stats := counter.Stats()
+	if stats.Requests == 0 {
+		return
+	}
+	resourceTypes := map[string]interface{}{}
+	for resourceType, count := range stats.ResourceTypes {
+		resourceTypes[resourceType] = count
+	}
+	blocking := models.ResourceBlocking{
+		JobID:         jobID,
+		Recipe:        recipeName,
+		Attempt:       attempt,
+		Requests:      stats.Requests,
+		ResourceTypes: resourceTypes,
+		BytesSaved:    stats.BytesSaved,
+		TimeSavedMs:   stats.TimeSavedMs,
+		Unmeasured:    stats.Unmeasured,
+	}
+	err := a.Database().Connection().Create(&blocking).Error
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to record blocked requests")
+		return
+	}
+	logger.Info().
+		Int("requests", stats.Requests).
+		Int64("bytes_saved", stats.BytesSaved).
+		Int64("time_saved_ms", stats.TimeSavedMs).
+		Int("unmeasured", stats.Unmeasured).
+		Msg("blocked requests")
+}
+
+// jobBlockingAction - lists the requests blocked by each attempt of a job, along with the totals of the job
+func (a *Application) jobBlockingAction(w http.ResponseWriter, r *http.Request) {
+	job, ok := a.findJob(w, r)
+	if !ok {
+		return
+	}
+	var attempts []models.ResourceBlocking
+	result := a.Database().Connection().Where("job_id = ?", job.ID.String()).Order("attempt").Order("id").Find(&attempts)
+	if result.Error != nil {
+		panic(result.Error)
+	}
+	total := recipe.BlockStats{ResourceTypes: map[string]int{}}
+	for _, attempt := range attempts {
+		total.Requests += attempt.Requests
+		total.BytesSaved += attempt.BytesSaved
+		total.TimeSavedMs += attempt.TimeSavedMs
+		total.Unmeasured += attempt.Unmeasured
+		for resourceType, count := range attempt.ResourceTypes {
+			if value, ok := count.(float64); ok {
+				total.ResourceTypes[resourceType] += int(value)
+			}
+		}
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"data":   attempts,
+		"totals": total,
+	})
+}
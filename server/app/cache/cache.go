@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"go-scrape-this/server/app/database"
+	"go-scrape-this/server/app/database/models"
+	"gorm.io/gorm"
+	"sync"
+	"time"
+)
+
+const (
+	HIT    = "HIT"
+	MISS   = "MISS"
+	STALE  = "STALE"
+	BYPASS = "BYPASS"
+)
+
+type SourceStats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Stale     int64 `json:"stale"`
+	Bypassed  int64 `json:"bypassed"`
+	Refreshes int64 `json:"refreshes"`
+}
+
+// Result - the outcome of a cache lookup, the entry is only set for hits and stale hits
+type Result struct {
+	Status string
+	Entry  models.CacheEntry
+	Age    time.Duration
+}
+
+// Cache - keeps track of the latest successful job for a key of a source
+type Cache struct {
+	db         *database.Database
+	ttl        map[string]time.Duration
+	defaultTtl time.Duration
+	lock       sync.Mutex
+	stats      map[string]*SourceStats
+	refreshing map[string]bool
+}
+
+// NewCache - creates a new cache, sources without a ttl use the default ttl
+func NewCache(db *database.Database, defaultTtl time.Duration, ttl map[string]time.Duration) *Cache {
+	return &Cache{
+		db:         db,
+		ttl:        ttl,
+		defaultTtl: defaultTtl,
+		stats:      map[string]*SourceStats{},
+		refreshing: map[string]bool{},
+	}
+}
+
+func (c *Cache) TTL(source string) time.Duration {
+	ttl, found := c.ttl[source]
+	if !found {
+		return c.defaultTtl
+	}
+	return ttl
+}
+
+// Lookup - finds the entry for the key and decides whether it may be used with the given directives
+func (c *Cache) Lookup(source string, key string, directives Directives) (Result, error) {
+	if directives.NoCache {
+		c.count(source, BYPASS)
+		return Result{Status: BYPASS}, nil
+	}
+	var entry models.CacheEntry
+	result := c.db.Connection().Where(models.CacheEntry{Source: source, CacheKey: key}).First(&entry)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		c.count(source, MISS)
+		return Result{Status: MISS}, nil
+	}
+	if result.Error != nil {
+		return Result{}, result.Error
+	}
+
+	maxAge := c.TTL(source)
+	if directives.MaxAge != nil && *directives.MaxAge < maxAge {
+		maxAge = *directives.MaxAge
+	}
+	age := time.Since(entry.FetchedAt)
+	if age <= maxAge {
+		c.count(source, HIT)
+		return Result{Status: HIT, Entry: entry, Age: age}, nil
+	}
+	if directives.StaleWhileRevalidate != nil && age <= maxAge+*directives.StaleWhileRevalidate {
+		c.count(source, STALE)
+		return Result{Status: STALE, Entry: entry, Age: age}, nil
+	}
+	c.count(source, MISS)
+	return Result{Status: MISS}, nil
+}
+
+// Store - makes the job the cached answer for the key
+func (c *Cache) Store(source string, key string, jobId uuid.UUID, fetchedAt time.Time) error {
+	var entry models.CacheEntry
+	return c.db.Connection().
+		Where(models.CacheEntry{Source: source, CacheKey: key}).
+		Assign(models.CacheEntry{JobID: jobId, FetchedAt: fetchedAt}).
+		FirstOrCreate(&entry).Error
+}
+
+// BeginRefresh - marks the key as being refreshed, returns false if a refresh is already in progress
+func (c *Cache) BeginRefresh(source string, key string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.refreshing[source+"\x00"+key] {
+		return false
+	}
+	c.refreshing[source+"\x00"+key] = true
+	c.sourceStats(source).Refreshes++
+	return true
+}
+
+// EndRefresh - marks the refresh of the key as done
+func (c *Cache) EndRefresh(source string, key string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.refreshing, source+"\x00"+key)
+}
+
+// Stats - returns a copy of the statistics of every source
+func (c *Cache) Stats() map[string]SourceStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	output := map[string]SourceStats{}
+	for source, stats := range c.stats {
+		output[source] = *stats
+	}
+	return output
+}
+
+func (c *Cache) count(source string, status string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stats := c.sourceStats(source)
+	switch status {
+	case HIT:
+		stats.Hits++
+	case MISS:
+		stats.Misses++
+	case STALE:
+		stats.Stale++
+	case BYPASS:
+		stats.Bypassed++
+	}
+}
+
+func (c *Cache) sourceStats(source string) *SourceStats {
+	stats, found := c.stats[source]
+	if !found {
+		stats = &SourceStats{}
+		c.stats[source] = stats
+	}
+	return stats
+}
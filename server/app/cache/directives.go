@@ -0,0 +1,41 @@
+package cache
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Directives - the cache directives of a request, modelled after the Cache-Control header
+type Directives struct {
+	NoCache              bool
+	MaxAge               *time.Duration
+	StaleWhileRevalidate *time.Duration
+}
+
+// ParseDirectives - parses a Cache-Control header value, unknown or malformed directives are ignored
+func ParseDirectives(header string) Directives {
+	output := Directives{}
+	for _, part := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "no-cache", "no-store":
+			output.NoCache = true
+		case "max-age":
+			output.MaxAge = parseSeconds(value)
+		case "stale-while-revalidate":
+			output.StaleWhileRevalidate = parseSeconds(value)
+		}
+	}
+	return output
+}
+
+func parseSeconds(value string) *time.Duration {
+	seconds, err := strconv.Atoi(strings.Trim(strings.TrimSpace(value), "\""))
+	if err != nil || seconds < 0 {
+		return nil
+	}
+	duration := time.Second * time.Duration(seconds)
+	return &duration
+}
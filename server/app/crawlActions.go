@@ -0,0 +1,233 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go-scrape-this/server/app/crawler"
+	"go-scrape-this/server/app/database/models"
+	"go-scrape-this/server/app/scrape"
+	"go-scrape-this/server/app/scrape/recipe"
+	"go-scrape-this/server/app/utils"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+)
+
+type crawlRequest struct {
+	Name     string                 `json:"name"`
+	Seeds    []string               `json:"seeds"`
+	Include  []string               `json:"include"`
+	Exclude  []string               `json:"exclude"`
+	MaxDepth *int                   `json:"max_depth"`
+	MaxPages int                    `json:"max_pages"`
+	Engine   string                 `json:"engine"`
+	Links    string                 `json:"links"`
+	Fields   map[string]recipe.Rule `json:"fields"`
+}
+
+func (a *Application) crawlListAction(w http.ResponseWriter, r *http.Request) {
+	limit := utils.GetQueryIntOption(r, "limit", 10)
+	offset := utils.GetQueryIntOption(r, "offset", 0)
+	if limit > 100 {
+		limit = 100
+	}
+	db := a.Database().Connection()
+	var crawls []models.Crawl
+	var count int64
+	db.Model(models.Crawl{}).Count(&count)
+	db.Order("created_at").Limit(limit).Offset(offset).Find(&crawls)
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"data":   crawls,
+		"total":  count,
+		"count":  len(crawls),
+		"offset": offset,
+		"limit":  limit,
+	})
+}
+
+// crawlCreateAction - starts a crawl from seeds of allowed hosts, the pages are crawled with the same host
+// allowlist as ad-hoc scrapes
+func (a *Application) crawlCreateAction(w http.ResponseWriter, r *http.Request) {
+	var request crawlRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if len(request.Name) == 0 || len(request.Name) > 255 {
+		errorResponse(w, http.StatusUnprocessableEntity, "name must be between 1 and 255 characters")
+		return
+	}
+	if len(request.Seeds) == 0 {
+		errorResponse(w, http.StatusUnprocessableEntity, "at least one seed url is required")
+		return
+	}
+	seeds := []string{}
+	for _, seed := range request.Seeds {
+		normalized, err := crawler.Normalize(seed)
+		if err != nil {
+			errorResponse(w, http.StatusUnprocessableEntity, "invalid seed url \""+seed+"\": "+err.Error())
+			return
+		}
+		if !a.adhocHosts.AllowsURL(normalized) {
+			errorResponse(w, http.StatusForbidden, "host of seed url \""+seed+"\" is not allowed")
+			return
+		}
+		seeds = append(seeds, normalized)
+	}
+	if _, err := crawler.NewScope(seeds, request.Include, request.Exclude); err != nil {
+		errorResponse(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	maxDepth := 2
+	if request.MaxDepth != nil {
+		maxDepth = *request.MaxDepth
+	}
+	if maxDepth < 0 {
+		errorResponse(w, http.StatusUnprocessableEntity, "max depth must not be negative")
+		return
+	}
+	if request.MaxPages == 0 {
+		request.MaxPages = 100
+	}
+	if request.MaxPages < 0 || request.MaxPages > a.maxCrawlPages {
+		errorResponse(w, http.StatusUnprocessableEntity, "max pages must be between 1 and "+strconv.Itoa(a.maxCrawlPages))
+		return
+	}
+	if len(request.Engine) == 0 {
+		request.Engine = recipe.STATIC.String()
+	}
+	page := scrape.CrawlPage{
+		Engine: request.Engine,
+		Links:  request.Links,
+		Fields: request.Fields,
+	}
+	if err := page.Validate(); err != nil {
+		errorResponse(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	fields := map[string]interface{}{}
+	encoded, err := json.Marshal(request.Fields)
+	if err == nil {
+		err = json.Unmarshal(encoded, &fields)
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	crawl := models.NewCrawl(
+		request.Name,
+		seeds,
+		request.Include,
+		request.Exclude,
+		maxDepth,
+		request.MaxPages,
+		request.Engine,
+		request.Links,
+		fields,
+	)
+	result := a.Database().Connection().Create(&crawl)
+	if result.Error != nil {
+		panic(result.Error)
+	}
+	_, err = a.enqueueCrawlURLs(crawl, seeds, 0)
+	if err != nil {
+		panic(err)
+	}
+	w.Header().Set("Location", "/api/crawls/"+crawl.ID.String())
+	jsonResponse(w, http.StatusCreated, crawl)
+}
+
+func (a *Application) crawlAction(w http.ResponseWriter, r *http.Request) {
+	crawl, found := a.findCrawl(w, r)
+	if !found {
+		return
+	}
+	counts, err := a.crawlStatusCounts(crawl.ID)
+	if err != nil {
+		panic(err)
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"crawl": crawl,
+		"urls":  counts,
+	})
+}
+
+// crawlPauseAction - stops submitting the queued urls of the crawl, pages already in flight are still recorded
+func (a *Application) crawlPauseAction(w http.ResponseWriter, r *http.Request) {
+	a.setCrawlStatus(w, r, models.CrawlRunning, models.CrawlPaused)
+}
+
+func (a *Application) crawlResumeAction(w http.ResponseWriter, r *http.Request) {
+	a.setCrawlStatus(w, r, models.CrawlPaused, models.CrawlRunning)
+}
+
+func (a *Application) setCrawlStatus(w http.ResponseWriter, r *http.Request, from string, to string) {
+	crawl, found := a.findCrawl(w, r)
+	if !found {
+		return
+	}
+	if crawl.Status != from {
+		errorResponse(w, http.StatusConflict, "crawl is "+crawl.Status)
+		return
+	}
+	result := a.Database().Connection().
+		Model(&crawl).
+		Where("status = ?", from).
+		Update("status", to)
+	if result.Error != nil {
+		panic(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		errorResponse(w, http.StatusConflict, "crawl status changed concurrently")
+		return
+	}
+	jsonResponse(w, http.StatusOK, crawl)
+}
+
+func (a *Application) crawlURLListAction(w http.ResponseWriter, r *http.Request) {
+	crawl, found := a.findCrawl(w, r)
+	if !found {
+		return
+	}
+	limit := utils.GetQueryIntOption(r, "limit", 50)
+	offset := utils.GetQueryIntOption(r, "offset", 0)
+	if limit > 500 {
+		limit = 500
+	}
+	query := a.Database().Connection().Model(&models.CrawlURL{}).Where("crawl_id = ?", crawl.ID.String())
+	if status := r.URL.Query().Get("status"); len(status) > 0 {
+		query = query.Where("status = ?", status)
+	}
+	var urls []models.CrawlURL
+	var count int64
+	query.Count(&count)
+	query.Order("id").Limit(limit).Offset(offset).Find(&urls)
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"data":   urls,
+		"total":  count,
+		"count":  len(urls),
+		"offset": offset,
+		"limit":  limit,
+	})
+}
+
+func (a *Application) findCrawl(w http.ResponseWriter, r *http.Request) (models.Crawl, bool) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "invalid crawl id")
+		return models.Crawl{}, false
+	}
+	var crawl models.Crawl
+	result := a.Database().Connection().First(&crawl, "id = ?", id.String())
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		errorResponse(w, http.StatusNotFound, "crawl not found")
+		return models.Crawl{}, false
+	}
+	if result.Error != nil {
+		panic(result.Error)
+	}
+	return crawl, true
+}
@@ -0,0 +1,222 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/rs/zerolog"
+	"go-scrape-this/server/app/crawler"
+	"go-scrape-this/server/app/database/models"
+	"go-scrape-this/server/app/jobs"
+	"net/http"
+	"strings"
+)
+
+const crawlIngestJobType = "crawl-ingest"
+
+type crawlSourceRequest struct {
+	URL     string `json:"url"`
+	Content string `json:"content"`
+}
+
+// crawlIngestCounts - what happened to the urls of an ingested source
+type crawlIngestCounts struct {
+	Listed     int `json:"listed"`
+	Added      int `json:"added"`
+	Requeued   int `json:"requeued"`
+	Unchanged  int `json:"unchanged"`
+	Pending    int `json:"pending"`
+	Invalid    int `json:"invalid"`
+	TooLong    int `json:"too_long"`
+	OutOfScope int `json:"out_of_scope"`
+	OverBudget int `json:"over_budget"`
+}
+
+func (c crawlIngestCounts) toMap() map[string]interface{} {
+	output := map[string]interface{}{}
+	encoded, _ := json.Marshal(c)
+	_ = json.Unmarshal(encoded, &output)
+	return output
+}
+
+// crawlIngestRunner - fetches the source from the url, or the sitemaps the parsed source refers to, and adds
+// its urls to the frontier of the crawl
+func (a *Application) crawlIngestRunner(crawl models.Crawl, url string, source crawler.Source) jobs.Runner {
+	return func(logger *zerolog.Logger) (map[string]interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), a.scrapeTimeout)
+		defer cancel()
+		client := &http.Client{Transport: a.adhocHosts.Transport(http.DefaultTransport)}
+		var err error
+		if len(url) > 0 {
+			logger.Info().Str("url", url).Msg("fetching crawl source")
+			source, err = crawler.FetchSource(ctx, client, url)
+		} else if len(source.Sitemaps) > 0 {
+			source, err = crawler.FetchSitemaps(ctx, client, source)
+		}
+		if err != nil {
+			return nil, err
+		}
+		// the crawl may have been paused or finished while the source was fetched
+		result := a.Database().Connection().First(&crawl, "id = ?", crawl.ID.String())
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		counts, err := a.ingestCrawlEntries(crawl, source.Entries)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info().Interface("counts", counts).Str("format", source.Format).Msg("ingested crawl source")
+		output := counts.toMap()
+		output["format"] = source.Format
+		return output, nil
+	}
+}
+
+// ingestCrawlEntries - adds the listed urls that are in scope to the frontier of the crawl as seeds. Urls that
+// were crawled before are queued again unless the source tells they were not modified since they were last
+// ingested, urls that failed are always queued again and urls waiting to be crawled are left alone. A finished crawl is resumed when urls were queued.
+func (a *Application) ingestCrawlEntries(crawl models.Crawl, entries []crawler.Entry) (crawlIngestCounts, error) {
+	counts := crawlIngestCounts{Listed: len(entries)}
+	scope, err := crawler.NewScope(crawl.Seeds, crawl.Include, crawl.Exclude)
+	if err != nil {
+		return counts, err
+	}
+	candidates := []models.CrawlURL{}
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		normalized, err := crawler.Normalize(entry.URL)
+		if errors.Is(err, crawler.ErrURLTooLong) {
+			counts.TooLong++
+			continue
+		}
+		if err != nil {
+			counts.Invalid++
+			continue
+		}
+		if !scope.Allows(normalized) {
+			counts.OutOfScope++
+			continue
+		}
+		key := crawler.Key(normalized)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		candidates = append(candidates, models.CrawlURL{
+			CrawlID:      crawl.ID,
+			URLKey:       key,
+			URL:          normalized,
+			Status:       models.CrawlURLQueued,
+			LastModified: entry.LastModified,
+		})
+	}
+
+	a.crawlFrontierLock.Lock()
+	defer a.crawlFrontierLock.Unlock()
+	db := a.Database().Connection()
+	var known int64
+	result := db.Model(&models.CrawlURL{}).Where("crawl_id = ?", crawl.ID.String()).Count(&known)
+	if result.Error != nil {
+		return counts, result.Error
+	}
+	remaining := crawl.MaxPages - int(known)
+	existing, err := a.knownCrawlURLs(crawl.ID, candidates)
+	if err != nil {
+		return counts, err
+	}
+	added := []models.CrawlURL{}
+	for _, candidate := range candidates {
+		previous, found := existing[candidate.URLKey]
+		switch {
+		case !found && len(added) >= remaining:
+			counts.OverBudget++
+		case !found:
+			added = append(added, candidate)
+		case previous.Status == models.CrawlURLQueued || previous.Status == models.CrawlURLProcessing:
+			counts.Pending++
+		case previous.Status != models.CrawlURLFailed && candidate.LastModified != nil && previous.LastModified != nil && !candidate.LastModified.After(*previous.LastModified):
+			counts.Unchanged++
+		default:
+			result = db.Model(&previous).Updates(map[string]interface{}{
+				"status":        models.CrawlURLQueued,
+				"depth":         0,
+				"error":         "",
+				"last_modified": candidate.LastModified,
+			})
+			if result.Error != nil {
+				return counts, result.Error
+			}
+			counts.Requeued++
+		}
+	}
+	err = a.createCrawlURLs(added)
+	if err != nil {
+		return counts, err
+	}
+	counts.Added = len(added)
+	if crawl.Status == models.CrawlFinished && counts.Added+counts.Requeued > 0 {
+		result = db.Model(&crawl).
+			Where("status = ?", models.CrawlFinished).
+			Updates(map[string]interface{}{"status": models.CrawlRunning, "finished_at": nil})
+		if result.Error != nil {
+			return counts, result.Error
+		}
+	}
+	return counts, nil
+}
+
+// crawlSourceAction - seeds the crawl from a sitemap, feed or url list, either fetched from the url or given as
+// the content of the request. The source is ingested by a job, sources and the sitemaps they refer to are only
+// fetched from the hosts allowed for ad-hoc scrapes.
+func (a *Application) crawlSourceAction(w http.ResponseWriter, r *http.Request) {
+	crawl, found := a.findCrawl(w, r)
+	if !found {
+		return
+	}
+	var request crawlSourceRequest
+	// the content is capped like an import, it is parsed before the job is submitted
+	r.Body = http.MaxBytesReader(w, r.Body, a.maxImportSize)
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "invalid or too large request body")
+		return
+	}
+	request.URL = strings.TrimSpace(request.URL)
+	if (len(request.URL) > 0) == (len(strings.TrimSpace(request.Content)) > 0) {
+		errorResponse(w, http.StatusUnprocessableEntity, "either url or content is required")
+		return
+	}
+	input := map[string]interface{}{
+		"crawl_id": crawl.ID.String(),
+	}
+	var source crawler.Source
+	if len(request.URL) > 0 {
+		normalized, err := crawler.Normalize(request.URL)
+		if err != nil {
+			errorResponse(w, http.StatusUnprocessableEntity, "invalid url: "+err.Error())
+			return
+		}
+		if !a.adhocHosts.AllowsURL(normalized) {
+			errorResponse(w, http.StatusForbidden, "host of url \""+request.URL+"\" is not allowed")
+			return
+		}
+		input["url"] = request.URL
+	} else {
+		source, err = crawler.ParseSource([]byte(request.Content))
+		if err != nil {
+			errorResponse(w, http.StatusUnprocessableEntity, "invalid content: "+err.Error())
+			return
+		}
+		input["content_length"] = len(request.Content)
+	}
+	job, err := a.jobs.Submit(crawlIngestJobType, input, a.crawlIngestRunner(crawl, request.URL, source))
+	if err != nil {
+		submitErrorResponse(w, err)
+		return
+	}
+	job, _, err = a.jobs.Wait(job.ID, a.maxLookupWait)
+	if err != nil {
+		panic(err)
+	}
+	jobResponse(w, job)
+}
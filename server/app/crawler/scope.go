@@ -0,0 +1,78 @@
+package crawler
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+)
+
+// Scope - the links a crawl follows, a link is followed when it matches any include pattern and no exclude
+// pattern. Without include patterns only links to the hosts of the seeds are followed.
+type Scope struct {
+	hosts   map[string]bool
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// NewScope - compiles the include and exclude patterns, patterns are regular expressions matched against the
+// normalized url
+func NewScope(seeds []string, include []string, exclude []string) (Scope, error) {
+	hosts := map[string]bool{}
+	for _, seed := range seeds {
+		if host := hostOf(seed); len(host) > 0 {
+			hosts[host] = true
+		}
+	}
+	compiledInclude, err := compilePatterns(include)
+	if err != nil {
+		return Scope{}, fmt.Errorf("include: %w", err)
+	}
+	compiledExclude, err := compilePatterns(exclude)
+	if err != nil {
+		return Scope{}, fmt.Errorf("exclude: %w", err)
+	}
+	return Scope{
+		hosts:   hosts,
+		include: compiledInclude,
+		exclude: compiledExclude,
+	}, nil
+}
+
+// Allows - checks if the normalized url is in scope
+func (s Scope) Allows(normalized string) bool {
+	for _, pattern := range s.exclude {
+		if pattern.MatchString(normalized) {
+			return false
+		}
+	}
+	if len(s.include) == 0 {
+		return s.hosts[hostOf(normalized)]
+	}
+	for _, pattern := range s.include {
+		if pattern.MatchString(normalized) {
+			return true
+		}
+	}
+	return false
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	output := []*regexp.Regexp{}
+	for _, pattern := range patterns {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern \"%s\": %w", pattern, err)
+		}
+		output = append(output, compiled)
+	}
+	return output, nil
+}
+
+// hostOf - the host, including a port that is not the default, of the normalized url
+func hostOf(normalized string) string {
+	parsed, err := url.Parse(normalized)
+	if err != nil {
+		return ""
+	}
+	return parsed.Host
+}
@@ -0,0 +1,44 @@
+package crawler
+
+import "testing"
+
+func TestScopeAllows(t *testing.T) {
+	seeds := []string{"https://example.com/", "http://shop.example.com:8080/start"}
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		url     string
+		allowed bool
+	}{
+		{"seed host", nil, nil, "https://example.com/about", true},
+		{"seed host with port", nil, nil, "http://shop.example.com:8080/cart", true},
+		{"seed host on another port", nil, nil, "http://shop.example.com/cart", false},
+		{"subdomain of seed host", nil, nil, "https://www.example.com/", false},
+		{"other host", nil, nil, "https://other.org/", false},
+		{"excluded seed host", nil, []string{"/about$"}, "https://example.com/about", false},
+		{"included other host", []string{"^https://other\\.org/"}, nil, "https://other.org/page", true},
+		{"seed host not included", []string{"^https://other\\.org/"}, nil, "https://example.com/", false},
+		{"included and excluded", []string{"^https://other\\.org/"}, []string{"private"}, "https://other.org/private", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scope, err := NewScope(seeds, test.include, test.exclude)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if allowed := scope.Allows(test.url); allowed != test.allowed {
+				t.Errorf("expected %v for %s, got %v", test.allowed, test.url, allowed)
+			}
+		})
+	}
+}
+
+func TestScopeInvalidPattern(t *testing.T) {
+	if _, err := NewScope(nil, []string{"("}, nil); err == nil {
+		t.Error("expected an invalid include pattern to fail")
+	}
+	if _, err := NewScope(nil, nil, []string{"("}); err == nil {
+		t.Error("expected an invalid exclude pattern to fail")
+	}
+}
@@ -0,0 +1,246 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxSourceSize - the max size of a source document after decompression, the limit of the sitemap protocol
+const maxSourceSize = 50 << 20
+
+// maxSitemaps - how many sitemaps of a sitemap index are fetched at most
+const maxSitemaps = 100
+
+// lastModifiedLayouts - the W3C datetime formats of sitemaps and atom feeds and the RFC 822 dates of rss feeds
+var lastModifiedLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02",
+	"2006-01",
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	time.RFC822Z,
+	time.RFC822,
+}
+
+// Entry - a url listed by a source with the time it was last modified, if the source tells
+type Entry struct {
+	URL          string     `json:"url"`
+	LastModified *time.Time `json:"last_modified,omitempty"`
+}
+
+// Source - the urls of a sitemap, feed or url list, a sitemap index lists sitemaps instead of urls
+type Source struct {
+	Format   string
+	Entries  []Entry
+	Sitemaps []string
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+type urlSet struct {
+	URLs []sitemapURL `xml:"url"`
+}
+
+type sitemapIndex struct {
+	Sitemaps []sitemapURL `xml:"sitemap"`
+}
+
+type rssFeed struct {
+	Items []struct {
+		Link    string `xml:"link"`
+		PubDate string `xml:"pubDate"`
+	} `xml:"channel>item"`
+}
+
+type atomFeed struct {
+	Entries []struct {
+		Links []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"link"`
+		Updated   string `xml:"updated"`
+		Published string `xml:"published"`
+	} `xml:"entry"`
+}
+
+// ParseSource - parses a sitemap, sitemap index, rss or atom feed or a plain text list with a url per line,
+// gzip compressed documents are decompressed first
+func ParseSource(data []byte) (Source, error) {
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return Source{}, err
+		}
+		data, err = io.ReadAll(io.LimitReader(reader, maxSourceSize))
+		if err != nil {
+			return Source{}, fmt.Errorf("failed to decompress source: %w", err)
+		}
+	}
+	root, isXML := rootElement(data)
+	if !isXML {
+		return parseURLList(data)
+	}
+	switch root {
+	case "urlset":
+		var parsed urlSet
+		if err := xml.Unmarshal(data, &parsed); err != nil {
+			return Source{}, err
+		}
+		output := Source{Format: "sitemap"}
+		for _, entry := range parsed.URLs {
+			output.Entries = append(output.Entries, newEntry(entry.Loc, entry.LastMod))
+		}
+		return output, nil
+	case "sitemapindex":
+		var parsed sitemapIndex
+		if err := xml.Unmarshal(data, &parsed); err != nil {
+			return Source{}, err
+		}
+		output := Source{Format: "sitemapindex"}
+		for _, sitemap := range parsed.Sitemaps {
+			output.Sitemaps = append(output.Sitemaps, strings.TrimSpace(sitemap.Loc))
+		}
+		return output, nil
+	case "rss":
+		var parsed rssFeed
+		if err := xml.Unmarshal(data, &parsed); err != nil {
+			return Source{}, err
+		}
+		output := Source{Format: "rss"}
+		for _, item := range parsed.Items {
+			output.Entries = append(output.Entries, newEntry(item.Link, item.PubDate))
+		}
+		return output, nil
+	case "feed":
+		var parsed atomFeed
+		if err := xml.Unmarshal(data, &parsed); err != nil {
+			return Source{}, err
+		}
+		output := Source{Format: "atom"}
+		for _, entry := range parsed.Entries {
+			for _, link := range entry.Links {
+				if len(link.Rel) == 0 || link.Rel == "alternate" {
+					updated := entry.Updated
+					if len(updated) == 0 {
+						updated = entry.Published
+					}
+					output.Entries = append(output.Entries, newEntry(link.Href, updated))
+					break
+				}
+			}
+		}
+		return output, nil
+	}
+	return Source{}, errors.New("unsupported source document: <" + root + ">")
+}
+
+// FetchSource - downloads and parses the source at the url, the sitemaps of a sitemap index are fetched and
+// their urls returned instead
+func FetchSource(ctx context.Context, client *http.Client, url string) (Source, error) {
+	source, err := fetchSource(ctx, client, url)
+	if err != nil || len(source.Sitemaps) == 0 {
+		return source, err
+	}
+	return FetchSitemaps(ctx, client, source)
+}
+
+// FetchSitemaps - fetches the sitemaps listed by a sitemap index and collects their urls, indexes listed by
+// the index are not followed
+func FetchSitemaps(ctx context.Context, client *http.Client, index Source) (Source, error) {
+	output := Source{Format: index.Format, Entries: index.Entries}
+	if len(index.Sitemaps) > maxSitemaps {
+		return Source{}, fmt.Errorf("sitemap index lists more than %d sitemaps", maxSitemaps)
+	}
+	for _, sitemap := range index.Sitemaps {
+		source, err := fetchSource(ctx, client, sitemap)
+		if err != nil {
+			return Source{}, fmt.Errorf("sitemap \"%s\": %w", sitemap, err)
+		}
+		output.Entries = append(output.Entries, source.Entries...)
+	}
+	return output, nil
+}
+
+func fetchSource(ctx context.Context, client *http.Client, url string) (Source, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return Source{}, err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return Source{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		return Source{}, fmt.Errorf("unexpected status: %d", res.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxSourceSize))
+	if err != nil {
+		return Source{}, err
+	}
+	return ParseSource(data)
+}
+
+// rootElement - the name of the root element of a XML document, plain text is not XML
+func rootElement(data []byte) (string, bool) {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	if !bytes.HasPrefix(trimmed, []byte("<")) {
+		return "", false
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(trimmed))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", true
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local, true
+		}
+	}
+}
+
+func parseURLList(data []byte) (Source, error) {
+	output := Source{Format: "text"}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		output.Entries = append(output.Entries, Entry{URL: line})
+	}
+	if err := scanner.Err(); err != nil {
+		return Source{}, fmt.Errorf("failed to read url list: %w", err)
+	}
+	return output, nil
+}
+
+func newEntry(url string, lastModified string) Entry {
+	entry := Entry{URL: strings.TrimSpace(url)}
+	lastModified = strings.TrimSpace(lastModified)
+	if len(lastModified) == 0 {
+		return entry
+	}
+	for _, layout := range lastModifiedLayouts {
+		if parsed, err := time.Parse(layout, lastModified); err == nil {
+			entry.LastModified = &parsed
+			break
+		}
+	}
+	return entry
+}
@@ -0,0 +1,20 @@
+package crawler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseURLList(t *testing.T) {
+	source, err := ParseSource([]byte("# seeds\nhttps://example.com/a\n\n  https://example.com/b  \n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source.Format != "text" || len(source.Entries) != 2 || source.Entries[1].URL != "https://example.com/b" {
+		t.Errorf("unexpected source %v", source)
+	}
+	// a line longer than the buffer of the scanner ends the list, it must not be taken as the whole list
+	if _, err := ParseSource([]byte("https://example.com/a\nhttps://example.com/" + strings.Repeat("a", 70000) + "\n")); err == nil {
+		t.Error("expected an unreadable url list to be refused")
+	}
+}
@@ -0,0 +1,87 @@
+package crawler
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// MaxURLLength - the longest normalized url kept in the frontier, the size of the url columns
+const MaxURLLength = 2048
+
+// ErrURLTooLong - the normalized url is longer than MaxURLLength
+var ErrURLTooLong = errors.New("url is longer than 2048 characters")
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Normalize - the canonical form of an absolute http(s) url: the scheme and host are lowercased, default ports,
+// fragments and empty queries are dropped and the query parameters are sorted. Urls longer than MaxURLLength
+// once normalized are refused with ErrURLTooLong.
+func Normalize(raw string) (string, error) {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", err
+	}
+	return normalizeURL(parsed)
+}
+
+// Resolve - resolves a link found on the page at base and normalizes it
+func Resolve(base string, href string) (string, error) {
+	parsed, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	link, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return "", err
+	}
+	return normalizeURL(parsed.ResolveReference(link))
+}
+
+func normalizeURL(parsed *url.URL) (string, error) {
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	if _, found := defaultPorts[parsed.Scheme]; !found {
+		return "", errors.New("unsupported scheme: \"" + parsed.Scheme + "\"")
+	}
+	if len(parsed.Host) == 0 {
+		return "", errors.New("missing host")
+	}
+	host := strings.ToLower(parsed.Hostname())
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port := parsed.Port(); len(port) > 0 && port != defaultPorts[parsed.Scheme] {
+		host += ":" + port
+	}
+	parsed.Host = host
+	parsed.User = nil
+	parsed.Fragment = ""
+	parsed.RawFragment = ""
+	if len(parsed.Path) == 0 {
+		parsed.Path = "/"
+	}
+	query := parsed.Query()
+	for _, values := range query {
+		sort.Strings(values)
+	}
+	// Encode sorts the parameters by key
+	parsed.RawQuery = query.Encode()
+	parsed.ForceQuery = false
+	normalized := parsed.String()
+	if len(normalized) > MaxURLLength {
+		return "", ErrURLTooLong
+	}
+	return normalized, nil
+}
+
+// Key - a fixed length key identifying a normalized url in the frontier
+func Key(normalized string) string {
+	sum := sha1.Sum([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
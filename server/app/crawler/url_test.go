@@ -0,0 +1,54 @@
+package crawler
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	long := "https://example.com/" + strings.Repeat("a", 3000)
+	tests := []struct {
+		name       string
+		url        string
+		normalized string
+		err        error
+	}{
+		{"scheme, host and default port", "HTTPS://Example.COM:443/Path", "https://example.com/Path", nil},
+		{"sorted query without fragment", "https://example.com/?b=2&a=1#top", "https://example.com/?a=1&b=2", nil},
+		{"empty path", "https://example.com", "https://example.com/", nil},
+		{"at the max length", "https://example.com/" + strings.Repeat("a", MaxURLLength-len("https://example.com/")), "https://example.com/" + strings.Repeat("a", MaxURLLength-len("https://example.com/")), nil},
+		{"longer than the max length", long, "", ErrURLTooLong},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			normalized, err := Normalize(test.url)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+			if normalized != test.normalized {
+				t.Errorf("expected %q, got %q", test.normalized, normalized)
+			}
+		})
+	}
+}
+
+func TestLongLinksAreRefused(t *testing.T) {
+	href := "/" + strings.Repeat("a", 3000)
+	if _, err := Resolve("https://example.com/", href); !errors.Is(err, ErrURLTooLong) {
+		t.Errorf("expected a link of 3000 characters to be refused, got %v", err)
+	}
+	source, err := ParseSource([]byte("https://example.com/short\nhttps://example.com" + href + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	refused := 0
+	for _, entry := range source.Entries {
+		if _, err := Normalize(entry.URL); errors.Is(err, ErrURLTooLong) {
+			refused++
+		}
+	}
+	if len(source.Entries) != 2 || refused != 1 {
+		t.Errorf("expected 1 of 2 listed urls refused, got %d of %d", refused, len(source.Entries))
+	}
+}
@@ -0,0 +1,310 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/samber/lo"
+	"go-scrape-this/server/app/crawler"
+	"go-scrape-this/server/app/database/models"
+	"go-scrape-this/server/app/jobs"
+	"go-scrape-this/server/app/scrape"
+	"go-scrape-this/server/app/scrape/recipe"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+const crawlPageJobType = "crawl-page"
+
+// checkCrawls - submits jobs for the queued urls of every running crawl, keeping at most the configured amount
+// of pages of a crawl in flight, crawls without queued or processing urls are finished. Jobs are submitted in
+// batches that never grow the backlog of the queue beyond the batch size.
+func (a *Application) checkCrawls() {
+	var crawls []models.Crawl
+	result := a.Database().Connection().Where("status = ?", models.CrawlRunning).Order("created_at").Find(&crawls)
+	if result.Error != nil {
+		a.DefaultLogger().Error().Err(result.Error).Msg("failed to find running crawls")
+		return
+	}
+	batch := a.crawlBatchSize - a.queue.Pending()
+	for _, crawl := range crawls {
+		submitted, err := a.advanceCrawl(crawl, batch)
+		if err != nil {
+			a.DefaultLogger().Error().Err(err).Str("crawl", crawl.ID.String()).Msg("failed to advance crawl")
+		}
+		batch -= submitted
+	}
+}
+
+// advanceCrawl - submits up to batch queued urls of the crawl, returns how many were submitted
+func (a *Application) advanceCrawl(crawl models.Crawl, batch int) (int, error) {
+	db := a.Database().Connection()
+	var processing int64
+	result := db.Model(&models.CrawlURL{}).
+		Where("crawl_id = ? AND status = ?", crawl.ID.String(), models.CrawlURLProcessing).
+		Count(&processing)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	slots := a.maxCrawlInFlight - int(processing)
+	if batch < slots {
+		slots = batch
+	}
+	if slots <= 0 {
+		return 0, nil
+	}
+	var queued []models.CrawlURL
+	result = db.
+		Where("crawl_id = ? AND status = ?", crawl.ID.String(), models.CrawlURLQueued).
+		Order("depth, id").
+		Limit(slots).
+		Find(&queued)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if len(queued) == 0 {
+		if processing == 0 {
+			now := time.Now()
+			crawl.Status = models.CrawlFinished
+			crawl.FinishedAt = &now
+			return 0, db.Model(&crawl).Select("status", "finished_at").Updates(&crawl).Error
+		}
+		return 0, nil
+	}
+	page, err := crawlPage(crawl)
+	if err != nil {
+		return 0, err
+	}
+	for i, entry := range queued {
+		runner := a.crawlPageRunner(page, entry.URL)
+		// the entry is marked as processing with the job record, a job finishing right away must find it so
+		_, err := a.jobs.SubmitPrepared(crawlPageJobType, map[string]interface{}{
+			"crawl_id": crawl.ID.String(),
+			"url":      entry.URL,
+			"url_key":  entry.URLKey,
+			"depth":    entry.Depth,
+		}, func(logger *zerolog.Logger, _ uuid.UUID, _ jobs.Attach) (map[string]interface{}, error) {
+			return runner(logger)
+		}, func(tx *gorm.DB, job models.Job) error {
+			return tx.Model(&entry).Updates(map[string]interface{}{
+				"status": models.CrawlURLProcessing,
+				"job_id": job.ID.String(),
+			}).Error
+		})
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(queued), nil
+}
+
+func (a *Application) crawlPageRunner(page scrape.CrawlPage, url string) jobs.Runner {
+	return a.scrapeRetry.Wrap(a.stopping, func(logger *zerolog.Logger) (map[string]interface{}, error) {
+		logger.Info().Str("url", url).Msg("crawling page")
+		result, err := scrape.RunCrawlPage(page, url, scrape.RunOptions{
+			Timeout:      a.scrapeTimeout,
+			AllowedHosts: a.adhocHosts,
+		})
+		if err != nil {
+			return nil, err
+		}
+		result["url"] = url
+		return result, nil
+	})
+}
+
+// crawlPage - the page scrape configured for the crawl
+func crawlPage(crawl models.Crawl) (scrape.CrawlPage, error) {
+	fields := map[string]recipe.Rule{}
+	if len(crawl.Fields) > 0 {
+		encoded, err := json.Marshal(crawl.Fields)
+		if err == nil {
+			err = json.Unmarshal(encoded, &fields)
+		}
+		if err != nil {
+			return scrape.CrawlPage{}, fmt.Errorf("invalid crawl fields: %w", err)
+		}
+	}
+	return scrape.CrawlPage{
+		Engine: crawl.Engine,
+		Links:  crawl.Links,
+		Fields: fields,
+	}, nil
+}
+
+// recordCrawlPage - job listener that marks crawled urls as visited or failed and adds the links found on
+// visited pages to the frontier until the max depth of the crawl is reached
+func (a *Application) recordCrawlPage(job models.Job) {
+	if job.Type != crawlPageJobType {
+		return
+	}
+	crawlID, _ := job.Input["crawl_id"].(string)
+	key, _ := job.Input["url_key"].(string)
+	db := a.Database().Connection()
+	var crawl models.Crawl
+	result := db.First(&crawl, "id = ?", crawlID)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return
+	}
+	if result.Error != nil {
+		a.DefaultLogger().Error().Err(result.Error).Str("crawl", crawlID).Msg("failed to find crawl")
+		return
+	}
+	var entry models.CrawlURL
+	result = db.Where("crawl_id = ? AND url_key = ?", crawlID, key).First(&entry)
+	if result.Error != nil {
+		a.DefaultLogger().Error().Err(result.Error).Str("crawl", crawlID).Msg("failed to find crawled url")
+		return
+	}
+	entry.JobID = &job.ID
+	entry.VisitedAt = job.FinishedAt
+	entry.Error = job.Error
+	entry.Status = models.CrawlURLVisited
+	if job.Status != models.JobSucceeded {
+		entry.Status = models.CrawlURLFailed
+	}
+	result = db.Save(&entry)
+	if result.Error != nil {
+		a.DefaultLogger().Error().Err(result.Error).Uint("url", entry.ID).Msg("failed to update crawled url")
+		return
+	}
+	if entry.Status != models.CrawlURLVisited || entry.Depth >= crawl.MaxDepth {
+		return
+	}
+	links := []string{}
+	tooLong := 0
+	if found, ok := job.Result["links"].([]interface{}); ok {
+		for _, link := range found {
+			href, ok := link.(string)
+			if !ok {
+				continue
+			}
+			resolved, err := crawler.Resolve(entry.URL, href)
+			if err == nil {
+				links = append(links, resolved)
+			} else if errors.Is(err, crawler.ErrURLTooLong) {
+				tooLong++
+			}
+		}
+	}
+	if tooLong > 0 {
+		a.DefaultLogger().Warn().Str("crawl", crawlID).Uint("url", entry.ID).Int("links", tooLong).Msg("skipped links longer than the max url length")
+	}
+	_, err := a.enqueueCrawlURLs(crawl, links, entry.Depth+1)
+	if err != nil {
+		a.DefaultLogger().Error().Err(err).Str("crawl", crawlID).Msg("failed to add links to crawl frontier")
+	}
+}
+
+// enqueueCrawlURLs - adds the normalized urls that are in scope and not yet known to the frontier of the crawl,
+// seeds (depth 0) are added regardless of the scope. No more urls are added once the frontier holds max pages.
+// Urls longer than the url column are skipped, so they never fail the insert of the others.
+func (a *Application) enqueueCrawlURLs(crawl models.Crawl, urls []string, depth int) (int, error) {
+	scope, err := crawler.NewScope(crawl.Seeds, crawl.Include, crawl.Exclude)
+	if err != nil {
+		return 0, err
+	}
+	candidates := []models.CrawlURL{}
+	seen := map[string]bool{}
+	for _, url := range urls {
+		key := crawler.Key(url)
+		if seen[key] || len(url) > crawler.MaxURLLength || (depth > 0 && !scope.Allows(url)) {
+			continue
+		}
+		seen[key] = true
+		candidates = append(candidates, models.CrawlURL{
+			CrawlID: crawl.ID,
+			URLKey:  key,
+			URL:     url,
+			Depth:   depth,
+			Status:  models.CrawlURLQueued,
+		})
+	}
+	if len(candidates) == 0 {
+		return 0, nil
+	}
+
+	// listeners of concurrently finished pages would otherwise both see room left in the frontier
+	a.crawlFrontierLock.Lock()
+	defer a.crawlFrontierLock.Unlock()
+	db := a.Database().Connection()
+	var known int64
+	result := db.Model(&models.CrawlURL{}).Where("crawl_id = ?", crawl.ID.String()).Count(&known)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	remaining := crawl.MaxPages - int(known)
+	if remaining <= 0 {
+		return 0, nil
+	}
+	existing, err := a.knownCrawlURLs(crawl.ID, candidates)
+	if err != nil {
+		return 0, err
+	}
+	added := []models.CrawlURL{}
+	for _, candidate := range candidates {
+		if len(added) >= remaining {
+			break
+		}
+		if _, found := existing[candidate.URLKey]; !found {
+			added = append(added, candidate)
+		}
+	}
+	return len(added), a.createCrawlURLs(added)
+}
+
+// knownCrawlURLs - the entries of the frontier of the crawl matching the keys of the candidates, by key
+func (a *Application) knownCrawlURLs(id uuid.UUID, candidates []models.CrawlURL) (map[string]models.CrawlURL, error) {
+	output := map[string]models.CrawlURL{}
+	for _, chunk := range lo.Chunk(lo.Map(candidates, func(c models.CrawlURL, _ int) string { return c.URLKey }), 500) {
+		var entries []models.CrawlURL
+		result := a.Database().Connection().
+			Where("crawl_id = ? AND url_key IN ?", id.String(), chunk).
+			Find(&entries)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		for _, entry := range entries {
+			output[entry.URLKey] = entry
+		}
+	}
+	return output, nil
+}
+
+func (a *Application) createCrawlURLs(entries []models.CrawlURL) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return a.Database().Connection().Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&entries, 100).Error
+}
+
+// crawlStatusCounts - the amount of urls of the crawl by their status
+func (a *Application) crawlStatusCounts(id uuid.UUID) (map[string]int, error) {
+	type statusCount struct {
+		Status string
+		Count  int
+	}
+	var counts []statusCount
+	result := a.Database().Connection().
+		Model(&models.CrawlURL{}).
+		Select("status, COUNT(*) AS count").
+		Where("crawl_id = ?", id.String()).
+		Group("status").
+		Scan(&counts)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	output := map[string]int{
+		models.CrawlURLQueued:     0,
+		models.CrawlURLProcessing: 0,
+		models.CrawlURLVisited:    0,
+		models.CrawlURLFailed:     0,
+	}
+	for _, count := range counts {
+		output[count.Status] = count.Count
+	}
+	return output, nil
+}
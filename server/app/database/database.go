@@ -53,6 +53,7 @@ var (
 )
 
 type Database struct {
+	dbType         *DatabaseType
 	conn           *gorm.DB
 	databaseModels map[string]interface{}
 }
@@ -118,9 +119,28 @@ func NewDatabase(dbType *DatabaseType, dsn string, providedLogger *zerolog.Logge
 	}
 
 	db := Database{
-		conn: connection,
+		dbType: dbType,
+		conn:   connection,
 		databaseModels: map[string]interface{}{
-			"user": models.User{},
+			"user":                  models.User{},
+			"job":                   models.Job{},
+			"job-artifact":          models.JobArtifact{},
+			"import-batch":          models.ImportBatch{},
+			"import-row":            models.ImportRow{},
+			"cache-entry":           models.CacheEntry{},
+			"schema-drift-stat":     models.SchemaDriftStat{},
+			"watchlist":             models.Watchlist{},
+			"watchlist-vehicle":     models.WatchlistVehicle{},
+			"vehicle-change":        models.VehicleChange{},
+			"vehicle-history-event": models.VehicleHistoryEvent{},
+			"webhook-subscription":  models.WebhookSubscription{},
+			"webhook-delivery":      models.WebhookDelivery{},
+			"crawl":                 models.Crawl{},
+			"crawl-url":             models.CrawlURL{},
+			"scrape-result":         models.ScrapeResult{},
+			"archive":               models.Archive{},
+			"stored-file":           models.StoredFile{},
+			"resource-blocking":     models.ResourceBlocking{},
 		},
 	}
 
@@ -131,6 +151,10 @@ func (d *Database) Connection() *gorm.DB {
 	return d.conn
 }
 
+func (d *Database) Type() *DatabaseType {
+	return d.dbType
+}
+
 func (d *Database) RunMigrations() error {
 	return d.conn.AutoMigrate(maps.Values(d.databaseModels)...)
 }
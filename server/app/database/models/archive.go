@@ -0,0 +1,24 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Archive - a WARC file of the exchanges of a scrape, the file itself is kept by the storage backend. Archives of
+// scrapes replayed from a fixture are marked as replayed, their exchanges never took place.
+type Archive struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	JobID      uuid.UUID `gorm:"type:string;size:36;index" json:"job_id"`
+	Source     string    `gorm:"size:32;index:idx_archive_subject" json:"source"`
+	SearchType string    `gorm:"size:32;index:idx_archive_subject" json:"search_type,omitempty"`
+	Value      string    `gorm:"size:64;index:idx_archive_subject" json:"value"`
+	Vin        string    `gorm:"size:17;index" json:"vin,omitempty"`
+	Storage    string    `gorm:"size:32" json:"storage"`
+	StorageKey string    `gorm:"size:191" json:"-"`
+	Size       int64     `json:"size"`
+	Records    int       `json:"records"`
+	Replayed   bool      `json:"replayed,omitempty"`
+	Sha256     string    `gorm:"size:64" json:"sha256"`
+	CapturedAt time.Time `gorm:"index" json:"captured_at"`
+}
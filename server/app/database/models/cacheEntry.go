@@ -0,0 +1,14 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type CacheEntry struct {
+	ID        uint      `gorm:"primaryKey" json:"-"`
+	Source    string    `gorm:"size:32;uniqueIndex:idx_cache_entry_key" json:"source"`
+	CacheKey  string    `gorm:"size:191;uniqueIndex:idx_cache_entry_key" json:"key"`
+	JobID     uuid.UUID `gorm:"type:string;size:36" json:"job_id"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
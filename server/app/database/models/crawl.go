@@ -0,0 +1,69 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"go-scrape-this/server/app/database/structs"
+	"time"
+)
+
+const (
+	CrawlRunning  = "running"
+	CrawlPaused   = "paused"
+	CrawlFinished = "finished"
+)
+
+const (
+	CrawlURLQueued     = "queued"
+	CrawlURLProcessing = "processing"
+	CrawlURLVisited    = "visited"
+	CrawlURLFailed     = "failed"
+)
+
+type Crawl struct {
+	ID         uuid.UUID          `gorm:"primaryKey;type:string;size:36;<-:create" json:"id"`
+	Name       string             `gorm:"size:255" json:"name"`
+	Seeds      structs.StringList `gorm:"size:16777215" json:"seeds"`
+	Include    structs.StringList `gorm:"size:16777215" json:"include,omitempty"`
+	Exclude    structs.StringList `gorm:"size:16777215" json:"exclude,omitempty"`
+	MaxDepth   int                `json:"max_depth"`
+	MaxPages   int                `json:"max_pages"`
+	Engine     string             `gorm:"size:16" json:"engine"`
+	Links      string             `gorm:"size:255" json:"links"`
+	Fields     structs.JSONMap    `gorm:"size:16777215" json:"fields,omitempty"`
+	Status     string             `gorm:"size:16;index" json:"status"`
+	CreatedAt  time.Time          `gorm:"autoCreateTime:milli" json:"created_at"`
+	UpdatedAt  time.Time          `gorm:"autoUpdateTime:milli" json:"updated_at,omitempty"`
+	FinishedAt *time.Time         `json:"finished_at,omitempty"`
+}
+
+// CrawlURL - an entry of the frontier of a crawl, every normalized url is kept once per crawl. The last modified
+// time is the one of the sitemap or feed the url was last ingested from.
+type CrawlURL struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	CrawlID      uuid.UUID  `gorm:"type:string;size:36;uniqueIndex:idx_crawl_urls_key;index:idx_crawl_urls_status" json:"crawl_id"`
+	URLKey       string     `gorm:"size:40;uniqueIndex:idx_crawl_urls_key" json:"-"`
+	URL          string     `gorm:"size:2048" json:"url"`
+	Depth        int        `json:"depth"`
+	Status       string     `gorm:"size:16;index:idx_crawl_urls_status" json:"status"`
+	JobID        *uuid.UUID `gorm:"type:string;size:36;index" json:"job_id,omitempty"`
+	Error        string     `gorm:"size:1024" json:"error,omitempty"`
+	CreatedAt    time.Time  `gorm:"autoCreateTime:milli" json:"created_at"`
+	VisitedAt    *time.Time `json:"visited_at,omitempty"`
+	LastModified *time.Time `json:"last_modified,omitempty"`
+}
+
+func NewCrawl(name string, seeds []string, include []string, exclude []string, maxDepth int, maxPages int, engine string, links string, fields map[string]interface{}) Crawl {
+	return Crawl{
+		ID:       uuid.New(),
+		Name:     name,
+		Seeds:    seeds,
+		Include:  include,
+		Exclude:  exclude,
+		MaxDepth: maxDepth,
+		MaxPages: maxPages,
+		Engine:   engine,
+		Links:    links,
+		Fields:   fields,
+		Status:   CrawlRunning,
+	}
+}
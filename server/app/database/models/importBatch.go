@@ -0,0 +1,59 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"go-scrape-this/server/app/database/structs"
+	"time"
+)
+
+const (
+	ImportRowQueued    = "queued"
+	ImportRowInvalid   = "invalid"
+	ImportRowDuplicate = "duplicate"
+)
+
+const (
+	// ImportRowValueSize - the most characters of the identifier kept for a row
+	ImportRowValueSize = 255
+	// ImportRowErrorSize - the most characters of the error kept for a row
+	ImportRowErrorSize = 255
+)
+
+type ImportBatch struct {
+	ID          uuid.UUID          `gorm:"primaryKey;type:string;size:36;<-:create" json:"id"`
+	Filename    string             `gorm:"size:255" json:"filename"`
+	Format      string             `gorm:"size:16" json:"format"`
+	SearchType  string             `gorm:"size:32" json:"search_type"`
+	ColumnIndex int                `json:"column_index"`
+	Header      structs.StringList `gorm:"size:16777215" json:"header,omitempty"`
+	Tabs        structs.StringList `gorm:"size:255" json:"tabs,omitempty"`
+	Total       int                `json:"total"`
+	Queued      int                `json:"queued"`
+	Invalid     int                `json:"invalid"`
+	Duplicates  int                `json:"duplicates"`
+	CreatedAt   time.Time          `gorm:"autoCreateTime:milli" json:"created_at"`
+	UpdatedAt   time.Time          `gorm:"autoUpdateTime:milli" json:"updated_at,omitempty"`
+}
+
+type ImportRow struct {
+	ID      uint               `gorm:"primaryKey" json:"-"`
+	BatchID uuid.UUID          `gorm:"type:string;size:36;index" json:"-"`
+	Line    int                `json:"line"`
+	Value   string             `gorm:"size:255" json:"value"`
+	Cells   structs.StringList `gorm:"size:16777215" json:"cells"`
+	Status  string             `gorm:"size:16" json:"status"`
+	Error   string             `gorm:"size:255" json:"error,omitempty"`
+	JobID   *uuid.UUID         `gorm:"type:string;size:36;index" json:"job_id,omitempty"`
+}
+
+func NewImportBatch(filename string, format string, searchType string, column int, header []string, tabs []string) ImportBatch {
+	return ImportBatch{
+		ID:          uuid.New(),
+		Filename:    filename,
+		Format:      format,
+		SearchType:  searchType,
+		ColumnIndex: column,
+		Header:      header,
+		Tabs:        tabs,
+	}
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"go-scrape-this/server/app/database/structs"
+	"time"
+)
+
+const (
+	JobQueued     = "queued"
+	JobProcessing = "processing"
+	JobSucceeded  = "succeeded"
+	JobFailed     = "failed"
+)
+
+type Job struct {
+	ID          uuid.UUID       `gorm:"primaryKey;type:string;size:36;<-:create" json:"id"`
+	Type        string          `gorm:"size:64;index" json:"type"`
+	Status      string          `gorm:"size:16;index" json:"status"`
+	Input       structs.JSONMap `gorm:"size:16777215" json:"input,omitempty"`
+	Result      structs.JSONMap `gorm:"size:4294967295" json:"result,omitempty"`
+	Error       string          `gorm:"size:1024" json:"error,omitempty"`
+	ErrorKind   string          `gorm:"size:32" json:"error_kind,omitempty"`
+	Diagnostics bool            `json:"diagnostics,omitempty"`
+	CreatedAt   time.Time       `gorm:"autoCreateTime:milli" json:"created_at"`
+	UpdatedAt   time.Time       `gorm:"autoUpdateTime:milli" json:"updated_at,omitempty"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+}
+
+func NewJob(jobType string, input map[string]interface{}) Job {
+	return Job{
+		ID:     uuid.New(),
+		Type:   jobType,
+		Status: JobQueued,
+		Input:  input,
+	}
+}
+
+func (j Job) IsFinished() bool {
+	return j.Status == JobSucceeded || j.Status == JobFailed
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	ArtifactDiagnostics = "diagnostics"
+	ArtifactHAR         = "har"
+)
+
+// JobArtifact - a file produced by a job, like the diagnostics bundle of a failed scrape or
+// the HAR capture of a scrape
+type JobArtifact struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	JobID     uuid.UUID `gorm:"type:string;size:36;index" json:"job_id"`
+	Kind      string    `gorm:"size:32;index" json:"kind"`
+	Name      string    `gorm:"size:255" json:"name"`
+	Data      []byte    `gorm:"size:4294967295" json:"-"`
+	CreatedAt time.Time `gorm:"autoCreateTime:milli" json:"created_at"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"go-scrape-this/server/app/database/structs"
+	"time"
+)
+
+// ResourceBlocking - the requests blocked by an attempt of a scrape job and what blocking them is estimated to
+// have saved
+type ResourceBlocking struct {
+	ID            uint            `gorm:"primaryKey" json:"id"`
+	JobID         uuid.UUID       `gorm:"type:string;size:36;index" json:"job_id"`
+	Recipe        string          `gorm:"size:64" json:"recipe"`
+	Attempt       int             `json:"attempt"`
+	Requests      int             `json:"requests"`
+	ResourceTypes structs.JSONMap `gorm:"size:16777215" json:"resource_types"`
+	BytesSaved    int64           `json:"bytes_saved"`
+	TimeSavedMs   int64           `json:"time_saved_ms"`
+	Unmeasured    int             `json:"unmeasured"`
+	CreatedAt     time.Time       `gorm:"autoCreateTime:milli" json:"created_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// SchemaDriftStat - how often a field of the schema of a source was checked and missing within an hour
+type SchemaDriftStat struct {
+	ID       uint      `gorm:"primaryKey" json:"-"`
+	Source   string    `gorm:"size:32;uniqueIndex:idx_schema_drift_stat" json:"source"`
+	Field    string    `gorm:"size:191;uniqueIndex:idx_schema_drift_stat" json:"field"`
+	Hour     time.Time `gorm:"uniqueIndex:idx_schema_drift_stat" json:"hour"`
+	Required bool      `json:"required"`
+	Checks   int       `json:"checks"`
+	Missing  int       `json:"missing"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"go-scrape-this/server/app/database/structs"
+	"time"
+)
+
+// ScrapeResult - a stored result of a scrape without its screenshots. The vehicle attributes are empty for
+// results that are not vehicles and the content holds the text searched by full-text queries.
+type ScrapeResult struct {
+	ID                    uint            `gorm:"primaryKey" json:"id"`
+	JobID                 uuid.UUID       `gorm:"type:string;size:36;uniqueIndex" json:"job_id"`
+	Source                string          `gorm:"size:32;index" json:"source"`
+	Subject               string          `gorm:"size:2048" json:"subject"`
+	Make                  string          `gorm:"size:64;index" json:"make,omitempty"`
+	Model                 string          `gorm:"size:128;index" json:"model,omitempty"`
+	FirstRegistrationYear *int            `gorm:"index" json:"first_registration_year,omitempty"`
+	Insurer               string          `gorm:"size:255;index" json:"insurer,omitempty"`
+	Vin                   string          `gorm:"size:17;index" json:"vin,omitempty"`
+	Content               structs.Text    `json:"-"`
+	Data                  structs.JSONMap `gorm:"size:4294967295" json:"data"`
+	ScrapedAt             time.Time       `gorm:"index" json:"scraped_at"`
+}
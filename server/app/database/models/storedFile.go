@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// StoredFile - a file of the database storage backend, the path is its storage key
+type StoredFile struct {
+	Path      string    `gorm:"primaryKey;size:191" json:"path"`
+	Data      []byte    `gorm:"size:4294967295" json:"-"`
+	CreatedAt time.Time `gorm:"autoCreateTime:milli" json:"created_at"`
+}
@@ -20,6 +20,7 @@ type User struct {
 	ID        uuid.UUID            `gorm:"primaryKey;type:string;size:36;<-:create" json:"id"`
 	Username  string               `gorm:"unique" json:"username"`
 	Password  structs.PasswordHash `gorm:"type:string;size:200" json:"-"`
+	Admin     bool                 `gorm:"not null;default:false" json:"admin"`
 	CreatedAt time.Time            `gorm:"autoCreateTime:milli" json:"created_at"`
 	UpdatedAt time.Time            `gorm:"autoUpdateTime:milli" json:"updated_at,omitempty"`
 	DeletedAt gorm.DeletedAt       `gorm:"index" json:"deleted_at,omitempty"`
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"go-scrape-this/server/app/database/structs"
+	"time"
+)
+
+// VehicleHistoryEvent - an entry of the history of a vehicle, stored once per vehicle no matter how often it
+// was scraped
+type VehicleHistoryEvent struct {
+	ID          uint            `gorm:"primaryKey" json:"id"`
+	SearchType  string          `gorm:"size:32;uniqueIndex:idx_vehicle_history_event" json:"search_type"`
+	Value       string          `gorm:"size:64;uniqueIndex:idx_vehicle_history_event" json:"value"`
+	Hash        string          `gorm:"size:64;uniqueIndex:idx_vehicle_history_event" json:"-"`
+	Vin         string          `gorm:"size:17;index" json:"vin,omitempty"`
+	Tab         string          `gorm:"size:32" json:"tab"`
+	Type        string          `gorm:"size:255" json:"type"`
+	OccurredOn  *time.Time      `gorm:"index" json:"date"`
+	Details     structs.JSONMap `gorm:"size:16777215" json:"details"`
+	JobID       uuid.UUID       `gorm:"type:string;size:36" json:"job_id"`
+	FirstSeenAt time.Time       `json:"first_seen_at"`
+	LastSeenAt  time.Time       `json:"last_seen_at"`
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"go-scrape-this/server/app/database/structs"
+	"gorm.io/gorm"
+	"time"
+)
+
+// DefaultWatchedFields - field patterns covering the inspection status, insurance company and registration state
+var DefaultWatchedFields = []string{
+	"inspection.*",
+	"insurance.*",
+	"*Status*",
+}
+
+type Watchlist struct {
+	ID        uuid.UUID          `gorm:"primaryKey;type:string;size:36;<-:create" json:"id"`
+	Name      string             `gorm:"size:255" json:"name"`
+	Interval  int                `json:"interval"`
+	Fields    structs.StringList `gorm:"size:16777215" json:"fields"`
+	CreatedAt time.Time          `gorm:"autoCreateTime:milli" json:"created_at"`
+	UpdatedAt time.Time          `gorm:"autoUpdateTime:milli" json:"updated_at,omitempty"`
+	DeletedAt gorm.DeletedAt     `gorm:"index" json:"deleted_at,omitempty"`
+}
+
+type WatchlistVehicle struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	WatchlistID   uuid.UUID  `gorm:"type:string;size:36;index" json:"watchlist_id"`
+	SearchType    string     `gorm:"size:32" json:"search_type"`
+	Value         string     `gorm:"size:64" json:"value"`
+	LastJobID     *uuid.UUID `gorm:"type:string;size:36" json:"last_job_id,omitempty"`
+	PendingJobID  *uuid.UUID `gorm:"type:string;size:36;index" json:"pending_job_id,omitempty"`
+	LastError     string     `gorm:"size:1024" json:"last_error,omitempty"`
+	LastCheckedAt *time.Time `json:"last_checked_at,omitempty"`
+	NextCheckAt   time.Time  `gorm:"index" json:"next_check_at"`
+	CreatedAt     time.Time  `gorm:"autoCreateTime:milli" json:"created_at"`
+}
+
+type VehicleChange struct {
+	ID                 uint      `gorm:"primaryKey" json:"id"`
+	WatchlistID        uuid.UUID `gorm:"type:string;size:36;index" json:"watchlist_id"`
+	WatchlistVehicleID uint      `gorm:"index" json:"vehicle_id"`
+	Field              string    `gorm:"size:255" json:"field"`
+	Op                 string    `gorm:"size:16" json:"op"`
+	OldValue           string    `gorm:"size:1024" json:"old_value"`
+	NewValue           string    `gorm:"size:1024" json:"new_value"`
+	PreviousJobID      uuid.UUID `gorm:"type:string;size:36" json:"previous_job_id"`
+	JobID              uuid.UUID `gorm:"type:string;size:36" json:"job_id"`
+	DetectedAt         time.Time `gorm:"index" json:"detected_at"`
+}
+
+func NewWatchlist(name string, interval int, fields []string) Watchlist {
+	if len(fields) == 0 {
+		fields = DefaultWatchedFields
+	}
+	return Watchlist{
+		ID:       uuid.New(),
+		Name:     name,
+		Interval: interval,
+		Fields:   fields,
+	}
+}
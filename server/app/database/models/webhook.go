@@ -0,0 +1,45 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"go-scrape-this/server/app/database/structs"
+	"golang.org/x/exp/slices"
+	"gorm.io/gorm"
+	"time"
+)
+
+type WebhookSubscription struct {
+	ID        uuid.UUID          `gorm:"primaryKey;type:string;size:36;<-:create" json:"id"`
+	URL       string             `gorm:"size:2048" json:"url"`
+	Events    structs.StringList `gorm:"size:1024" json:"events"`
+	Secret    string             `gorm:"size:255" json:"-"`
+	CreatedAt time.Time          `gorm:"autoCreateTime:milli" json:"created_at"`
+	UpdatedAt time.Time          `gorm:"autoUpdateTime:milli" json:"updated_at,omitempty"`
+	DeletedAt gorm.DeletedAt     `gorm:"index" json:"deleted_at,omitempty"`
+}
+
+type WebhookDelivery struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	SubscriptionID uuid.UUID `gorm:"type:string;size:36;index" json:"subscription_id"`
+	EventID        uuid.UUID `gorm:"type:string;size:36;index" json:"event_id"`
+	Event          string    `gorm:"size:64" json:"event"`
+	Attempt        int       `json:"attempt"`
+	StatusCode     int       `json:"status_code"`
+	Error          string    `gorm:"size:1024" json:"error,omitempty"`
+	Duration       int64     `json:"duration"`
+	Succeeded      bool      `json:"succeeded"`
+	CreatedAt      time.Time `gorm:"autoCreateTime:milli;index" json:"created_at"`
+}
+
+func NewWebhookSubscription(url string, events []string, secret string) WebhookSubscription {
+	return WebhookSubscription{
+		ID:     uuid.New(),
+		URL:    url,
+		Events: events,
+		Secret: secret,
+	}
+}
+
+func (s WebhookSubscription) Accepts(event string) bool {
+	return slices.Contains(s.Events, event)
+}
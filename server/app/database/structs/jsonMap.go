@@ -0,0 +1,66 @@
+package structs
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// JSONMap - a map stored as a JSON encoded string column
+type JSONMap map[string]interface{}
+
+func (m JSONMap) Value() (driver.Value, error) {
+	if m == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (m *JSONMap) Scan(dbValue interface{}) error {
+	var data []byte
+	switch value := dbValue.(type) {
+	case nil:
+		*m = nil
+		return nil
+	case []byte:
+		data = value
+	case string:
+		data = []byte(value)
+	default:
+		return fmt.Errorf("unsupported data %#v", dbValue)
+	}
+	output := JSONMap{}
+	if err := json.Unmarshal(data, &output); err != nil {
+		return err
+	}
+	*m = output
+	return nil
+}
+
+func (JSONMap) GormDataType() string {
+	return string(schema.String)
+}
+
+func (JSONMap) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	return textColumnType(db, field)
+}
+
+// JSONString - the sql expression reading the string stored at the key of a JSONMap column, the key and column are
+// names from code and never input of a request
+func JSONString(db *gorm.DB, column string, key string) string {
+	switch db.Dialector.Name() {
+	case "mysql":
+		return fmt.Sprintf("JSON_UNQUOTE(JSON_EXTRACT(%s, '$.%s'))", column, key)
+	case "postgres":
+		return fmt.Sprintf("(%s::json->>'%s')", column, key)
+	case "sqlserver":
+		return fmt.Sprintf("JSON_VALUE(%s, '$.%s')", column, key)
+	}
+	return fmt.Sprintf("json_extract(%s, '$.%s')", column, key)
+}
@@ -0,0 +1,47 @@
+package structs_test
+
+import (
+	"github.com/rs/zerolog"
+	"go-scrape-this/server/app/database"
+	"go-scrape-this/server/app/database/models"
+	"go-scrape-this/server/app/database/structs"
+	"path/filepath"
+	"testing"
+)
+
+func TestJSONString(t *testing.T) {
+	logger := zerolog.Nop()
+	db, err := database.NewDatabase(database.SQLITE, filepath.Join(t.TempDir(), "test.db"), &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RunMigrations(); err != nil {
+		t.Fatal(err)
+	}
+	connection := db.Connection()
+	jobs := []models.Job{
+		models.NewJob("lookup", map[string]interface{}{"value": "ab 12345"}),
+		models.NewJob("lookup", map[string]interface{}{"value": "CD67890"}),
+		models.NewJob("lookup", map[string]interface{}{"search_type": "vin"}),
+	}
+	if err := connection.Create(&jobs).Error; err != nil {
+		t.Fatal(err)
+	}
+	value := structs.JSONString(connection, "input", "value")
+	var found []models.Job
+	result := connection.Where("UPPER(REPLACE("+value+", ' ', '')) IN ?", []string{"AB12345"}).Find(&found)
+	if result.Error != nil {
+		t.Fatal(result.Error)
+	}
+	if len(found) != 1 || found[0].ID != jobs[0].ID {
+		t.Fatalf("expected only the first job, got %v", found)
+	}
+	var missing int64
+	result = connection.Model(&models.Job{}).Where(value + " IS NULL").Count(&missing)
+	if result.Error != nil {
+		t.Fatal(result.Error)
+	}
+	if missing != 1 {
+		t.Errorf("expected one job without a value, got %d", missing)
+	}
+}
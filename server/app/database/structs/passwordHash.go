@@ -99,10 +99,12 @@ func (h PasswordHash) CompareHash(hash string) (bool, error) {
 	return h.Compare(password), nil
 }
 
+// ComparePlainText - hashes the plain text with the salt and params of the hash and compares the results
 func (h PasswordHash) ComparePlainText(plainText string) (bool, error) {
-	password, err := NewPasswordHash(plainText, h.params)
-	if err != nil {
-		return false, nil
+	password := PasswordHash{
+		hash:   hashPassword(plainText, h.salt, h.params),
+		salt:   h.salt,
+		params: h.params,
 	}
 	return h.Compare(password), nil
 }
@@ -113,7 +115,15 @@ func NewPasswordHash(plainText string, params Params) (PasswordHash, error) {
 		return PasswordHash{}, err
 	}
 
-	hash := argon2.IDKey(
+	return PasswordHash{
+		hash:   hashPassword(plainText, salt, params),
+		salt:   salt,
+		params: params,
+	}, nil
+}
+
+func hashPassword(plainText string, salt []byte, params Params) []byte {
+	return argon2.IDKey(
 		[]byte(plainText),
 		salt,
 		params.iterations,
@@ -121,12 +131,6 @@ func NewPasswordHash(plainText string, params Params) (PasswordHash, error) {
 		params.parallelism,
 		params.keyLength,
 	)
-
-	return PasswordHash{
-		hash:   hash,
-		salt:   salt,
-		params: params,
-	}, nil
 }
 
 func LoadPasswordHash(hashedValue string) (PasswordHash, error) {
@@ -0,0 +1,52 @@
+package structs
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// StringList - a list of strings stored as a JSON encoded string column
+type StringList []string
+
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (l *StringList) Scan(dbValue interface{}) error {
+	var data []byte
+	switch value := dbValue.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		data = value
+	case string:
+		data = []byte(value)
+	default:
+		return fmt.Errorf("unsupported data %#v", dbValue)
+	}
+	output := StringList{}
+	if err := json.Unmarshal(data, &output); err != nil {
+		return err
+	}
+	*l = output
+	return nil
+}
+
+func (StringList) GormDataType() string {
+	return string(schema.String)
+}
+
+func (StringList) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	return textColumnType(db, field)
+}
@@ -0,0 +1,18 @@
+package structs
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Text - a string stored in a text column, declared without a size the column has no limit beyond the one of
+// the database
+type Text string
+
+func (Text) GormDataType() string {
+	return string(schema.String)
+}
+
+func (Text) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	return textColumnType(db, field)
+}
@@ -0,0 +1,28 @@
+package structs
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// maxVarcharSize - the largest size kept as a varchar, larger ones would not fit into a MySQL row of utf8mb4 text
+const maxVarcharSize = 16383
+
+// textColumnType - the column type of a string field too large for a varchar, an empty type keeps the varchar of
+// the dialect. MySQL gets the smallest text type holding the size, a field without size or one beyond the
+// medium text gets a long text, as other databases have no such limits their text type is used.
+func textColumnType(db *gorm.DB, field *schema.Field) string {
+	if field.Size > 0 && field.Size <= maxVarcharSize {
+		return ""
+	}
+	switch db.Dialector.Name() {
+	case "mysql":
+		if field.Size > 0 && field.Size <= 16777215 {
+			return "mediumtext"
+		}
+		return "longtext"
+	case "sqlserver":
+		return "nvarchar(MAX)"
+	}
+	return "text"
+}
@@ -0,0 +1,31 @@
+package structs
+
+import (
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/driver/sqlserver"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+	"testing"
+)
+
+func TestTextColumnType(t *testing.T) {
+	tests := []struct {
+		dialector gorm.Dialector
+		expected  string
+	}{
+		{mysql.Dialector{Config: &mysql.Config{}}, "longtext"},
+		{postgres.Dialector{Config: &postgres.Config{}}, "text"},
+		{sqlite.Dialector{}, "text"},
+		{sqlserver.Dialector{Config: &sqlserver.Config{}}, "nvarchar(MAX)"},
+	}
+	for _, test := range tests {
+		t.Run(test.dialector.Name(), func(t *testing.T) {
+			db := &gorm.DB{Config: &gorm.Config{Dialector: test.dialector}}
+			if found := Text("").GormDBDataType(db, &schema.Field{}); found != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, found)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package diff
+
+import (
+	"golang.org/x/exp/maps"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	ADDED   = "added"
+	REMOVED = "removed"
+	CHANGED = "changed"
+)
+
+// Change - a single difference between two documents
+type Change struct {
+	Path []string    `json:"path"`
+	Op   string      `json:"op"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// Field - the path of the change joined with dots
+func (c Change) Field() string {
+	return strings.Join(c.Path, ".")
+}
+
+// Ignore - decides if a path should be left out of the comparison
+type Ignore func(path []string) bool
+
+// Compare - compares two documents field by field, nested maps are compared recursively
+// while every other value is compared as a whole. Changes are sorted by path.
+func Compare(old map[string]interface{}, new map[string]interface{}, ignore Ignore) []Change {
+	output := []Change{}
+	compareInto(&output, []string{}, old, new, ignore)
+	return output
+}
+
+func compareInto(output *[]Change, prefix []string, old map[string]interface{}, new map[string]interface{}, ignore Ignore) {
+	keys := map[string]bool{}
+	for key := range old {
+		keys[key] = true
+	}
+	for key := range new {
+		keys[key] = true
+	}
+	sorted := maps.Keys(keys)
+	sort.Strings(sorted)
+	for _, key := range sorted {
+		path := append(append([]string{}, prefix...), key)
+		if ignore != nil && ignore(path) {
+			continue
+		}
+		oldValue, inOld := old[key]
+		newValue, inNew := new[key]
+		switch {
+		case !inOld:
+			*output = append(*output, Change{Path: path, Op: ADDED, New: newValue})
+		case !inNew:
+			*output = append(*output, Change{Path: path, Op: REMOVED, Old: oldValue})
+		default:
+			oldMap, oldIsMap := oldValue.(map[string]interface{})
+			newMap, newIsMap := newValue.(map[string]interface{})
+			if oldIsMap && newIsMap {
+				compareInto(output, path, oldMap, newMap, ignore)
+				continue
+			}
+			if !reflect.DeepEqual(oldValue, newValue) {
+				*output = append(*output, Change{Path: path, Op: CHANGED, Old: oldValue, New: newValue})
+			}
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package diff
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// pointerEscape - escapes the keys of a path as reference tokens of a JSON Pointer (RFC 6901)
+var pointerEscape = strings.NewReplacer("~", "~0", "/", "~1")
+
+// Operation - an operation of a JSON Patch (RFC 6902)
+type Operation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// MarshalJSON - removals have no value, while a null value of other operations is kept
+func (o Operation) MarshalJSON() ([]byte, error) {
+	if o.Op == "remove" {
+		return json.Marshal(map[string]string{"op": o.Op, "path": o.Path})
+	}
+	type operation Operation
+	return json.Marshal(operation(o))
+}
+
+// Pointer - the path of the change as a JSON Pointer
+func (c Change) Pointer() string {
+	output := ""
+	for _, key := range c.Path {
+		output += "/" + pointerEscape.Replace(key)
+	}
+	return output
+}
+
+// Patch - the JSON Patch turning the old document into the new one
+func Patch(changes []Change) []Operation {
+	output := []Operation{}
+	for _, change := range changes {
+		switch change.Op {
+		case ADDED:
+			output = append(output, Operation{Op: "add", Path: change.Pointer(), Value: change.New})
+		case REMOVED:
+			output = append(output, Operation{Op: "remove", Path: change.Pointer()})
+		case CHANGED:
+			output = append(output, Operation{Op: "replace", Path: change.Pointer(), Value: change.New})
+		}
+	}
+	return output
+}
+
+// volatileFields - fields that differ between scrapes of the same unchanged page
+var volatileFields = []string{"timestamp", "scraped_at", "fetched_at", "captured_at", "retrieved_at"}
+
+// IgnoreVolatile - leaves out screenshots, their formats and the times of scrapes
+func IgnoreVolatile(path []string) bool {
+	for _, segment := range path {
+		segment = strings.ToLower(segment)
+		if strings.HasSuffix(segment, "_image") || strings.HasSuffix(segment, "_screenshot") {
+			return true
+		}
+	}
+	key := strings.ToLower(path[len(path)-1])
+	for _, field := range volatileFields {
+		if key == field {
+			return true
+		}
+	}
+	return false
+}
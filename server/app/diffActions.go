@@ -0,0 +1,185 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go-scrape-this/server/app/database/models"
+	"go-scrape-this/server/app/diff"
+	"go-scrape-this/server/app/scrape"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const jsonPatchContentType = "application/json-patch+json"
+
+// snapshotChange - a change of the readable change list
+type snapshotChange struct {
+	Field string      `json:"field"`
+	Op    string      `json:"op"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// vehicleDiffAction - compares two stored snapshots of the vehicle with the registration number
+func (a *Application) vehicleDiffAction(w http.ResponseWriter, r *http.Request) {
+	plate, err := scrape.REGISTRATION_NUMBER.Normalize(mux.Vars(r)["plate"])
+	if err != nil {
+		errorResponse(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	a.snapshotDiff(w, r, scrape.DmrSource, plate)
+}
+
+// sourceDiffAction - compares two stored snapshots of the subject of the source, e.g. the url of a crawled page
+func (a *Application) sourceDiffAction(w http.ResponseWriter, r *http.Request) {
+	source := mux.Vars(r)["source"]
+	if _, ok := resultSources[source]; !ok {
+		errorResponse(w, http.StatusNotFound, "unknown source \""+source+"\"")
+		return
+	}
+	subject := r.URL.Query().Get("subject")
+	if len(subject) == 0 {
+		errorResponse(w, http.StatusUnprocessableEntity, "subject is required")
+		return
+	}
+	a.snapshotDiff(w, r, source, subject)
+}
+
+// snapshotDiff - compares the snapshots "from" and "to", which are the id of a stored result, the id of the job
+// that scraped it or a time naming the latest snapshot at that time. Without "to" the latest snapshot is used
+// and without "from" the one before "to".
+func (a *Application) snapshotDiff(w http.ResponseWriter, r *http.Request, source string, subject string) {
+	to, err := a.findSnapshot(source, subject, r.URL.Query().Get("to"), nil)
+	if err != nil {
+		snapshotErrorResponse(w, "to", err)
+		return
+	}
+	from, err := a.findSnapshot(source, subject, r.URL.Query().Get("from"), &to)
+	if err != nil {
+		snapshotErrorResponse(w, "from", err)
+		return
+	}
+	changes := diff.Compare(from.Data, to.Data, diff.IgnoreVolatile)
+
+	if strings.Contains(r.Header.Get("Accept"), jsonPatchContentType) {
+		w.Header().Set("Content-Type", jsonPatchContentType)
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(diff.Patch(changes))
+		if err != nil {
+			panic(err)
+		}
+		return
+	}
+	if strings.Contains(r.Header.Get("Accept"), "text/plain") {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		for _, change := range changes {
+			_, err = fmt.Fprintln(w, readableChange(change))
+			if err != nil {
+				return
+			}
+		}
+		return
+	}
+	list := []snapshotChange{}
+	counts := map[string]int{diff.ADDED: 0, diff.REMOVED: 0, diff.CHANGED: 0}
+	for _, change := range changes {
+		list = append(list, snapshotChange{
+			Field: change.Field(),
+			Op:    change.Op,
+			Old:   change.Old,
+			New:   change.New,
+		})
+		counts[change.Op]++
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"source":  source,
+		"subject": subject,
+		"from":    snapshotInfo(from),
+		"to":      snapshotInfo(to),
+		"summary": counts,
+		"changes": list,
+	})
+}
+
+var errSnapshotNotFound = errors.New("snapshot not found")
+
+// findSnapshot - the snapshot of the subject named by the reference, the latest one without a reference. Given
+// a later snapshot the latest snapshot before it is found instead, and references must name an earlier one.
+func (a *Application) findSnapshot(source string, subject string, reference string, later *models.ScrapeResult) (models.ScrapeResult, error) {
+	query := a.Database().Connection().Where("source = ? AND subject = ?", source, subject)
+	reference = strings.TrimSpace(reference)
+	if id, err := uuid.Parse(reference); err == nil {
+		query = query.Where("job_id = ?", id.String())
+	} else if id, err := strconv.ParseUint(reference, 10, 64); err == nil {
+		query = query.Where("id = ?", id)
+	} else if len(reference) > 0 {
+		at, err := parseExportTime(reference, true)
+		if err != nil {
+			return models.ScrapeResult{}, errors.New("expected a result id, job id, RFC 3339 time or date")
+		}
+		query = query.Where("scraped_at <= ?", *at)
+	}
+	if later != nil {
+		query = query.Where(
+			"scraped_at < ? OR (scraped_at = ? AND id < ?)",
+			later.ScrapedAt,
+			later.ScrapedAt,
+			later.ID,
+		)
+	}
+	var snapshot models.ScrapeResult
+	result := query.Order("scraped_at DESC").Order("id DESC").First(&snapshot)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return models.ScrapeResult{}, errSnapshotNotFound
+	}
+	if result.Error != nil {
+		panic(result.Error)
+	}
+	return snapshot, nil
+}
+
+func snapshotErrorResponse(w http.ResponseWriter, name string, err error) {
+	if errors.Is(err, errSnapshotNotFound) {
+		errorResponse(w, http.StatusNotFound, "\""+name+"\" snapshot not found")
+		return
+	}
+	errorResponse(w, http.StatusUnprocessableEntity, "invalid "+name+": "+err.Error())
+}
+
+func snapshotInfo(snapshot models.ScrapeResult) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         snapshot.ID,
+		"job_id":     snapshot.JobID,
+		"scraped_at": snapshot.ScrapedAt,
+	}
+}
+
+// readableChange - a line of the change list, e.g. "~ insurance.Selskab: Tryg -> Topdanmark"
+func readableChange(change diff.Change) string {
+	switch change.Op {
+	case diff.ADDED:
+		return "+ " + change.Field() + ": " + readableValue(change.New)
+	case diff.REMOVED:
+		return "- " + change.Field() + ": " + readableValue(change.Old)
+	}
+	return "~ " + change.Field() + ": " + readableValue(change.Old) + " -> " + readableValue(change.New)
+}
+
+func readableValue(value interface{}) string {
+	if text, ok := value.(string); ok {
+		return text
+	}
+	var encoded strings.Builder
+	encoder := json.NewEncoder(&encoded)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return fmt.Sprint(value)
+	}
+	return strings.TrimSuffix(encoded.String(), "\n")
+}
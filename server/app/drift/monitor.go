@@ -0,0 +1,189 @@
+package drift
+
+import (
+	"go-scrape-this/server/app/database"
+	"go-scrape-this/server/app/database/models"
+	"go-scrape-this/server/app/scrape/recipe"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	OK       = "ok"
+	DEGRADED = "degraded"
+)
+
+// FieldRate - how often a field was missing from the results of a source within the window
+type FieldRate struct {
+	Field       string  `json:"field"`
+	Required    bool    `json:"required"`
+	Checks      int     `json:"checks"`
+	Missing     int     `json:"missing"`
+	MissingRate float64 `json:"missing_rate"`
+}
+
+// SourceStatus - the drift of the results of a source from its schema
+type SourceStatus struct {
+	Source         string      `json:"source"`
+	Status         string      `json:"status"`
+	Since          time.Time   `json:"since"`
+	DegradedFields []string    `json:"degraded_fields"`
+	Fields         []FieldRate `json:"fields"`
+}
+
+// Listener - is called when a source becomes degraded or recovers
+type Listener func(status SourceStatus)
+
+// Monitor - counts the fields missing from the results of every source per hour. A source is degraded while
+// a required field is missing from at least the threshold of the results within the window.
+type Monitor struct {
+	db        *database.Database
+	window    time.Duration
+	threshold float64
+	minChecks int
+	// lock - guards the statuses only, the database is never queried while it is held
+	lock      sync.Mutex
+	degraded  map[string]bool
+	listeners []Listener
+}
+
+// NewMonitor - creates a new monitor, the threshold is the fraction of results that may lack a required field
+// and fields checked less than the minimum number of times within the window never degrade a source
+func NewMonitor(db *database.Database, window time.Duration, threshold float64, minChecks int) *Monitor {
+	return &Monitor{
+		db:        db,
+		window:    window,
+		threshold: threshold,
+		minChecks: minChecks,
+		degraded:  map[string]bool{},
+		listeners: []Listener{},
+	}
+}
+
+// OnChange - adds a listener called when the status of a source changes, listeners are added before the
+// first result is recorded and must not block
+func (m *Monitor) OnChange(listener Listener) {
+	m.listeners = append(m.listeners, listener)
+}
+
+// Record - counts the checks of a result of the source and updates the status of the source
+func (m *Monitor) Record(source string, checks []recipe.FieldCheck, checkedAt time.Time) error {
+	if len(checks) == 0 {
+		return nil
+	}
+	if !m.known(source) {
+		// the status before this result, so restarts don't repeat alerts of sources that were already degraded
+		status, err := m.Status(source)
+		if err != nil {
+			return err
+		}
+		m.lock.Lock()
+		if _, known := m.degraded[source]; !known {
+			m.degraded[source] = status.Status == DEGRADED
+		}
+		m.lock.Unlock()
+	}
+	hour := checkedAt.UTC().Truncate(time.Hour)
+	// every field is counted by an upsert, which takes the write lock of sqlite right away, so concurrent
+	// results wait for each other instead of failing to upgrade a read lock
+	err := m.db.Connection().Transaction(func(tx *gorm.DB) error {
+		for _, check := range checks {
+			missing := 0
+			if check.Missing {
+				missing = 1
+			}
+			result := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "source"}, {Name: "field"}, {Name: "hour"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"required": check.Required,
+					"checks":   gorm.Expr("schema_drift_stats.checks + ?", 1),
+					"missing":  gorm.Expr("schema_drift_stats.missing + ?", missing),
+				}),
+			}).Create(&models.SchemaDriftStat{
+				Source:   source,
+				Field:    check.Field,
+				Hour:     hour,
+				Required: check.Required,
+				Checks:   1,
+				Missing:  missing,
+			})
+			if result.Error != nil {
+				return result.Error
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	status, err := m.Status(source)
+	if err != nil {
+		return err
+	}
+	if m.changed(source, status.Status == DEGRADED) {
+		for _, listener := range m.listeners {
+			listener(status)
+		}
+	}
+	return nil
+}
+
+func (m *Monitor) known(source string) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	_, known := m.degraded[source]
+	return known
+}
+
+// changed - stores if the source is degraded, returns true if that changed
+func (m *Monitor) changed(source string, degraded bool) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.degraded[source] == degraded {
+		return false
+	}
+	m.degraded[source] = degraded
+	return true
+}
+
+// Status - the drift of the source within the window
+func (m *Monitor) Status(source string) (SourceStatus, error) {
+	since := time.Now().UTC().Add(-m.window).Truncate(time.Hour)
+	var rates []FieldRate
+	result := m.db.Connection().
+		Model(&models.SchemaDriftStat{}).
+		Select("field, required, SUM(checks) AS checks, SUM(missing) AS missing").
+		Where("source = ? AND hour >= ?", source, since).
+		Group("field, required").
+		Scan(&rates)
+	if result.Error != nil {
+		return SourceStatus{}, result.Error
+	}
+	output := SourceStatus{
+		Source:         source,
+		Status:         OK,
+		Since:          since,
+		DegradedFields: []string{},
+		Fields:         []FieldRate{},
+	}
+	sort.Slice(rates, func(i, j int) bool {
+		return rates[i].Field < rates[j].Field
+	})
+	for _, rate := range rates {
+		if rate.Checks > 0 {
+			rate.MissingRate = float64(rate.Missing) / float64(rate.Checks)
+		}
+		if rate.Required && rate.Checks >= m.minChecks && rate.MissingRate >= m.threshold {
+			output.DegradedFields = append(output.DegradedFields, rate.Field)
+		}
+		output.Fields = append(output.Fields, rate)
+	}
+	if len(output.DegradedFields) > 0 {
+		output.Status = DEGRADED
+	}
+	return output, nil
+}
@@ -0,0 +1,56 @@
+package drift
+
+import (
+	"github.com/rs/zerolog"
+	"go-scrape-this/server/app/database"
+	"go-scrape-this/server/app/scrape/recipe"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRecordAlertsOncePerChange(t *testing.T) {
+	logger := zerolog.Nop()
+	db, err := database.NewDatabase(database.SQLITE, filepath.Join(t.TempDir(), "test.db"), &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RunMigrations(); err != nil {
+		t.Fatal(err)
+	}
+	monitor := NewMonitor(&db, time.Hour*24, 0.5, 4)
+	changes := make(chan SourceStatus, 10)
+	monitor.OnChange(func(status SourceStatus) {
+		changes <- status
+	})
+
+	missing := []recipe.FieldCheck{{Field: "vehicle.vin", Required: true, Missing: true}}
+	var wait sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			if err := monitor.Record("dmr", missing, time.Now()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wait.Wait()
+	if len(changes) != 1 {
+		t.Fatalf("expected one change, got %d", len(changes))
+	}
+	if status := <-changes; status.Status != DEGRADED || len(status.DegradedFields) != 1 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	present := []recipe.FieldCheck{{Field: "vehicle.vin", Required: true}}
+	for i := 0; i < 5; i++ {
+		if err := monitor.Record("dmr", present, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(changes) != 1 || (<-changes).Status != OK {
+		t.Fatal("expected the source to recover once")
+	}
+}
@@ -0,0 +1,124 @@
+package app
+
+import (
+	"github.com/gorilla/mux"
+	"go-scrape-this/server/app/database/models"
+	"go-scrape-this/server/app/drift"
+	"go-scrape-this/server/app/scrape"
+	"go-scrape-this/server/app/utils"
+	"go-scrape-this/server/app/webhook"
+	"net/http"
+	"time"
+)
+
+// sourceRecipes - the recipe whose schema the results of a source are checked against
+var sourceRecipes = map[string]string{
+	scrape.DmrSource: scrape.DmrVehicleRecipe,
+}
+
+// checkLookupSchema - job listener that checks the results of found vehicles against the schema of their recipe
+func (a *Application) checkLookupSchema(job models.Job) {
+	if job.Type != vehicleLookupJobType || job.Status != models.JobSucceeded || job.Result["found"] != true {
+		return
+	}
+	a.checkSchema(scrape.DmrSource, job)
+}
+
+func (a *Application) checkSchema(source string, job models.Job) {
+	found, err := scrape.Recipes().Get(sourceRecipes[source])
+	if err != nil || found.Schema.IsEmpty() {
+		return
+	}
+	checkedAt := time.Now()
+	if job.FinishedAt != nil {
+		checkedAt = *job.FinishedAt
+	}
+	checks := found.Schema.Check(withoutImages(job.Result))
+	missing := []string{}
+	for _, check := range checks {
+		if check.Missing && check.Required {
+			missing = append(missing, check.Field)
+		}
+	}
+	if len(missing) > 0 {
+		a.DefaultLogger().Warn().
+			Str("source", source).
+			Str("job", job.ID.String()).
+			Strs("fields", missing).
+			Msg("result is missing required fields")
+	}
+	err = a.drift.Record(source, checks, checkedAt)
+	if err != nil {
+		a.DefaultLogger().Error().Err(err).Str("source", source).Msg("failed to record schema drift")
+	}
+}
+
+// alertSourceDrift - drift listener that logs and publishes sources becoming degraded or recovering
+func (a *Application) alertSourceDrift(status drift.SourceStatus) {
+	eventType := webhook.SOURCE_RECOVERED
+	if status.Status == drift.DEGRADED {
+		eventType = webhook.SOURCE_DEGRADED
+		a.DefaultLogger().Warn().
+			Str("source", status.Source).
+			Strs("fields", status.DegradedFields).
+			Msg("source is degraded, its results are missing required fields")
+	} else {
+		a.DefaultLogger().Info().Str("source", status.Source).Msg("source recovered")
+	}
+	a.webhooks.Publish(webhook.NewEvent(eventType, map[string]interface{}{
+		"source": status,
+	}))
+}
+
+// sourceStatuses - the drift of every source with a schema
+func (a *Application) sourceStatuses() (map[string]drift.SourceStatus, error) {
+	output := map[string]drift.SourceStatus{}
+	for source := range sourceRecipes {
+		status, err := a.drift.Status(source)
+		if err != nil {
+			return nil, err
+		}
+		output[source] = status
+	}
+	return output, nil
+}
+
+func (a *Application) sourceDriftAction(w http.ResponseWriter, r *http.Request) {
+	source := mux.Vars(r)["source"]
+	if _, found := sourceRecipes[source]; !found {
+		errorResponse(w, http.StatusNotFound, "source not found")
+		return
+	}
+	status, err := a.drift.Status(source)
+	if err != nil {
+		panic(err)
+	}
+	limit := utils.GetQueryIntOption(r, "limit", 100)
+	offset := utils.GetQueryIntOption(r, "offset", 0)
+	if limit > 1000 {
+		limit = 1000
+	}
+	hours := utils.GetQueryIntOption(r, "hours", 168)
+	since := time.Now().UTC().Add(-time.Hour * time.Duration(hours)).Truncate(time.Hour)
+	query := a.Database().Connection().
+		Model(&models.SchemaDriftStat{}).
+		Where("source = ? AND hour >= ?", source, since)
+	if field := r.URL.Query().Get("field"); len(field) > 0 {
+		query = query.Where("field = ?", field)
+	}
+	var stats []models.SchemaDriftStat
+	var count int64
+	query.Count(&count)
+	result := query.Order("hour DESC").Order("field").Limit(limit).Offset(offset).Find(&stats)
+	if result.Error != nil {
+		panic(result.Error)
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"status": status,
+		"data":   stats,
+		"total":  count,
+		"count":  len(stats),
+		"offset": offset,
+		"limit":  limit,
+	})
+}
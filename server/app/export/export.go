@@ -0,0 +1,182 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/xitongsys/parquet-go/writer"
+	"github.com/xuri/excelize/v2"
+	"go-scrape-this/server/app/utils"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// fixedColumns - the columns every columnar export starts with, the flattened fields of the results follow
+var fixedColumns = []string{"job_id", "source", "subject", "finished_at"}
+
+var parquetNamePattern = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// Record - a stored result, the subject is what was scraped, e.g. the vehicle or the url of a page
+type Record struct {
+	JobID      string                 `json:"job_id"`
+	Source     string                 `json:"source"`
+	Subject    string                 `json:"subject"`
+	FinishedAt time.Time              `json:"finished_at"`
+	Result     map[string]interface{} `json:"result"`
+}
+
+func (r Record) fixedValues() []string {
+	return []string{r.JobID, r.Source, r.Subject, r.FinishedAt.UTC().Format(time.RFC3339)}
+}
+
+// Records - calls the function with every record in order until it returns an error, columnar formats
+// iterate the records twice so they are never all held in memory
+type Records func(each func(Record) error) error
+
+// Write - writes the records in the format, returns the amount of records written
+func Write(format *Format, w io.Writer, records Records) (int, error) {
+	if !format.columnar {
+		return writeNDJSON(w, records)
+	}
+	columns, err := collectColumns(records)
+	if err != nil {
+		return 0, err
+	}
+	switch format {
+	case CSV:
+		return writeCSV(w, columns, records)
+	case XLSX:
+		return writeXLSX(w, columns, records)
+	case PARQUET:
+		return writeParquet(w, columns, records)
+	}
+	return 0, errors.New("unknown or unsupported export format")
+}
+
+func writeNDJSON(w io.Writer, records Records) (int, error) {
+	encoder := json.NewEncoder(w)
+	count := 0
+	err := records(func(record Record) error {
+		count++
+		return encoder.Encode(record)
+	})
+	return count, err
+}
+
+// collectColumns - the sorted names of every flattened field of the records
+func collectColumns(records Records) ([]string, error) {
+	names := map[string]bool{}
+	err := records(func(record Record) error {
+		for name := range utils.Flatten(record.Result, ".") {
+			names[name] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	output := []string{}
+	for name := range names {
+		output = append(output, name)
+	}
+	sort.Strings(output)
+	return output, nil
+}
+
+// eachRow - calls the function with the cells of every record, missing fields are empty
+func eachRow(columns []string, records Records, each func(cells []string) error) (int, error) {
+	count := 0
+	err := records(func(record Record) error {
+		count++
+		fields := utils.Flatten(record.Result, ".")
+		cells := append(record.fixedValues(), make([]string, len(columns))...)
+		for i, column := range columns {
+			cells[len(fixedColumns)+i] = fields[column]
+		}
+		return each(cells)
+	})
+	return count, err
+}
+
+func writeCSV(w io.Writer, columns []string, records Records) (int, error) {
+	csvWriter := csv.NewWriter(w)
+	err := csvWriter.Write(append(append([]string{}, fixedColumns...), columns...))
+	if err != nil {
+		return 0, err
+	}
+	count, err := eachRow(columns, records, csvWriter.Write)
+	if err != nil {
+		return count, err
+	}
+	csvWriter.Flush()
+	return count, csvWriter.Error()
+}
+
+func writeXLSX(w io.Writer, columns []string, records Records) (int, error) {
+	file := excelize.NewFile()
+	defer file.Close()
+	stream, err := file.NewStreamWriter(file.GetSheetName(0))
+	if err != nil {
+		return 0, err
+	}
+	row := 1
+	setRow := func(cells []string) error {
+		cell, err := excelize.CoordinatesToCellName(1, row)
+		if err != nil {
+			return err
+		}
+		values := make([]interface{}, len(cells))
+		for i := range cells {
+			values[i] = cells[i]
+		}
+		row++
+		return stream.SetRow(cell, values)
+	}
+	err = setRow(append(append([]string{}, fixedColumns...), columns...))
+	if err != nil {
+		return 0, err
+	}
+	count, err := eachRow(columns, records, setRow)
+	if err != nil {
+		return count, err
+	}
+	err = stream.Flush()
+	if err != nil {
+		return count, err
+	}
+	return count, file.Write(w)
+}
+
+// writeParquet - writes every column as an optional UTF8 string, the names are reduced to the characters
+// parquet schemas accept
+func writeParquet(w io.Writer, columns []string, records Records) (int, error) {
+	metadata := []string{}
+	used := map[string]bool{}
+	for _, column := range append(append([]string{}, fixedColumns...), columns...) {
+		name := parquetNamePattern.ReplaceAllString(column, "_")
+		for suffix := 2; used[name]; suffix++ {
+			name = parquetNamePattern.ReplaceAllString(column, "_") + "_" + strconv.Itoa(suffix)
+		}
+		used[name] = true
+		metadata = append(metadata, fmt.Sprintf("name=%s, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL", name))
+	}
+	parquetWriter, err := writer.NewCSVWriterFromWriter(metadata, w, 1)
+	if err != nil {
+		return 0, err
+	}
+	count, err := eachRow(columns, records, func(cells []string) error {
+		values := make([]*string, len(cells))
+		for i := range cells {
+			values[i] = &cells[i]
+		}
+		return parquetWriter.WriteString(values)
+	})
+	if err != nil {
+		return count, err
+	}
+	return count, parquetWriter.WriteStop()
+}
@@ -0,0 +1,63 @@
+package export
+
+import (
+	"errors"
+	"strings"
+)
+
+type Format struct {
+	value       string
+	extension   string
+	contentType string
+	// columnar - the format has a fixed set of columns, which are collected in a first pass over the records
+	columnar bool
+}
+
+func (f Format) String() string {
+	return f.value
+}
+
+func (f Format) Extension() string {
+	return f.extension
+}
+
+func (f Format) ContentType() string {
+	return f.contentType
+}
+
+func ParseFormat(value string) (*Format, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	for _, format := range formats {
+		if format.String() == value {
+			return format, nil
+		}
+	}
+	return nil, errors.New("unknown or unsupported export format")
+}
+
+var (
+	CSV = &Format{
+		value:       "csv",
+		extension:   ".csv",
+		contentType: "text/csv",
+		columnar:    true,
+	}
+	NDJSON = &Format{
+		value:       "ndjson",
+		extension:   ".ndjson",
+		contentType: "application/x-ndjson",
+	}
+	XLSX = &Format{
+		value:       "xlsx",
+		extension:   ".xlsx",
+		contentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		columnar:    true,
+	}
+	PARQUET = &Format{
+		value:       "parquet",
+		extension:   ".parquet",
+		contentType: "application/vnd.apache.parquet",
+		columnar:    true,
+	}
+	formats = []*Format{CSV, NDJSON, XLSX, PARQUET}
+)
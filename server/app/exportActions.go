@@ -0,0 +1,372 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+	"go-scrape-this/server/app/database/models"
+	"go-scrape-this/server/app/database/structs"
+	"go-scrape-this/server/app/export"
+	"go-scrape-this/server/app/jobs"
+	"go-scrape-this/server/app/scrape"
+	"gorm.io/gorm"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const exportJobType = "export"
+
+// resultSource - the jobs storing the results of a source and the input naming what was scraped
+type resultSource struct {
+	jobType string
+	subject string
+}
+
+// resultSources - the sources whose results are exported and searched
+var resultSources = map[string]resultSource{
+	scrape.DmrSource: {jobType: vehicleLookupJobType, subject: "value"},
+	"crawl":          {jobType: crawlPageJobType, subject: "url"},
+	"adhoc":          {jobType: adhocScrapeJobType, subject: "url"},
+}
+
+// exportFilter - selects the stored results of a source, the vehicles only apply to vehicle lookups
+type exportFilter struct {
+	Source   string     `json:"source"`
+	From     *time.Time `json:"from,omitempty"`
+	To       *time.Time `json:"to,omitempty"`
+	Vehicles []string   `json:"vehicles,omitempty"`
+}
+
+type exportRequest struct {
+	Format    string   `json:"format"`
+	Source    string   `json:"source"`
+	From      string   `json:"from"`
+	To        string   `json:"to"`
+	Vehicles  []string `json:"vehicles"`
+	Watchlist string   `json:"watchlist"`
+}
+
+// exportRequestFromQuery - reads the request from the query of a synchronous export, vehicles are comma separated
+func exportRequestFromQuery(r *http.Request) exportRequest {
+	query := r.URL.Query()
+	request := exportRequest{
+		Format:    query.Get("format"),
+		Source:    query.Get("source"),
+		From:      query.Get("from"),
+		To:        query.Get("to"),
+		Watchlist: query.Get("watchlist"),
+	}
+	for _, vehicle := range strings.Split(query.Get("vehicles"), ",") {
+		if len(strings.TrimSpace(vehicle)) > 0 {
+			request.Vehicles = append(request.Vehicles, vehicle)
+		}
+	}
+	return request
+}
+
+// parseExportRequest - validates the request, the vehicles of a watchlist are added to the vehicle set
+func (a *Application) parseExportRequest(request exportRequest) (*export.Format, exportFilter, error) {
+	if len(request.Format) == 0 {
+		request.Format = export.NDJSON.String()
+	}
+	format, err := export.ParseFormat(request.Format)
+	if err != nil {
+		return nil, exportFilter{}, err
+	}
+	if len(request.Source) == 0 {
+		request.Source = scrape.DmrSource
+	}
+	if _, ok := resultSources[request.Source]; !ok {
+		return nil, exportFilter{}, errors.New("unknown source \"" + request.Source + "\"")
+	}
+	filter := exportFilter{Source: request.Source}
+	filter.From, err = parseExportTime(request.From, false)
+	if err != nil {
+		return nil, exportFilter{}, fmt.Errorf("invalid from: %w", err)
+	}
+	filter.To, err = parseExportTime(request.To, true)
+	if err != nil {
+		return nil, exportFilter{}, fmt.Errorf("invalid to: %w", err)
+	}
+	vehicles := request.Vehicles
+	if len(request.Watchlist) > 0 {
+		id, err := uuid.Parse(request.Watchlist)
+		if err != nil {
+			return nil, exportFilter{}, errors.New("invalid watchlist id")
+		}
+		var watched []models.WatchlistVehicle
+		result := a.Database().Connection().Where("watchlist_id = ?", id.String()).Find(&watched)
+		if result.Error != nil {
+			panic(result.Error)
+		}
+		if len(watched) == 0 {
+			return nil, exportFilter{}, errors.New("watchlist not found or empty")
+		}
+		for _, vehicle := range watched {
+			vehicles = append(vehicles, vehicle.Value)
+		}
+	}
+	if len(vehicles) > 0 && request.Source != scrape.DmrSource {
+		return nil, exportFilter{}, errors.New("a vehicle set only applies to the \"" + scrape.DmrSource + "\" source")
+	}
+	for _, vehicle := range vehicles {
+		filter.Vehicles = append(filter.Vehicles, normalizeExportVehicle(vehicle))
+	}
+	return format, filter, nil
+}
+
+// parseExportTime - parses a RFC 3339 time or a date, a date ending a range includes the whole day
+func parseExportTime(value string, end bool) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if len(value) == 0 {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return &parsed, nil
+	}
+	parsed, err = time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, errors.New("expected a RFC 3339 time or a date")
+	}
+	if end {
+		parsed = parsed.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return &parsed, nil
+}
+
+func normalizeExportVehicle(value string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(value), " ", ""))
+}
+
+// exportQuery - the succeeded jobs of the source in the date range and of the vehicle set, the subject is read
+// from the job input the way normalizeExportVehicle normalizes it
+func (a *Application) exportQuery(filter exportFilter) *gorm.DB {
+	source := resultSources[filter.Source]
+	db := a.Database().Connection()
+	query := db.
+		Model(&models.Job{}).
+		Where("type = ? AND status = ?", source.jobType, models.JobSucceeded)
+	if len(filter.Vehicles) > 0 {
+		subject := structs.JSONString(db, "input", source.subject)
+		query = query.Where("UPPER(REPLACE("+subject+", ' ', '')) IN ?", filter.Vehicles)
+	}
+	if filter.From != nil {
+		query = query.Where("finished_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("finished_at <= ?", *filter.To)
+	}
+	return query
+}
+
+// exportRecords - streams the results matching the filter from the database, the screenshots are left out
+func (a *Application) exportRecords(filter exportFilter) export.Records {
+	source := resultSources[filter.Source]
+	return func(each func(export.Record) error) error {
+		query := a.exportQuery(filter)
+		rows, err := query.Order("finished_at").Order("id").Rows()
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var job models.Job
+			err = query.ScanRows(rows, &job)
+			if err != nil {
+				return err
+			}
+			subject, _ := job.Input[source.subject].(string)
+			record := export.Record{
+				JobID:   job.ID.String(),
+				Source:  filter.Source,
+				Subject: subject,
+				Result:  withoutImages(job.Result),
+			}
+			if job.FinishedAt != nil {
+				record.FinishedAt = *job.FinishedAt
+			}
+			err = each(record)
+			if err != nil {
+				return err
+			}
+		}
+		return rows.Err()
+	}
+}
+
+// exportResultsAction - streams the results matching the filter in the response, exports larger than the
+// synchronous limit have to run as a job
+func (a *Application) exportResultsAction(w http.ResponseWriter, r *http.Request) {
+	format, filter, err := a.parseExportRequest(exportRequestFromQuery(r))
+	if err != nil {
+		errorResponse(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	var count int64
+	result := a.exportQuery(filter).Count(&count)
+	if result.Error != nil {
+		panic(result.Error)
+	}
+	if count > int64(a.maxExportRows) {
+		errorResponse(
+			w,
+			http.StatusUnprocessableEntity,
+			"more than "+strconv.Itoa(a.maxExportRows)+" results match, submit the export as a job with POST /api/exports",
+		)
+		return
+	}
+	filename := filter.Source + "-" + time.Now().UTC().Format("20060102-150405") + format.Extension()
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	_, err = export.Write(format, w, a.exportRecords(filter))
+	if err != nil {
+		// the response has already begun, the client sees a truncated file
+		a.DefaultLogger().Error().Err(err).Str("source", filter.Source).Msg("failed to stream export")
+	}
+}
+
+// exportRunner - writes the export to a file in the export directory, the file is only renamed into place
+// when it is complete
+func (a *Application) exportRunner(format *export.Format, filter exportFilter) jobs.Runner {
+	return func(logger *zerolog.Logger) (map[string]interface{}, error) {
+		filename := uuid.New().String() + format.Extension()
+		path := filepath.Join(a.exportDir, filename)
+		file, err := os.CreateTemp(a.exportDir, ".export-*")
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(file.Name())
+		rows, err := export.Write(format, file, a.exportRecords(filter))
+		if err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+		err = file.Close()
+		if err != nil {
+			return nil, err
+		}
+		err = os.Rename(file.Name(), path)
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info().Str("file", filename).Int("rows", rows).Msg("wrote export")
+		return map[string]interface{}{
+			"file":   filename,
+			"format": format.String(),
+			"rows":   rows,
+			"size":   info.Size(),
+		}, nil
+	}
+}
+
+// exportCreateAction - submits an export job, the file is downloaded from /api/exports/{id}/download when the
+// job has succeeded
+func (a *Application) exportCreateAction(w http.ResponseWriter, r *http.Request) {
+	var request exportRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	format, filter, err := a.parseExportRequest(request)
+	if err != nil {
+		errorResponse(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	input := map[string]interface{}{}
+	encoded, err := json.Marshal(filter)
+	if err == nil {
+		err = json.Unmarshal(encoded, &input)
+	}
+	if err != nil {
+		panic(err)
+	}
+	input["format"] = format.String()
+	job, err := a.jobs.Submit(exportJobType, input, a.exportRunner(format, filter))
+	if err != nil {
+		submitErrorResponse(w, err)
+		return
+	}
+	job, _, err = a.jobs.Wait(job.ID, a.maxLookupWait)
+	if err != nil {
+		panic(err)
+	}
+	jobResponse(w, job)
+}
+
+func (a *Application) exportDownloadAction(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "invalid export id")
+		return
+	}
+	var job models.Job
+	result := a.Database().Connection().First(&job, "id = ? AND type = ?", id.String(), exportJobType)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		errorResponse(w, http.StatusNotFound, "export not found")
+		return
+	}
+	if result.Error != nil {
+		panic(result.Error)
+	}
+	if !job.IsFinished() {
+		w.Header().Set("Location", "/api/jobs/"+job.ID.String())
+		errorResponse(w, http.StatusConflict, "export is "+job.Status)
+		return
+	}
+	filename, _ := job.Result["file"].(string)
+	formatName, _ := job.Result["format"].(string)
+	format, err := export.ParseFormat(formatName)
+	if job.Status != models.JobSucceeded || err != nil || len(filename) == 0 {
+		errorResponse(w, http.StatusConflict, "export failed")
+		return
+	}
+	file, err := os.Open(filepath.Join(a.exportDir, filepath.Base(filename)))
+	if errors.Is(err, os.ErrNotExist) {
+		errorResponse(w, http.StatusGone, "export file has expired")
+		return
+	}
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+	source, _ := job.Input["source"].(string)
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(
+		"attachment; filename=%q",
+		source+"-"+job.FinishedAt.UTC().Format("20060102-150405")+format.Extension(),
+	))
+	http.ServeContent(w, r, "", *job.FinishedAt, file)
+}
+
+// pruneExports - removes export files older than the retention, the jobs keep pointing at them
+func (a *Application) pruneExports() {
+	entries, err := os.ReadDir(a.exportDir)
+	if err != nil {
+		a.DefaultLogger().Error().Err(err).Msg("failed to list export files")
+		return
+	}
+	expired := time.Now().Add(-a.exportRetention)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || info.IsDir() || info.ModTime().After(expired) {
+			continue
+		}
+		err = os.Remove(filepath.Join(a.exportDir, entry.Name()))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			a.DefaultLogger().Error().Err(err).Str("file", entry.Name()).Msg("failed to remove expired export")
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"go-scrape-this/server/app/database/models"
+	"go-scrape-this/server/app/database/structs"
+	"go-scrape-this/server/app/scrape"
+	"go-scrape-this/server/app/utils"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+// recordVehicleHistory - job listener that stores the history events of found vehicles, events seen before
+// only get their last seen time updated
+func (a *Application) recordVehicleHistory(job models.Job) {
+	if job.Type != vehicleLookupJobType || job.Status != models.JobSucceeded || job.Result["found"] != true {
+		return
+	}
+	query, err := scrape.VehicleQueryFromMap(job.Input)
+	if err != nil {
+		return
+	}
+	events := scrape.VehicleHistory(job.Result)
+	if len(events) == 0 {
+		return
+	}
+	seenAt := time.Now()
+	if job.FinishedAt != nil {
+		seenAt = *job.FinishedAt
+	}
+	vin := scrape.VehicleVin(job.Result)
+	err = a.Database().Connection().Transaction(func(tx *gorm.DB) error {
+		for _, event := range events {
+			details := structs.JSONMap{}
+			for label, value := range event.Details {
+				details[label] = value
+			}
+			stored := models.VehicleHistoryEvent{
+				SearchType: query.SearchType.String(),
+				Value:      query.Value,
+				Hash:       historyEventHash(event),
+			}
+			updates := models.VehicleHistoryEvent{LastSeenAt: seenAt, Vin: vin}
+			result := tx.Where(stored).
+				Attrs(models.VehicleHistoryEvent{
+					Tab:         event.Tab,
+					Type:        event.Type,
+					OccurredOn:  event.Date,
+					Details:     details,
+					JobID:       job.ID,
+					FirstSeenAt: seenAt,
+				}).
+				Assign(updates).
+				FirstOrCreate(&stored)
+			if result.Error != nil {
+				return result.Error
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		a.DefaultLogger().Error().Err(err).Str("job", job.ID.String()).Msg("failed to store vehicle history")
+	}
+}
+
+// historyEventHash - identifies an event by its content, the same event scraped again has the same hash
+func historyEventHash(event scrape.HistoryEvent) string {
+	content, _ := json.Marshal(event)
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func (a *Application) vehicleHistoryAction(w http.ResponseWriter, r *http.Request) {
+	searchType, err := scrape.ParseSearchType(mux.Vars(r)["search_type"])
+	if err != nil {
+		errorResponse(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	value, err := searchType.Normalize(mux.Vars(r)["value"])
+	if err != nil {
+		errorResponse(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	limit := utils.GetQueryIntOption(r, "limit", 50)
+	offset := utils.GetQueryIntOption(r, "offset", 0)
+	if limit > 500 {
+		limit = 500
+	}
+	query := a.Database().Connection().Model(&models.VehicleHistoryEvent{}).Where(a.vehicleHistoryScope(searchType, value))
+	if searchType == scrape.VIN {
+		// events of lookups by other search types are found by the VIN they were scraped with, an event
+		// stored by lookups of both kinds is listed once, as it was first stored
+		query = query.Where("id IN (?)", a.Database().Connection().
+			Model(&models.VehicleHistoryEvent{}).
+			Select("MIN(id)").
+			Where(a.vehicleHistoryScope(searchType, value)).
+			Group("hash"))
+	}
+	if eventType := r.URL.Query().Get("type"); len(eventType) > 0 {
+		query = query.Where("type = ?", eventType)
+	}
+	var events []models.VehicleHistoryEvent
+	var count int64
+	result := query.Count(&count)
+	if result.Error != nil {
+		panic(result.Error)
+	}
+	result = query.Order("occurred_on DESC").Order("id").Limit(limit).Offset(offset).Find(&events)
+	if result.Error != nil {
+		panic(result.Error)
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"data":   events,
+		"total":  count,
+		"count":  len(events),
+		"offset": offset,
+		"limit":  limit,
+	})
+}
+
+// vehicleHistoryScope - the conditions matching the stored events of the vehicle
+func (a *Application) vehicleHistoryScope(searchType *scrape.SearchType, value string) *gorm.DB {
+	vehicle := a.Database().Connection().Where("search_type = ? AND value = ?", searchType.String(), value)
+	if searchType == scrape.VIN {
+		vehicle = vehicle.Or("vin = ?", value)
+	}
+	return vehicle
+}
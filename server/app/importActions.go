@@ -0,0 +1,334 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/samber/lo"
+	"go-scrape-this/server/app/cache"
+	"go-scrape-this/server/app/database/models"
+	"go-scrape-this/server/app/jobs"
+	"go-scrape-this/server/app/scrape"
+	"go-scrape-this/server/app/scrape/recipe"
+	"go-scrape-this/server/app/spreadsheet"
+	"go-scrape-this/server/app/utils"
+	"golang.org/x/exp/maps"
+	"gorm.io/gorm"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var importContentTypes = []string{
+	"multipart/form-data",
+}
+
+func (a *Application) vehicleImportAction(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, a.maxImportSize)
+	err := r.ParseMultipartForm(a.maxImportSize)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "invalid or too large upload")
+		return
+	}
+	file, fileHeader, err := r.FormFile("file")
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "missing upload field \"file\"")
+		return
+	}
+	defer file.Close()
+
+	format, err := spreadsheet.FormatFromFilename(fileHeader.Filename)
+	if len(r.FormValue("format")) > 0 {
+		format, err = spreadsheet.ParseFormat(r.FormValue("format"))
+	}
+	if err != nil {
+		errorResponse(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	var searchType *scrape.SearchType
+	if len(r.FormValue("search_type")) > 0 {
+		searchType, err = scrape.ParseSearchType(r.FormValue("search_type"))
+		if err != nil {
+			errorResponse(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+	}
+	tabs, err := scrape.ParseVehicleTabs(strings.Split(r.FormValue("tabs"), ","))
+	if err != nil {
+		errorResponse(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	rows, err := spreadsheet.Read(format, file)
+	if err != nil {
+		errorResponse(w, http.StatusUnprocessableEntity, "failed to read spreadsheet: "+err.Error())
+		return
+	}
+	column, err := scrape.DetectIdentifierColumn(rows, searchType, r.FormValue("column"))
+	if err != nil {
+		errorResponse(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	var header []string
+	if column.HasHeader {
+		header = rows[0]
+		rows = rows[1:]
+	}
+	if len(rows) > a.maxImportRows {
+		errorResponse(w, http.StatusUnprocessableEntity, "spreadsheet contains more than "+strconv.Itoa(a.maxImportRows)+" rows")
+		return
+	}
+
+	batch := models.NewImportBatch(
+		filepath.Base(fileHeader.Filename),
+		format.String(),
+		column.SearchType.String(),
+		column.Index,
+		header,
+		scrape.VehicleQuery{Tabs: tabs}.TabNames(),
+	)
+	db := a.Database().Connection()
+	result := db.Create(&batch)
+	if result.Error != nil {
+		panic(result.Error)
+	}
+
+	importRows := []models.ImportRow{}
+	submitted := map[string]*uuid.UUID{}
+	for i, cells := range rows {
+		if len(strings.TrimSpace(strings.Join(cells, ""))) == 0 {
+			continue
+		}
+		row := models.ImportRow{
+			BatchID: batch.ID,
+			Line:    i + 1,
+			Cells:   cells,
+		}
+		if column.HasHeader {
+			row.Line++
+		}
+		if column.Index < len(cells) {
+			row.Value = strings.TrimSpace(cells[column.Index])
+		}
+		query, err := scrape.NewVehicleQuery(column.SearchType.String(), row.Value, batch.Tabs)
+		if err != nil {
+			// cells of any length are rejected here, they are cut to fit their columns
+			row.Status = models.ImportRowInvalid
+			row.Value = utils.TruncateText(row.Value, models.ImportRowValueSize)
+			row.Error = utils.TruncateText(err.Error(), models.ImportRowErrorSize)
+			batch.Invalid++
+			importRows = append(importRows, row)
+			continue
+		}
+		row.Value = query.Value
+		if jobId, found := submitted[query.Value]; found {
+			row.Status = models.ImportRowDuplicate
+			row.JobID = jobId
+			batch.Duplicates++
+			importRows = append(importRows, row)
+			continue
+		}
+		job, _, err := a.lookupVehicle(query, cache.Directives{})
+		if errors.Is(err, jobs.ErrQueueFull) {
+			row.Status = models.ImportRowInvalid
+			row.Error = err.Error()
+			batch.Invalid++
+			importRows = append(importRows, row)
+			continue
+		}
+		if err != nil {
+			panic(err)
+		}
+		row.Status = models.ImportRowQueued
+		row.JobID = &job.ID
+		submitted[query.Value] = &job.ID
+		batch.Queued++
+		importRows = append(importRows, row)
+	}
+	batch.Total = len(importRows)
+
+	if len(importRows) > 0 {
+		result = db.CreateInBatches(&importRows, 100)
+		if result.Error != nil {
+			panic(result.Error)
+		}
+	}
+	result = db.Save(&batch)
+	if result.Error != nil {
+		panic(result.Error)
+	}
+
+	w.Header().Set("Location", "/api/imports/"+batch.ID.String())
+	jsonResponse(w, http.StatusAccepted, map[string]interface{}{
+		"batch":    batch,
+		"rejected": rejectedRows(importRows),
+	})
+}
+
+func (a *Application) importAction(w http.ResponseWriter, r *http.Request) {
+	batch, found := a.findImportBatch(w, r)
+	if !found {
+		return
+	}
+	type statusCount struct {
+		Status string
+		Count  int
+	}
+	var counts []statusCount
+	result := a.Database().Connection().
+		Model(&models.ImportRow{}).
+		Select("jobs.status AS status, COUNT(*) AS count").
+		Joins("JOIN jobs ON jobs.id = import_rows.job_id").
+		Where("import_rows.batch_id = ? AND import_rows.status = ?", batch.ID.String(), models.ImportRowQueued).
+		Group("jobs.status").
+		Scan(&counts)
+	if result.Error != nil {
+		panic(result.Error)
+	}
+	jobs := map[string]int{}
+	for _, count := range counts {
+		jobs[count.Status] = count.Count
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"batch": batch,
+		"jobs":  jobs,
+	})
+}
+
+func (a *Application) importResultAction(w http.ResponseWriter, r *http.Request) {
+	batch, found := a.findImportBatch(w, r)
+	if !found {
+		return
+	}
+	format, err := spreadsheet.ParseFormat(batch.Format)
+	if len(r.URL.Query().Get("format")) > 0 {
+		format, err = spreadsheet.ParseFormat(r.URL.Query().Get("format"))
+	}
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	db := a.Database().Connection()
+	var importRows []models.ImportRow
+	result := db.Where("batch_id = ?", batch.ID.String()).Order("line").Find(&importRows)
+	if result.Error != nil {
+		panic(result.Error)
+	}
+	jobIds := []string{}
+	for _, row := range importRows {
+		if row.JobID != nil && row.Status == models.ImportRowQueued {
+			jobIds = append(jobIds, row.JobID.String())
+		}
+	}
+	jobs := map[uuid.UUID]models.Job{}
+	for _, chunk := range lo.Chunk(jobIds, 500) {
+		var found []models.Job
+		result = db.Where("id IN ?", chunk).Find(&found)
+		if result.Error != nil {
+			panic(result.Error)
+		}
+		for _, job := range found {
+			jobs[job.ID] = job
+		}
+	}
+
+	width := len(batch.Header)
+	for _, row := range importRows {
+		if len(row.Cells) > width {
+			width = len(row.Cells)
+		}
+	}
+	fields := map[uuid.UUID]map[string]string{}
+	fieldNames := map[string]bool{}
+	for id, job := range jobs {
+		fields[id] = utils.Flatten(withoutImages(job.Result), ".")
+		for name := range fields[id] {
+			fieldNames[name] = true
+		}
+	}
+	columns := maps.Keys(fieldNames)
+	sort.Strings(columns)
+
+	header := make([]string, width)
+	for i := range header {
+		if i < len(batch.Header) {
+			header[i] = batch.Header[i]
+		} else {
+			header[i] = "column " + strconv.Itoa(i+1)
+		}
+	}
+	header = append(header, "import_status", "lookup_status", "lookup_error")
+	output := [][]string{append(header, columns...)}
+	for _, row := range importRows {
+		cells := make([]string, width, len(header)+len(columns))
+		copy(cells, row.Cells)
+		cells = append(cells, row.Status)
+		job, hasJob := models.Job{}, false
+		if row.JobID != nil {
+			job, hasJob = jobs[*row.JobID]
+		}
+		if !hasJob {
+			cells = append(cells, "", row.Error)
+			output = append(output, append(cells, make([]string, len(columns))...))
+			continue
+		}
+		cells = append(cells, job.Status, job.Error)
+		for _, column := range columns {
+			cells = append(cells, fields[job.ID][column])
+		}
+		output = append(output, cells)
+	}
+
+	filename := strings.TrimSuffix(batch.Filename, filepath.Ext(batch.Filename)) + "-result" + format.Extension()
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	err = spreadsheet.Write(format, w, output)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (a *Application) findImportBatch(w http.ResponseWriter, r *http.Request) (models.ImportBatch, bool) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "invalid import id")
+		return models.ImportBatch{}, false
+	}
+	var batch models.ImportBatch
+	result := a.Database().Connection().First(&batch, "id = ?", id.String())
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		errorResponse(w, http.StatusNotFound, "import not found")
+		return models.ImportBatch{}, false
+	}
+	if result.Error != nil {
+		panic(result.Error)
+	}
+	return batch, true
+}
+
+func rejectedRows(rows []models.ImportRow) []models.ImportRow {
+	output := []models.ImportRow{}
+	for _, row := range rows {
+		if row.Status != models.ImportRowQueued {
+			output = append(output, row)
+		}
+	}
+	return output
+}
+
+// withoutImages - removes the base64 encoded screenshots and their formats from a vehicle result
+func withoutImages(result map[string]interface{}) map[string]interface{} {
+	output := map[string]interface{}{}
+	for key, value := range result {
+		if !strings.HasSuffix(key, "_image") && key != recipe.ScreenshotsKey {
+			output[key] = value
+		}
+	}
+	return output
+}
@@ -0,0 +1,134 @@
+package app
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+	"go-scrape-this/server/app/database/models"
+	"go-scrape-this/server/app/jobs"
+	"go-scrape-this/server/app/scrape/har"
+	"go-scrape-this/server/app/utils"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+func (a *Application) jobAction(w http.ResponseWriter, r *http.Request) {
+	job, ok := a.findJob(w, r)
+	if !ok {
+		return
+	}
+	if utils.GetQueryBoolOption(r, "typed", false) {
+		job = typedLookup(job)
+	}
+	jsonResponse(w, http.StatusOK, job)
+}
+
+// jobDiagnosticsAction - downloads the diagnostics bundle of a failed job as a zip
+func (a *Application) jobDiagnosticsAction(w http.ResponseWriter, r *http.Request) {
+	job, ok := a.findJob(w, r)
+	if !ok {
+		return
+	}
+	artifacts, err := a.jobs.Artifacts(job.ID, models.ArtifactDiagnostics)
+	if err != nil {
+		panic(err)
+	}
+	if len(artifacts) == 0 {
+		errorResponse(w, http.StatusNotFound, "job has no diagnostics")
+		return
+	}
+	zipArtifacts(w, "job-"+job.ID.String()+"-diagnostics.zip", artifacts)
+}
+
+// jobHARAction - downloads the HAR capture of a job, a job with several sessions, like a retried lookup, is
+// downloaded as a zip of their files
+func (a *Application) jobHARAction(w http.ResponseWriter, r *http.Request) {
+	job, ok := a.findJob(w, r)
+	if !ok {
+		return
+	}
+	artifacts, err := a.jobs.Artifacts(job.ID, models.ArtifactHAR)
+	if err != nil {
+		panic(err)
+	}
+	if len(artifacts) == 0 {
+		errorResponse(w, http.StatusNotFound, "job has no HAR capture")
+		return
+	}
+	if len(artifacts) > 1 {
+		zipArtifacts(w, "job-"+job.ID.String()+"-har.zip", artifacts)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "job-"+job.ID.String()+".har"))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(artifacts[0].Data)
+}
+
+// attachHAR - stores the capture of a session with the job, failing to do so does not fail the scrape
+func (a *Application) attachHAR(logger *zerolog.Logger, attach jobs.Attach, recorder *har.Recorder, session int) {
+	data, err := recorder.Encode()
+	if err == nil {
+		err = attach(models.ArtifactHAR, map[string][]byte{fmt.Sprintf("session-%d.har", session): data})
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to store HAR capture")
+		return
+	}
+	logger.Info().Int("entries", recorder.Len()).Int("session", session).Msg("stored HAR capture")
+}
+
+// zipArtifacts - responds with the artifacts as a zip download
+func zipArtifacts(w http.ResponseWriter, filename string, artifacts []models.JobArtifact) {
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	archive := zip.NewWriter(w)
+	for _, artifact := range artifacts {
+		file, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     artifact.Name,
+			Method:   zip.Deflate,
+			Modified: artifact.CreatedAt,
+		})
+		if err != nil {
+			panic(err)
+		}
+		_, err = file.Write(artifact.Data)
+		if err != nil {
+			panic(err)
+		}
+	}
+	err := archive.Close()
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (a *Application) findJob(w http.ResponseWriter, r *http.Request) (models.Job, bool) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "invalid job id")
+		return models.Job{}, false
+	}
+	job, err := a.jobs.Get(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		errorResponse(w, http.StatusNotFound, "job not found")
+		return models.Job{}, false
+	}
+	if err != nil {
+		panic(err)
+	}
+	return job, true
+}
+
+// jobResponse - responds with the job, unfinished jobs are answered as accepted with a link to poll
+func jobResponse(w http.ResponseWriter, job models.Job) {
+	if !job.IsFinished() {
+		w.Header().Set("Location", "/api/jobs/"+job.ID.String())
+		jsonResponse(w, http.StatusAccepted, job)
+		return
+	}
+	jsonResponse(w, http.StatusOK, job)
+}
@@ -0,0 +1,91 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"go-scrape-this/server/app/database/models"
+	"go-scrape-this/server/app/utils"
+	"time"
+)
+
+const maxErrorLength = 1024
+
+// ArtifactCarrier - an error carrying files that are stored with the failed job as its diagnostics bundle
+type ArtifactCarrier interface {
+	Artifacts() map[string][]byte
+}
+
+// Classified - an error knowing its kind, the kind is stored with the failed job
+type Classified interface {
+	ErrorKind() string
+}
+
+// Runner - the work done by a tracked job, the returned map is stored as the result of the job
+type Runner func(logger *zerolog.Logger) (map[string]interface{}, error)
+
+// Attach - stores files of the given kind with the running job, whether it succeeds or fails
+type Attach func(kind string, files map[string][]byte) error
+
+// AttachingRunner - a runner knowing the id of its job, it can store files with the job while it runs
+type AttachingRunner func(logger *zerolog.Logger, id uuid.UUID, attach Attach) (map[string]interface{}, error)
+
+type trackedJob struct {
+	record  models.Job
+	runner  Runner
+	tracker *Tracker
+}
+
+func (j *trackedJob) ID() uuid.UUID {
+	return j.record.ID
+}
+
+func (j *trackedJob) Process(logger *zerolog.Logger) {
+	j.tracker.started()
+	j.record.Status = models.JobProcessing
+	if err := j.tracker.update(&j.record); err != nil {
+		logger.Error().Err(err).Msg("failed to update job state")
+	}
+
+	result, err := j.runner(logger)
+	if err != nil {
+		var carrier ArtifactCarrier
+		if errors.As(err, &carrier) && len(carrier.Artifacts()) > 0 {
+			if storeErr := j.tracker.storeArtifacts(j.record.ID, models.ArtifactDiagnostics, carrier.Artifacts()); storeErr != nil {
+				logger.Error().Err(storeErr).Msg("failed to store job diagnostics")
+			} else {
+				j.record.Diagnostics = true
+			}
+		}
+		var classified Classified
+		if errors.As(err, &classified) {
+			j.record.ErrorKind = classified.ErrorKind()
+		}
+		j.finish(logger, nil, err.Error())
+		return
+	}
+	j.finish(logger, result, "")
+}
+
+func (j *trackedJob) Error(logger *zerolog.Logger, v interface{}) {
+	logger.Error().Interface("panic", v).Msg("failed to execute job.")
+	j.finish(logger, nil, fmt.Sprintf("%v", v))
+}
+
+func (j *trackedJob) finish(logger *zerolog.Logger, result map[string]interface{}, message string) {
+	now := time.Now()
+	j.record.FinishedAt = &now
+	j.record.Result = result
+	message = utils.TruncateText(message, maxErrorLength)
+	j.record.Error = message
+	if len(message) > 0 {
+		j.record.Status = models.JobFailed
+	} else {
+		j.record.Status = models.JobSucceeded
+	}
+	if err := j.tracker.update(&j.record); err != nil {
+		logger.Error().Err(err).Msg("failed to update job state")
+	}
+	j.tracker.notify(j.record)
+}
@@ -0,0 +1,47 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"github.com/rs/zerolog"
+	"time"
+)
+
+// Retryable - an error telling if another attempt may succeed, errors without it are not retried
+type Retryable interface {
+	Retryable() bool
+}
+
+// RetryPolicy - how often a runner is attempted and how long is waited between the attempts, the wait
+// doubles with every attempt
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+}
+
+// Wrap - a runner repeating the given runner while it fails with retryable errors, the wait between the
+// attempts is cut short and the last error returned when the context ends
+func (p RetryPolicy) Wrap(ctx context.Context, runner Runner) Runner {
+	return func(logger *zerolog.Logger) (map[string]interface{}, error) {
+		for attempt := 1; ; attempt++ {
+			result, err := runner(logger)
+			if err == nil || attempt >= p.MaxAttempts || !isRetryable(err) {
+				return result, err
+			}
+			delay := p.Backoff * time.Duration(1<<(attempt-1))
+			logger.Warn().Err(err).Int("attempt", attempt).Dur("delay", delay).Msg("retrying failed job")
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return result, err
+			}
+		}
+	}
+}
+
+func isRetryable(err error) bool {
+	var retryable Retryable
+	return errors.As(err, &retryable) && retryable.Retryable()
+}
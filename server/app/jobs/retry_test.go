@@ -0,0 +1,67 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"github.com/rs/zerolog"
+	"testing"
+	"time"
+)
+
+type retryableError bool
+
+func (e retryableError) Error() string {
+	return "failed"
+}
+
+func (e retryableError) Retryable() bool {
+	return bool(e)
+}
+
+func TestRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		attempts int
+	}{
+		{"retryable errors are retried up to the max attempts", retryableError(true), 3},
+		{"other errors are not retried", retryableError(false), 1},
+		{"plain errors are not retried", errors.New("failed"), 1},
+	}
+	logger := zerolog.Nop()
+	for _, test := range tests {
+		attempts := 0
+		policy := RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}
+		_, err := policy.Wrap(context.Background(), func(logger *zerolog.Logger) (map[string]interface{}, error) {
+			attempts++
+			return nil, test.err
+		})(&logger)
+		if err != test.err || attempts != test.attempts {
+			t.Errorf("%s: expected %d attempts, got %d (%v)", test.name, test.attempts, attempts, err)
+		}
+	}
+}
+
+func TestRetryPolicyStopsWaitingWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	logger := zerolog.Nop()
+	attempts := 0
+	policy := RetryPolicy{MaxAttempts: 3, Backoff: time.Hour}
+	done := make(chan error)
+	go func() {
+		_, err := policy.Wrap(ctx, func(logger *zerolog.Logger) (map[string]interface{}, error) {
+			attempts++
+			return nil, retryableError(true)
+		})(&logger)
+		done <- err
+	}()
+	cancel()
+	select {
+	case err := <-done:
+		if err == nil || attempts != 1 {
+			t.Fatalf("expected the first failure after one attempt, got %v after %d", err, attempts)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("the backoff was not cut short")
+	}
+}
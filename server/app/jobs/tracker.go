@@ -0,0 +1,214 @@
+package jobs
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"go-scrape-this/server/app/database"
+	"go-scrape-this/server/app/database/models"
+	"go-scrape-this/server/app/queue"
+	"gorm.io/gorm"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrQueueFull - the job was not submitted as the queue holds the most jobs waiting for a worker it may
+var ErrQueueFull = errors.New("job queue is full, try again later")
+
+// Listener - is called with the final state of every job that finishes
+type Listener func(job models.Job)
+
+// Prepare - stores state referring to a job in the transaction creating its record, before the job is queued,
+// so listeners of the job always find the state
+type Prepare func(tx *gorm.DB, job models.Job) error
+
+// Tracker - submits jobs to the queue and persists their state and result in the database
+type Tracker struct {
+	db         *database.Database
+	queue      *queue.Queue
+	maxPending int64
+	pending    int64
+	lock       sync.Mutex
+	waiters    map[uuid.UUID][]chan models.Job
+	listeners  []Listener
+}
+
+// NewTracker - creates a new job tracker, submitting jobs fails with ErrQueueFull while the given amount of jobs
+// is waiting for a worker
+func NewTracker(db *database.Database, queue *queue.Queue, maxPending int) *Tracker {
+	return &Tracker{
+		db:         db,
+		queue:      queue,
+		maxPending: int64(maxPending),
+		waiters:    map[uuid.UUID][]chan models.Job{},
+	}
+}
+
+// Submit - persists a new job record and enqueues the runner for processing
+func (t *Tracker) Submit(jobType string, input map[string]interface{}, runner Runner) (models.Job, error) {
+	return t.SubmitAttaching(jobType, input, func(logger *zerolog.Logger, _ uuid.UUID, _ Attach) (map[string]interface{}, error) {
+		return runner(logger)
+	})
+}
+
+// SubmitAttaching - like Submit, the runner can store files with the job as it runs
+func (t *Tracker) SubmitAttaching(jobType string, input map[string]interface{}, runner AttachingRunner) (models.Job, error) {
+	return t.SubmitPrepared(jobType, input, runner, nil)
+}
+
+// SubmitPrepared - like SubmitAttaching, the job is queued once the record and the state stored by prepare are
+// committed. Nothing is stored or queued when prepare fails.
+func (t *Tracker) SubmitPrepared(jobType string, input map[string]interface{}, runner AttachingRunner, prepare Prepare) (models.Job, error) {
+	if atomic.AddInt64(&t.pending, 1) > t.maxPending {
+		t.started()
+		return models.Job{}, ErrQueueFull
+	}
+	record := models.NewJob(jobType, input)
+	err := t.db.Connection().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&record).Error; err != nil {
+			return err
+		}
+		if prepare == nil {
+			return nil
+		}
+		return prepare(tx, record)
+	})
+	if err != nil {
+		t.started()
+		return models.Job{}, err
+	}
+	job := &trackedJob{
+		record: record,
+		runner: func(logger *zerolog.Logger) (map[string]interface{}, error) {
+			return runner(logger, record.ID, func(kind string, files map[string][]byte) error {
+				return t.storeArtifacts(record.ID, kind, files)
+			})
+		},
+		tracker: t,
+	}
+	// the queue blocks until a worker is ready, so we do not want to hold up the caller. The goroutines waiting
+	// are bounded by the max pending jobs.
+	go t.queue.Submit(job)
+	return record, nil
+}
+
+// OnFinish - registers a listener for finished jobs, listeners are called from the queue worker
+// that processed the job and should not block
+func (t *Tracker) OnFinish(listener Listener) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.listeners = append(t.listeners, listener)
+}
+
+// Get - returns the current state of a job
+func (t *Tracker) Get(id uuid.UUID) (models.Job, error) {
+	var job models.Job
+	result := t.db.Connection().First(&job, "id = ?", id.String())
+	if result.Error != nil {
+		return models.Job{}, result.Error
+	}
+	return job, nil
+}
+
+// Wait - waits for a job to finish, the returned bool is false if the timeout was reached first
+func (t *Tracker) Wait(id uuid.UUID, timeout time.Duration) (models.Job, bool, error) {
+	c := make(chan models.Job, 1)
+	t.lock.Lock()
+	t.waiters[id] = append(t.waiters[id], c)
+	t.lock.Unlock()
+	defer t.removeWaiter(id, c)
+
+	job, err := t.Get(id)
+	if err != nil {
+		return models.Job{}, false, err
+	}
+	if job.IsFinished() {
+		return job, true, nil
+	}
+
+	select {
+	case finished := <-c:
+		return finished, true, nil
+	case <-time.After(timeout):
+		return job, false, nil
+	}
+}
+
+// FailInterrupted - marks jobs that were left unfinished by a previous process as failed
+func (t *Tracker) FailInterrupted() (int64, error) {
+	now := time.Now()
+	result := t.db.Connection().
+		Model(&models.Job{}).
+		Where("status IN ?", []string{models.JobQueued, models.JobProcessing}).
+		Updates(map[string]interface{}{
+			"status":      models.JobFailed,
+			"error":       "interrupted by shutdown",
+			"finished_at": &now,
+		})
+	return result.RowsAffected, result.Error
+}
+
+// Artifacts - the files of the given kind stored with a job, ordered by name
+func (t *Tracker) Artifacts(id uuid.UUID, kind string) ([]models.JobArtifact, error) {
+	var artifacts []models.JobArtifact
+	result := t.db.Connection().
+		Where("job_id = ? AND kind = ?", id.String(), kind).
+		Order("name").
+		Find(&artifacts)
+	return artifacts, result.Error
+}
+
+func (t *Tracker) storeArtifacts(id uuid.UUID, kind string, files map[string][]byte) error {
+	artifacts := []models.JobArtifact{}
+	for name, data := range files {
+		artifacts = append(artifacts, models.JobArtifact{
+			JobID: id,
+			Kind:  kind,
+			Name:  name,
+			Data:  data,
+		})
+	}
+	return t.db.Connection().Create(&artifacts).Error
+}
+
+// started - a submitted job is no longer waiting for a worker
+func (t *Tracker) started() {
+	atomic.AddInt64(&t.pending, -1)
+}
+
+func (t *Tracker) update(record *models.Job) error {
+	return t.db.Connection().Save(record).Error
+}
+
+func (t *Tracker) notify(record models.Job) {
+	t.lock.Lock()
+	for _, c := range t.waiters[record.ID] {
+		select {
+		case c <- record:
+		default:
+		}
+	}
+	listeners := t.listeners
+	t.lock.Unlock()
+	for _, listener := range listeners {
+		listener(record)
+	}
+}
+
+func (t *Tracker) removeWaiter(id uuid.UUID, c chan models.Job) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	list := t.waiters[id]
+	for i := range list {
+		if list[i] == c {
+			list = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+	if len(list) == 0 {
+		delete(t.waiters, id)
+		return
+	}
+	t.waiters[id] = list
+}
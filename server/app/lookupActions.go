@@ -0,0 +1,244 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"go-scrape-this/server/app/cache"
+	"go-scrape-this/server/app/database/models"
+	"go-scrape-this/server/app/jobs"
+	"go-scrape-this/server/app/scrape"
+	"go-scrape-this/server/app/scrape/danish"
+	"go-scrape-this/server/app/scrape/fixture"
+	"go-scrape-this/server/app/scrape/har"
+	"go-scrape-this/server/app/scrape/recipe"
+	"go-scrape-this/server/app/scrape/warc"
+	"gorm.io/gorm"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const vehicleLookupJobType = "vehicle-lookup"
+
+type vehicleLookupRequest struct {
+	SearchType string                   `json:"search_type"`
+	Value      string                   `json:"value"`
+	Tabs       []string                 `json:"tabs"`
+	Profile    string                   `json:"profile"`
+	Wait       int                      `json:"wait"`
+	Typed      bool                     `json:"typed"`
+	Screenshot recipe.ScreenshotOptions `json:"screenshot"`
+	Har        bool                     `json:"har"`
+	Archive    bool                     `json:"archive"`
+}
+
+// lookupErrorStatus - the status a failed lookup is answered with by the kind of its error
+var lookupErrorStatus = map[string]int{
+	recipe.AMBIGUOUS.String():      http.StatusUnprocessableEntity,
+	recipe.BLOCKED.String():        http.StatusServiceUnavailable,
+	recipe.MAINTENANCE.String():    http.StatusServiceUnavailable,
+	recipe.LAYOUT_CHANGED.String(): http.StatusBadGateway,
+	recipe.TIMEOUT.String():        http.StatusGatewayTimeout,
+	recipe.BROWSER_CRASH.String():  http.StatusInternalServerError,
+	recipe.UNKNOWN.String():        http.StatusInternalServerError,
+}
+
+// vehicleLookupRunner - scrapes the vehicle, retrying retryable failures. A vehicle that does not exist is a
+// valid answer, so it succeeds with a result marked as not found. Every attempt of a lookup capturing HAR
+// files is attached as a session of its own, while only the attempts with an answer are archived.
+func (a *Application) vehicleLookupRunner(query scrape.VehicleQuery) jobs.AttachingRunner {
+	return func(logger *zerolog.Logger, id uuid.UUID, attach jobs.Attach) (map[string]interface{}, error) {
+		attempt := 0
+		return a.scrapeRetry.Wrap(a.stopping, func(logger *zerolog.Logger) (map[string]interface{}, error) {
+			attempt++
+			logger.Info().Interface("query", query.ToMap()).Msg("scraping vehicle")
+			options := scrape.RunOptions{
+				Timeout:  a.scrapeTimeout,
+				Replay:   a.replayArchive,
+				Blocking: recipe.NewBlockCounter(),
+			}
+			if len(a.recordDir) > 0 {
+				options.Recorder = fixture.NewRecorder()
+			}
+			if query.Har {
+				options.HAR = har.NewRecorder(a.harOptions)
+			}
+			if query.Archive || a.archiveLookups {
+				options.Archive = warc.NewArchiver(a.archiveOptions)
+			}
+			result, err := scrape.ScrapeVehicle(query, options)
+			if options.Recorder != nil {
+				a.saveFixture(logger, options.Recorder, scrape.DmrVehicleRecipe+"-"+query.Value)
+			}
+			if options.HAR != nil {
+				a.attachHAR(logger, attach, options.HAR, attempt)
+			}
+			a.recordBlocking(logger, id, scrape.DmrVehicleRecipe, options.Blocking, attempt)
+			var failure *recipe.Failure
+			if errors.As(err, &failure) && failure.Kind == recipe.NOT_FOUND {
+				result = map[string]interface{}{"found": false}
+			} else if err != nil {
+				return nil, err
+			} else {
+				result["found"] = true
+			}
+			if options.Archive != nil {
+				a.archiveVehicleLookup(logger, id, query, options.Archive, result, attempt)
+			}
+			return result, nil
+		})(logger)
+	}
+}
+
+// saveFixture - stores a recorded scrape in the record directory, failing to do so does not fail the scrape
+func (a *Application) saveFixture(logger *zerolog.Logger, recorder *fixture.Recorder, name string) {
+	filename := filepath.Join(a.recordDir, name+"-"+time.Now().UTC().Format("20060102T150405.000")+".zip")
+	err := recorder.Archive().Save(filename)
+	if err != nil {
+		logger.Error().Err(err).Str("file", filename).Msg("failed to save fixture")
+		return
+	}
+	logger.Info().Str("file", filename).Msg("saved fixture")
+}
+
+// submitVehicleLookup - submits a lookup job, prepare may store state referring to the job before it runs
+func (a *Application) submitVehicleLookup(query scrape.VehicleQuery, prepare jobs.Prepare) (models.Job, error) {
+	return a.jobs.SubmitPrepared(vehicleLookupJobType, query.ToMap(), a.vehicleLookupRunner(query), prepare)
+}
+
+// lookupVehicle - answers the query from the cache when the directives allow it, otherwise a lookup job is submitted.
+// Stale answers are returned right away while a refresh job updates the cache in the background.
+func (a *Application) lookupVehicle(query scrape.VehicleQuery, directives cache.Directives) (models.Job, cache.Result, error) {
+	key := query.CacheKey()
+	cached, err := a.cache.Lookup(scrape.DmrSource, key, directives)
+	if err != nil {
+		return models.Job{}, cache.Result{}, err
+	}
+	if cached.Status == cache.HIT || cached.Status == cache.STALE {
+		job, err := a.jobs.Get(cached.Entry.JobID)
+		if err == nil {
+			if cached.Status == cache.STALE {
+				a.refreshVehicleLookup(query)
+			}
+			return job, cached, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Job{}, cache.Result{}, err
+		}
+		cached = cache.Result{Status: cache.MISS}
+	}
+	job, err := a.submitVehicleLookup(query, nil)
+	return job, cached, err
+}
+
+func (a *Application) refreshVehicleLookup(query scrape.VehicleQuery) {
+	key := query.CacheKey()
+	if !a.cache.BeginRefresh(scrape.DmrSource, key) {
+		return
+	}
+	_, err := a.submitVehicleLookup(query, nil)
+	if err != nil {
+		a.cache.EndRefresh(scrape.DmrSource, key)
+		a.DefaultLogger().Error().Err(err).Str("key", key).Msg("failed to submit cache refresh")
+	}
+}
+
+// cacheVehicleLookup - job listener that stores successful lookups in the cache
+func (a *Application) cacheVehicleLookup(job models.Job) {
+	if job.Type != vehicleLookupJobType {
+		return
+	}
+	query, err := scrape.VehicleQueryFromMap(job.Input)
+	if err != nil {
+		return
+	}
+	key := query.CacheKey()
+	defer a.cache.EndRefresh(scrape.DmrSource, key)
+	if job.Status != models.JobSucceeded || job.FinishedAt == nil {
+		return
+	}
+	err = a.cache.Store(scrape.DmrSource, key, job.ID, *job.FinishedAt)
+	if err != nil {
+		a.DefaultLogger().Error().Err(err).Str("key", key).Msg("failed to store lookup in cache")
+	}
+}
+
+func (a *Application) vehicleLookupAction(w http.ResponseWriter, r *http.Request) {
+	var request vehicleLookupRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	query, err := scrape.NewVehicleQuery(request.SearchType, request.Value, request.Tabs)
+	if err == nil {
+		query, err = query.WithProfile(request.Profile)
+	}
+	if err == nil {
+		query, err = query.WithScreenshot(request.Screenshot)
+	}
+	if err != nil {
+		errorResponse(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	query.Har = request.Har
+	query.Archive = request.Archive
+	directives := cache.ParseDirectives(r.Header.Get("Cache-Control"))
+	// a cached answer has no capture of its own, so capturing always scrapes
+	if query.Har || query.Archive {
+		directives.NoCache = true
+	}
+	job, cached, err := a.lookupVehicle(query, directives)
+	if err != nil {
+		submitErrorResponse(w, err)
+		return
+	}
+	w.Header().Set("X-Cache", cached.Status)
+	if cached.Entry.JobID == job.ID {
+		w.Header().Set("Age", strconv.Itoa(int(cached.Age.Seconds())))
+	}
+	if request.Wait > 0 && !job.IsFinished() {
+		wait := time.Second * time.Duration(request.Wait)
+		if wait > a.maxLookupWait {
+			wait = a.maxLookupWait
+		}
+		job, _, err = a.jobs.Wait(job.ID, wait)
+		if err != nil {
+			panic(err)
+		}
+	}
+	if request.Typed {
+		job = typedLookup(job)
+	}
+	lookupResponse(w, job)
+}
+
+// typedLookup - the job with the values of a lookup result parsed from their Danish formatting
+func typedLookup(job models.Job) models.Job {
+	if job.Type == vehicleLookupJobType && job.Result != nil {
+		job.Result = danish.ParseResult(job.Result)
+	}
+	return job
+}
+
+// lookupResponse - responds with the lookup job, finished lookups are answered with a status matching their
+// outcome: not found vehicles with 404 and failures by the kind of their error
+func lookupResponse(w http.ResponseWriter, job models.Job) {
+	if job.Status == models.JobSucceeded && job.Result["found"] == false {
+		jsonResponse(w, http.StatusNotFound, job)
+		return
+	}
+	if job.Status == models.JobFailed {
+		if status, found := lookupErrorStatus[job.ErrorKind]; found {
+			if status == http.StatusServiceUnavailable {
+				w.Header().Set("Retry-After", "60")
+			}
+			jsonResponse(w, status, job)
+			return
+		}
+	}
+	jobResponse(w, job)
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"github.com/gorilla/handlers"
+	"net/http"
+)
+
+// ContentTypeHandler - validates the request content type against the allowed types of the requested path,
+// paths without their own list of allowed types use the default list
+func ContentTypeHandler(next http.Handler, defaultTypes []string, pathTypes map[string][]string) http.Handler {
+	defaultHandler := handlers.ContentTypeHandler(next, defaultTypes...)
+	pathHandlers := map[string]http.Handler{}
+	for path, types := range pathTypes {
+		pathHandlers[path] = handlers.ContentTypeHandler(next, types...)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handler, found := pathHandlers[r.URL.Path]; found {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		defaultHandler.ServeHTTP(w, r)
+	})
+}
@@ -3,6 +3,7 @@ package queue
 import (
 	"github.com/rs/zerolog"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -10,6 +11,7 @@ type QueueStatus struct {
 	TotalWorkers  int `json:"total-workers"`
 	ActiveWorkers int `json:"active-workers"`
 	ReadyWorkers  int `json:"ready-workers"`
+	PendingJobs   int `json:"pending-jobs"`
 }
 
 // Queue - a queue for enqueueing jobs to be processed
@@ -21,6 +23,7 @@ type Queue struct {
 	workersStopped    *sync.WaitGroup
 	quit              chan bool
 	shutdownTimeout   time.Duration
+	pending           int64
 }
 
 // NewQueue - creates a new job queue
@@ -49,13 +52,14 @@ func (q *Queue) Start() {
 	for i := 0; i < len(q.workers); i++ {
 		q.workers[i].Start()
 	}
+	q.dispatcherStopped.Add(1)
 	go func() {
-		q.dispatcherStopped.Add(1)
 		for {
 			select {
 			case job := <-q.internalQueue: // We got something in on our queue
 				workerChannel := <-q.readyPool // Check out an available worker
 				workerChannel <- job           // Send the request to the channel
+				atomic.AddInt64(&q.pending, -1)
 			case <-q.quit:
 				for i := 0; i < len(q.workers); i++ {
 					q.workers[i].Stop()
@@ -78,9 +82,15 @@ func (q *Queue) Stop() {
 
 // Submit - adds a new job to be processed
 func (q *Queue) Submit(job Job) {
+	atomic.AddInt64(&q.pending, 1)
 	q.internalQueue <- job
 }
 
+// Pending - the amount of submitted jobs that are waiting for a worker
+func (q *Queue) Pending() int {
+	return int(atomic.LoadInt64(&q.pending))
+}
+
 // GetStates - returns the states of all the workers
 func (q *Queue) GetStates() []WorkerState {
 	output := []WorkerState{}
@@ -107,5 +117,6 @@ func (q *Queue) QueueStatus() QueueStatus {
 		TotalWorkers:  totalWorkers,
 		ActiveWorkers: activeWorkers,
 		ReadyWorkers:  rdyWorkers,
+		PendingJobs:   q.Pending(),
 	}
 }
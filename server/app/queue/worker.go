@@ -89,8 +89,8 @@ func (w *Worker) LogWithState() *zerolog.Logger {
 
 // Start - begins the job processing loop for the worker
 func (w *Worker) Start() {
+	w.done.Add(1)
 	go func() {
-		w.done.Add(1)
 		w.state = WorkerState{
 			Id:    w.state.Id,
 			State: starting,
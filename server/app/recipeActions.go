@@ -0,0 +1,50 @@
+package app
+
+import (
+	"github.com/gorilla/mux"
+	"go-scrape-this/server/app/scrape"
+	"net/http"
+	"net/url"
+)
+
+func (a *Application) recipeListAction(w http.ResponseWriter, r *http.Request) {
+	names := scrape.Recipes().Names()
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"data":  names,
+		"total": len(names),
+	})
+}
+
+func (a *Application) recipeAction(w http.ResponseWriter, r *http.Request) {
+	recipe, err := scrape.Recipes().Get(mux.Vars(r)["name"])
+	if err != nil {
+		errorResponse(w, http.StatusNotFound, "recipe not found")
+		return
+	}
+	jsonResponse(w, http.StatusOK, recipe)
+}
+
+func (a *Application) profileListAction(w http.ResponseWriter, r *http.Request) {
+	output := map[string]interface{}{}
+	for _, name := range scrape.ProfileNames() {
+		profile, err := scrape.Profile(name)
+		if err != nil {
+			continue
+		}
+		profile.Proxy = redactedProxy(profile.Proxy)
+		output[name] = profile
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"data":  output,
+		"total": len(output),
+	})
+}
+
+// redactedProxy - hides the credentials of a proxy url
+func redactedProxy(proxy string) string {
+	parsed, err := url.Parse(proxy)
+	if err != nil || len(proxy) == 0 {
+		return proxy
+	}
+	return parsed.Redacted()
+}
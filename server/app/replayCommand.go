@@ -0,0 +1,83 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go-scrape-this/server/app/scrape"
+	"go-scrape-this/server/app/scrape/fixture"
+	"go-scrape-this/server/app/scrape/recipe"
+	"os"
+	"time"
+)
+
+// ReplayCommand - re-runs the extraction of a recorded scrape against its fixture archive without touching the
+// network and prints the result, returns the exit code of the command
+func ReplayCommand(args []string) int {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	recipeDir := flags.String("recipes", os.Getenv("RECIPE_DIR"), "directory with recipes overriding the embedded ones")
+	recipeName := flags.String("recipe", "", "recipe to run instead of the recorded one")
+	profilesFile := flags.String("profiles", os.Getenv("BROWSER_PROFILES"), "file with named browser profiles")
+	profile := flags.String("profile", "", "browser profile to run with")
+	timeout := flags.Int("timeout", 120, "seconds the replay may take")
+	images := flags.Bool("images", false, "keep screenshots in the printed result")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: server replay [flags] <fixture.zip>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	if len(*recipeDir) > 0 {
+		if err := scrape.LoadRecipes(*recipeDir); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to load recipes: %v\n", err)
+			return 1
+		}
+	}
+	if len(*profilesFile) > 0 {
+		if err := scrape.LoadProfiles(*profilesFile); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to load browser profiles: %v\n", err)
+			return 1
+		}
+	}
+	archive, err := fixture.Open(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open fixture: %v\n", err)
+		return 1
+	}
+	name := archive.Manifest.Recipe
+	if len(*recipeName) > 0 {
+		name = *recipeName
+	}
+
+	result, err := scrape.RunRecipe(name, archive.Manifest.Variables, scrape.RunOptions{
+		Profile: *profile,
+		Timeout: time.Second * time.Duration(*timeout),
+		Replay:  archive,
+	})
+	if err != nil {
+		var failure *recipe.Failure
+		if errors.As(err, &failure) {
+			fmt.Fprintf(os.Stderr, "replay failed (%s): %v\n", failure.Kind, err)
+		} else {
+			fmt.Fprintf(os.Stderr, "replay failed: %v\n", err)
+		}
+		return 1
+	}
+	if !*images {
+		result = withoutImages(result)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to print result: %v\n", err)
+		return 1
+	}
+	return 0
+}
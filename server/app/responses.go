@@ -0,0 +1,36 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"go-scrape-this/server/app/jobs"
+	"net/http"
+)
+
+func jsonResponse(w http.ResponseWriter, code int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	err := json.NewEncoder(w).Encode(data)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func errorResponse(w http.ResponseWriter, code int, message string) {
+	jsonResponse(w, code, map[string]interface{}{
+		"error":   true,
+		"code":    code,
+		"message": message,
+	})
+}
+
+// submitErrorResponse - responds to a job that could not be submitted, a full queue is answered as unavailable
+// while other errors panic like any other database error
+func submitErrorResponse(w http.ResponseWriter, err error) {
+	if errors.Is(err, jobs.ErrQueueFull) {
+		w.Header().Set("Retry-After", "30")
+		errorResponse(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	panic(err)
+}
@@ -0,0 +1,141 @@
+package app
+
+import (
+	"errors"
+	"github.com/gorilla/mux"
+	"go-scrape-this/server/app/database/models"
+	"go-scrape-this/server/app/database/structs"
+	"go-scrape-this/server/app/scrape"
+	"go-scrape-this/server/app/search"
+	"go-scrape-this/server/app/utils"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// storeScrapeResult - job listener that stores the results of the sources in the search index, vehicles that
+// were not found have no result to store
+func (a *Application) storeScrapeResult(job models.Job) {
+	if job.Status != models.JobSucceeded {
+		return
+	}
+	for name, source := range resultSources {
+		if source.jobType != job.Type {
+			continue
+		}
+		data := withoutImages(job.Result)
+		if name == scrape.DmrSource {
+			if data["found"] != true {
+				return
+			}
+			delete(data, "found")
+		}
+		subject, _ := job.Input[source.subject].(string)
+		// urls of ad-hoc scrapes are not bound by the crawler, the subject is cut to the size of its column
+		subject = utils.TruncateText(subject, 2048)
+		stored := models.ScrapeResult{
+			JobID:     job.ID,
+			Source:    name,
+			Subject:   subject,
+			Content:   structs.Text(search.Content(data)),
+			Data:      data,
+			ScrapedAt: time.Now(),
+		}
+		if job.FinishedAt != nil {
+			stored.ScrapedAt = *job.FinishedAt
+		}
+		if name == scrape.DmrSource {
+			attributes := scrape.ScrapedVehicleAttributes(data)
+			stored.Make = attributes.Make
+			stored.Model = attributes.Model
+			stored.Insurer = attributes.Insurer
+			stored.Vin = attributes.Vin
+			if attributes.FirstRegistration != nil {
+				year := attributes.FirstRegistration.Year()
+				stored.FirstRegistrationYear = &year
+			}
+		}
+		err := a.search.Store(&stored)
+		if err != nil {
+			a.DefaultLogger().Error().Err(err).Str("job", job.ID.String()).Msg("failed to store scrape result")
+		}
+		return
+	}
+}
+
+// resultSearchAction - searches the stored results by full-text terms in "q" and by their attributes
+func (a *Application) resultSearchAction(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	limit := utils.GetQueryIntOption(r, "limit", 50)
+	offset := utils.GetQueryIntOption(r, "offset", 0)
+	if limit > 500 {
+		limit = 500
+	}
+	query := search.Query{
+		Text:    params.Get("q"),
+		Source:  params.Get("source"),
+		Subject: params.Get("subject"),
+		Make:    params.Get("make"),
+		Model:   params.Get("model"),
+		Insurer: params.Get("insurer"),
+		Vin:     params.Get("vin"),
+		Limit:   limit,
+		Offset:  offset,
+	}
+	if len(query.Source) > 0 {
+		if _, ok := resultSources[query.Source]; !ok {
+			errorResponse(w, http.StatusUnprocessableEntity, "unknown source \""+query.Source+"\"")
+			return
+		}
+	}
+	if year := params.Get("year"); len(year) > 0 {
+		parsed, err := strconv.Atoi(year)
+		if err != nil {
+			errorResponse(w, http.StatusUnprocessableEntity, "invalid year")
+			return
+		}
+		query.Year = &parsed
+	}
+	var err error
+	query.From, err = parseExportTime(params.Get("from"), false)
+	if err != nil {
+		errorResponse(w, http.StatusUnprocessableEntity, "invalid from: "+err.Error())
+		return
+	}
+	query.To, err = parseExportTime(params.Get("to"), true)
+	if err != nil {
+		errorResponse(w, http.StatusUnprocessableEntity, "invalid to: "+err.Error())
+		return
+	}
+	results, count, err := a.search.Search(query)
+	if err != nil {
+		panic(err)
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"data":   results,
+		"total":  count,
+		"count":  len(results),
+		"offset": offset,
+		"limit":  limit,
+		"search": a.search.Backend(),
+	})
+}
+
+func (a *Application) resultAction(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "invalid result id")
+		return
+	}
+	var stored models.ScrapeResult
+	result := a.Database().Connection().First(&stored, id)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		errorResponse(w, http.StatusNotFound, "result not found")
+		return
+	}
+	if result.Error != nil {
+		panic(result.Error)
+	}
+	jsonResponse(w, http.StatusOK, stored)
+}
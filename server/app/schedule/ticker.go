@@ -0,0 +1,48 @@
+package schedule
+
+import (
+	"sync"
+	"time"
+)
+
+// Ticker - runs a task on a fixed interval until it is stopped
+type Ticker struct {
+	interval time.Duration
+	task     func()
+	quit     chan bool
+	stopped  *sync.WaitGroup
+}
+
+// NewTicker - creates a new ticker for the task
+func NewTicker(interval time.Duration, task func()) *Ticker {
+	return &Ticker{
+		interval: interval,
+		task:     task,
+		quit:     make(chan bool),
+		stopped:  &sync.WaitGroup{},
+	}
+}
+
+// Start - starts running the task, the first run happens after one interval
+func (t *Ticker) Start() {
+	t.stopped.Add(1)
+	go func() {
+		ticker := time.NewTicker(t.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				t.task()
+			case <-t.quit:
+				t.stopped.Done()
+				return
+			}
+		}
+	}()
+}
+
+// Stop - stops the ticker and waits for a running task to finish
+func (t *Ticker) Stop() {
+	t.quit <- true
+	t.stopped.Wait()
+}
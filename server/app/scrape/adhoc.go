@@ -0,0 +1,73 @@
+package scrape
+
+import (
+	"errors"
+	"go-scrape-this/server/app/scrape/recipe"
+	"net/url"
+	"strings"
+)
+
+// adhocRecipe - the name ad-hoc scrapes are run and recorded under
+const adhocRecipe = "adhoc"
+
+// ErrHostNotAllowed - the url of an ad-hoc scrape is not on the allowlist
+var ErrHostNotAllowed = errors.New("host is not allowed for ad-hoc scrapes")
+
+// AdhocScrape - a validated one-off extraction, the script is evaluated on the page once it is ready
+type AdhocScrape struct {
+	URL          string
+	WaitSelector string
+	Script       string
+}
+
+// NewAdhocScrape - validates the scrape, the host of the url must be on the allowlist
+func NewAdhocScrape(target string, waitSelector string, script string, allowed recipe.HostAllowlist) (AdhocScrape, error) {
+	parsed, err := url.Parse(strings.TrimSpace(target))
+	if err != nil || len(parsed.Host) == 0 || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return AdhocScrape{}, errors.New("invalid url: \"" + target + "\"")
+	}
+	if !allowed.Allows(parsed.Hostname()) {
+		return AdhocScrape{}, ErrHostNotAllowed
+	}
+	if len(strings.TrimSpace(script)) == 0 {
+		return AdhocScrape{}, errors.New("missing script")
+	}
+	return AdhocScrape{
+		URL:          parsed.String(),
+		WaitSelector: strings.TrimSpace(waitSelector),
+		Script:       script,
+	}, nil
+}
+
+func (s AdhocScrape) ToMap() map[string]interface{} {
+	output := map[string]interface{}{
+		"url":    s.URL,
+		"script": s.Script,
+	}
+	if len(s.WaitSelector) > 0 {
+		output["wait_selector"] = s.WaitSelector
+	}
+	return output
+}
+
+// Recipe - the scrape as a browser recipe storing the value of the script as "result"
+func (s AdhocScrape) Recipe() recipe.Recipe {
+	steps := []recipe.Step{{Action: recipe.NAVIGATE, URL: "{{url}}"}}
+	if len(s.WaitSelector) > 0 {
+		steps = append(steps, recipe.Step{Action: recipe.WAIT, Selector: "{{wait_selector}}"})
+	}
+	steps = append(steps, recipe.Step{Action: recipe.EVALUATE, Script: s.Script, Into: "result"})
+	return recipe.Recipe{
+		Name:   adhocRecipe,
+		Engine: recipe.BROWSER.String(),
+		Steps:  steps,
+	}
+}
+
+// RunAdhoc - runs the scrape in a browser of its own, requests are limited to the allowed hosts of the options
+func RunAdhoc(scrape AdhocScrape, options RunOptions) (map[string]interface{}, error) {
+	return runRecipe(Recipes(), scrape.Recipe(), map[string]interface{}{
+		"url":           scrape.URL,
+		"wait_selector": scrape.WaitSelector,
+	}, options)
+}
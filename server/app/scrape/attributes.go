@@ -0,0 +1,65 @@
+package scrape
+
+import (
+	"go-scrape-this/server/app/utils"
+	"sort"
+	"strings"
+	"time"
+)
+
+// VehicleAttributes - the attributes of a scraped vehicle results are searched by, empty if the result has none
+type VehicleAttributes struct {
+	Make              string
+	Model             string
+	Vin               string
+	Insurer           string
+	FirstRegistration *time.Time
+}
+
+// labelSpelling - spells the danish letters of labels the way the ids of the register do
+var labelSpelling = strings.NewReplacer("æ", "ae", "ø", "oe", "å", "aa", "_", "", "-", "", " ", "")
+
+// ScrapedVehicleAttributes - finds the attributes by the labels and ids of the fields of the result, the
+// history of a tab is never used as it holds previous values
+func ScrapedVehicleAttributes(result map[string]interface{}) VehicleAttributes {
+	output := VehicleAttributes{Vin: VehicleVin(result)}
+	fields := utils.Flatten(result, ".")
+	keys := []string{}
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := strings.TrimSpace(fields[key])
+		if len(value) == 0 {
+			continue
+		}
+		name := labelSpelling.Replace(strings.ToLower(key[strings.LastIndex(key, ".")+1:]))
+		switch {
+		case strings.Contains(name, "maerkemodelvariant") && len(output.Make) == 0:
+			output.Make, output.Model = splitMakeModel(value)
+		case strings.HasSuffix(name, "maerketypenavn") && len(output.Make) == 0:
+			output.Make = strings.ToUpper(value)
+		case strings.HasSuffix(name, "modeltypenavn") && len(output.Model) == 0:
+			output.Model = strings.ToUpper(value)
+		case strings.Contains(name, "foersteregistrering") && output.FirstRegistration == nil:
+			output.FirstRegistration = parseHistoryDate(value)
+		case strings.HasPrefix(key, TAB_INSURANCE.String()+".") && strings.Contains(name, "selskab") && len(output.Insurer) == 0:
+			output.Insurer = value
+		}
+	}
+	return output
+}
+
+// splitMakeModel - splits "TOYOTA, PROACE, 2,0 D-4D" into make and model, values without separators are
+// split at the first spaces instead
+func splitMakeModel(value string) (string, string) {
+	parts := strings.Split(value, ", ")
+	if len(parts) < 2 {
+		parts = strings.Fields(value)
+	}
+	if len(parts) < 2 {
+		return strings.ToUpper(value), ""
+	}
+	return strings.ToUpper(strings.TrimSpace(parts[0])), strings.ToUpper(strings.TrimSpace(parts[1]))
+}
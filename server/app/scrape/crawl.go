@@ -0,0 +1,51 @@
+package scrape
+
+import (
+	"go-scrape-this/server/app/scrape/recipe"
+)
+
+// crawlRecipe - the name crawled pages are run and recorded under
+const crawlRecipe = "crawl"
+
+// DefaultLinkSelector - the elements whose href is followed when a crawl has no link selector of its own
+const DefaultLinkSelector = "a[href]"
+
+// CrawlPage - how a page of a crawl is scraped: the fields are extracted into "data" and the href attributes
+// of the elements matching the link selector into "links"
+type CrawlPage struct {
+	Engine string
+	Links  string
+	Fields map[string]recipe.Rule
+}
+
+// Recipe - the page as a recipe
+func (p CrawlPage) Recipe() recipe.Recipe {
+	links := p.Links
+	if len(links) == 0 {
+		links = DefaultLinkSelector
+	}
+	steps := []recipe.Step{{Action: recipe.NAVIGATE, URL: "{{url}}"}}
+	if len(p.Fields) > 0 {
+		steps = append(steps, recipe.Step{Action: recipe.EXTRACT, Fields: p.Fields, Into: "data"})
+	}
+	steps = append(steps, recipe.Step{Action: recipe.EXTRACT, Fields: map[string]recipe.Rule{
+		"links": {Selector: links, Attribute: "href", Multiple: true},
+	}})
+	return recipe.Recipe{
+		Name:   crawlRecipe,
+		Engine: p.Engine,
+		Steps:  steps,
+	}
+}
+
+// Validate - checks the engine, the link selector and the extraction rules
+func (p CrawlPage) Validate() error {
+	return p.Recipe().Validate()
+}
+
+// RunCrawlPage - scrapes the page at the url
+func RunCrawlPage(page CrawlPage, url string, options RunOptions) (map[string]interface{}, error) {
+	return runRecipe(Recipes(), page.Recipe(), map[string]interface{}{
+		"url": url,
+	}, options)
+}
@@ -0,0 +1,57 @@
+package danish
+
+import (
+	"go-scrape-this/server/app/scrape/recipe"
+	"strings"
+)
+
+var identifierSuffixes = []string{"nr", "_id", "-id", " id"}
+
+// ParseResult - replaces the strings of a scraped result with parsed values, other values are kept as they
+// are and so are screenshots and their formats. Fields named as identifiers are kept as text.
+func ParseResult(result map[string]interface{}) map[string]interface{} {
+	output := map[string]interface{}{}
+	for key, value := range result {
+		if strings.HasSuffix(key, "_image") || key == recipe.ScreenshotsKey {
+			output[key] = value
+			continue
+		}
+		if text, ok := value.(string); ok && IsIdentifierField(key) {
+			output[key] = ParseIdentifier(text)
+			continue
+		}
+		output[key] = parseAny(value)
+	}
+	return output
+}
+
+// IsIdentifierField - if the name of the field says it holds a number identifying something rather than an
+// amount, e.g. "Registreringsnummer", "Typegodkendelsesnr." or "Køretøjs-id"
+func IsIdentifierField(name string) bool {
+	name = strings.ToLower(strings.TrimRight(strings.TrimSpace(name), ".:"))
+	if strings.Contains(name, "nummer") || strings.Contains(name, "ident") {
+		return true
+	}
+	for _, suffix := range identifierSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseAny(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return Parse(v)
+	case map[string]interface{}:
+		return ParseResult(v)
+	case []interface{}:
+		output := make([]interface{}, len(v))
+		for i, item := range v {
+			output[i] = parseAny(item)
+		}
+		return output
+	}
+	return value
+}
@@ -0,0 +1,67 @@
+package danish
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIsIdentifierField(t *testing.T) {
+	tests := []struct {
+		name       string
+		identifier bool
+	}{
+		{"Registreringsnummer", true},
+		{"Stelnummer:", true},
+		{"Typegodkendelsesnr.", true},
+		{"Køretøjs-id", true},
+		{"ptr_dmr_portlet_KoeretoejIdentifikator", true},
+		{"vehicle_id", true},
+		{"Totalvægt", false},
+		{"Antal døre", false},
+		{"Første registreringsdato", false},
+	}
+	for _, test := range tests {
+		if IsIdentifierField(test.name) != test.identifier {
+			t.Errorf("%q: expected identifier %v", test.name, test.identifier)
+		}
+	}
+}
+
+func TestParseResult(t *testing.T) {
+	result := map[string]interface{}{
+		"vehicle": map[string]interface{}{
+			"Registreringsnummer":                  "12345",
+			"Typeanmeldelses-/typegodkendelsesnr.": "e1*2001/116*0211",
+			"Stelnummer":                           "-",
+			"Totalvægt":                            "1.810 kg",
+			"history": []interface{}{
+				map[string]interface{}{"Registreringsnummer": "0123", "Dato": "01-02-2015"},
+			},
+		},
+		"vehicle_image": "aGVsbG8=",
+		"found":         true,
+	}
+	parsed := ParseResult(result)
+	vehicle := parsed["vehicle"].(map[string]interface{})
+	tests := []struct {
+		field    interface{}
+		expected Value
+	}{
+		{vehicle["Registreringsnummer"], Value{Raw: "12345", Type: TEXT, Value: "12345"}},
+		{vehicle["Typeanmeldelses-/typegodkendelsesnr."], Value{Raw: "e1*2001/116*0211", Type: TEXT, Value: "e1*2001/116*0211"}},
+		{vehicle["Stelnummer"], Value{Raw: "-", Type: EMPTY}},
+		{vehicle["history"].([]interface{})[0].(map[string]interface{})["Registreringsnummer"], Value{Raw: "0123", Type: TEXT, Value: "0123"}},
+		{vehicle["history"].([]interface{})[0].(map[string]interface{})["Dato"], Value{Raw: "01-02-2015", Type: DATE, Value: "2015-02-01"}},
+	}
+	for i, test := range tests {
+		if !reflect.DeepEqual(test.field, test.expected) {
+			t.Errorf("field %d: expected %+v, got %+v", i+1, test.expected, test.field)
+		}
+	}
+	if weight := vehicle["Totalvægt"].(Value); weight.Type != QUANTITY || weight.Value != 1810.0 {
+		t.Errorf("unexpected weight %+v", weight)
+	}
+	if parsed["vehicle_image"] != "aGVsbG8=" || parsed["found"] != true {
+		t.Errorf("expected screenshots and non strings to be kept, got %v %v", parsed["vehicle_image"], parsed["found"])
+	}
+}
@@ -0,0 +1,53 @@
+package danish
+
+import "strings"
+
+// unit - a unit used by the register, the factor converts a value into the SI unit. Units without an SI
+// unit, like decibel or kroner, are kept as they are.
+type unit struct {
+	symbol string
+	si     string
+	factor float64
+}
+
+var units = []unit{
+	{symbol: "kg", si: "kg", factor: 1},
+	{symbol: "g", si: "kg", factor: 0.001},
+	{symbol: "t", si: "kg", factor: 1000},
+	{symbol: "ton", si: "kg", factor: 1000},
+	{symbol: "km", si: "m", factor: 1000},
+	{symbol: "m", si: "m", factor: 1},
+	{symbol: "cm", si: "m", factor: 0.01},
+	{symbol: "mm", si: "m", factor: 0.001},
+	{symbol: "km/t", si: "m/s", factor: 1000.0 / 3600},
+	{symbol: "km/h", si: "m/s", factor: 1000.0 / 3600},
+	{symbol: "W", si: "W", factor: 1},
+	{symbol: "kW", si: "W", factor: 1000},
+	{symbol: "hk", si: "W", factor: 735.49875},
+	{symbol: "ccm", si: "m³", factor: 1e-6},
+	{symbol: "cm3", si: "m³", factor: 1e-6},
+	{symbol: "cm³", si: "m³", factor: 1e-6},
+	{symbol: "l", si: "m³", factor: 0.001},
+	{symbol: "liter", si: "m³", factor: 0.001},
+	{symbol: "g/km", si: "kg/m", factor: 1e-6},
+	{symbol: "km/l", si: "m/m³", factor: 1e6},
+	{symbol: "Wh/km", si: "J/m", factor: 3.6},
+	{symbol: "kWh", si: "J", factor: 3.6e6},
+	{symbol: "Nm", si: "N·m", factor: 1},
+	{symbol: "V", si: "V", factor: 1},
+	{symbol: "%"},
+	{symbol: "dB"},
+	{symbol: "dB(A)"},
+	{symbol: "kr"},
+	{symbol: "kr."},
+}
+
+func findUnit(value string) (unit, bool) {
+	value = strings.TrimSpace(value)
+	for _, candidate := range units {
+		if strings.EqualFold(candidate.symbol, value) {
+			return candidate, true
+		}
+	}
+	return unit{}, false
+}
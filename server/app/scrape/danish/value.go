@@ -0,0 +1,154 @@
+package danish
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	// the time zone database is embedded, so Europe/Copenhagen is known even without one on the system
+	_ "time/tzdata"
+)
+
+const (
+	TEXT     = "text"
+	EMPTY    = "empty"
+	BOOLEAN  = "boolean"
+	NUMBER   = "number"
+	QUANTITY = "quantity"
+	DATE     = "date"
+	DATETIME = "datetime"
+)
+
+// Location - the time zone values without an offset are read in
+var Location = mustLoadLocation("Europe/Copenhagen")
+
+var (
+	numberPattern   = regexp.MustCompile(`^([-+]?(?:\d{1,3}(?:\.\d{3})+|\d+)(?:,\d+)?)(?:\s*(\S.*))?$`)
+	datePattern     = regexp.MustCompile(`^(\d{1,2})[-./](\d{1,2})[-./](\d{4})$`)
+	dateTimePattern = regexp.MustCompile(`^(\d{1,2})[-./](\d{1,2})[-./](\d{4})(?:\s+kl\.?)?\s+(\d{1,2})[:.](\d{2})(?:[:.](\d{2}))?$`)
+	emptyValues     = []string{"", "-", "--", "ukendt", "ikke oplyst", "ingen oplysninger"}
+	trueValues      = []string{"ja", "sand"}
+	falseValues     = []string{"nej", "falsk"}
+)
+
+// leadingZero - digits starting with a zero, which are codes rather than numbers
+var leadingZero = regexp.MustCompile(`^0\d+$`)
+
+// Value - a value parsed from its Danish formatting, the original string is kept as raw. Quantities carry
+// their unit and, for units that can be converted, the value in SI units.
+type Value struct {
+	Raw     string      `json:"raw"`
+	Type    string      `json:"type"`
+	Value   interface{} `json:"value"`
+	Unit    string      `json:"unit,omitempty"`
+	SIValue *float64    `json:"si_value,omitempty"`
+	SIUnit  string      `json:"si_unit,omitempty"`
+}
+
+// Parse - parses a value of the Danish motor register, values that match no known format are text
+func Parse(raw string) Value {
+	value := strings.Join(strings.Fields(raw), " ")
+	lower := strings.ToLower(value)
+	switch {
+	case contains(emptyValues, lower):
+		return Value{Raw: raw, Type: EMPTY}
+	case contains(trueValues, lower):
+		return Value{Raw: raw, Type: BOOLEAN, Value: true}
+	case contains(falseValues, lower):
+		return Value{Raw: raw, Type: BOOLEAN, Value: false}
+	}
+	if date, ok := parseDate(value); ok {
+		return Value{Raw: raw, Type: DATE, Value: date}
+	}
+	if dateTime, ok := parseDateTime(value); ok {
+		return Value{Raw: raw, Type: DATETIME, Value: dateTime.Format(time.RFC3339)}
+	}
+	if leadingZero.MatchString(value) {
+		return Value{Raw: raw, Type: TEXT, Value: value}
+	}
+	if match := numberPattern.FindStringSubmatch(value); match != nil {
+		number, err := ParseNumber(match[1])
+		if err == nil {
+			if len(match[2]) == 0 {
+				return Value{Raw: raw, Type: NUMBER, Value: number}
+			}
+			if unit, found := findUnit(match[2]); found {
+				output := Value{Raw: raw, Type: QUANTITY, Value: number, Unit: unit.symbol}
+				if len(unit.si) > 0 {
+					converted := number * unit.factor
+					output.SIValue = &converted
+					output.SIUnit = unit.si
+				}
+				return output
+			}
+		}
+	}
+	return Value{Raw: raw, Type: TEXT, Value: value}
+}
+
+// ParseIdentifier - parses a value that identifies something, like a registration or type-approval number,
+// which is kept as text even when it consists of digits only
+func ParseIdentifier(raw string) Value {
+	value := strings.Join(strings.Fields(raw), " ")
+	if contains(emptyValues, strings.ToLower(value)) {
+		return Value{Raw: raw, Type: EMPTY}
+	}
+	return Value{Raw: raw, Type: TEXT, Value: value}
+}
+
+// ParseNumber - parses a number with "." as thousands separator and "," as decimal separator
+func ParseNumber(value string) (float64, error) {
+	value = strings.ReplaceAll(strings.TrimSpace(value), ".", "")
+	return strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+}
+
+// parseDate - parses a day-month-year date into its ISO 8601 form
+func parseDate(value string) (string, bool) {
+	match := datePattern.FindStringSubmatch(value)
+	if match == nil {
+		return "", false
+	}
+	date, err := time.Parse("2-1-2006", match[1]+"-"+match[2]+"-"+match[3])
+	if err != nil {
+		return "", false
+	}
+	return date.Format("2006-01-02"), true
+}
+
+// parseDateTime - parses a day-month-year date with a time of day as Danish local time
+func parseDateTime(value string) (time.Time, bool) {
+	match := dateTimePattern.FindStringSubmatch(value)
+	if match == nil {
+		return time.Time{}, false
+	}
+	seconds := match[6]
+	if len(seconds) == 0 {
+		seconds = "00"
+	}
+	dateTime, err := time.ParseInLocation(
+		"2-1-2006 15:04:05",
+		match[1]+"-"+match[2]+"-"+match[3]+" "+match[4]+":"+match[5]+":"+seconds,
+		Location,
+	)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return dateTime, true
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+func mustLoadLocation(name string) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return location
+}
@@ -0,0 +1,78 @@
+package danish
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		raw     string
+		kind    string
+		value   interface{}
+		unit    string
+		siValue float64
+		siUnit  string
+	}{
+		{raw: "", kind: EMPTY},
+		{raw: " - ", kind: EMPTY},
+		{raw: "Ikke oplyst", kind: EMPTY},
+		{raw: "Ja", kind: BOOLEAN, value: true},
+		{raw: "NEJ", kind: BOOLEAN, value: false},
+		{raw: "01-02-2015", kind: DATE, value: "2015-02-01"},
+		{raw: "1.2.2015", kind: DATE, value: "2015-02-01"},
+		{raw: "31-02-2015", kind: TEXT, value: "31-02-2015"},
+		{raw: "01-07-2020 kl. 14:30", kind: DATETIME, value: "2020-07-01T14:30:00+02:00"},
+		{raw: "01-01-2020 08.15.30", kind: DATETIME, value: "2020-01-01T08:15:30+01:00"},
+		{raw: "42", kind: NUMBER, value: 42.0},
+		{raw: "0", kind: NUMBER, value: 0.0},
+		{raw: "0,5", kind: NUMBER, value: 0.5},
+		{raw: "-1.234,5", kind: NUMBER, value: -1234.5},
+		{raw: "0012345", kind: TEXT, value: "0012345"},
+		{raw: "1.234,5 kg", kind: QUANTITY, value: 1234.5, unit: "kg", siValue: 1234.5, siUnit: "kg"},
+		{raw: "1.395 ccm", kind: QUANTITY, value: 1395.0, unit: "ccm", siValue: 0.001395, siUnit: "m³"},
+		{raw: "90 km/t", kind: QUANTITY, value: 90.0, unit: "km/t", siValue: 25, siUnit: "m/s"},
+		{raw: "110 kW", kind: QUANTITY, value: 110.0, unit: "kW", siValue: 110000, siUnit: "W"},
+		{raw: "72 dB(A)", kind: QUANTITY, value: 72.0, unit: "dB(A)"},
+		{raw: "3 døre", kind: TEXT, value: "3 døre"},
+		{raw: "  Personbil \n M1 ", kind: TEXT, value: "Personbil M1"},
+	}
+	for _, test := range tests {
+		parsed := Parse(test.raw)
+		if parsed.Raw != test.raw || parsed.Type != test.kind || !reflect.DeepEqual(parsed.Value, test.value) {
+			t.Errorf("%q: expected %s %v, got %s %v", test.raw, test.kind, test.value, parsed.Type, parsed.Value)
+			continue
+		}
+		if parsed.Unit != test.unit || parsed.SIUnit != test.siUnit {
+			t.Errorf("%q: expected unit %q (%q), got %q (%q)", test.raw, test.unit, test.siUnit, parsed.Unit, parsed.SIUnit)
+		}
+		if len(test.siUnit) > 0 && (parsed.SIValue == nil || math.Abs(*parsed.SIValue-test.siValue) > 1e-9) {
+			t.Errorf("%q: expected the si value %v, got %v", test.raw, test.siValue, parsed.SIValue)
+		}
+		if len(test.siUnit) == 0 && parsed.SIValue != nil {
+			t.Errorf("%q: expected no si value, got %v", test.raw, *parsed.SIValue)
+		}
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected float64
+	}{
+		{"1", 1},
+		{"1.234", 1234},
+		{"1.234.567,89", 1234567.89},
+		{" 12,5 ", 12.5},
+	}
+	for _, test := range tests {
+		number, err := ParseNumber(test.value)
+		if err != nil || number != test.expected {
+			t.Errorf("%q: expected %v, got %v (%v)", test.value, test.expected, number, err)
+		}
+	}
+	if _, err := ParseNumber("12a"); err == nil {
+		t.Error("expected an invalid number to fail")
+	}
+}
@@ -1,67 +1,27 @@
 package scrape
 
-import (
-	"context"
-	_ "embed"
-	"encoding/base64"
-	"github.com/chromedp/chromedp"
-	"github.com/samber/lo"
-	"time"
-)
+// DmrSource - the name of the danish motor register as a source of scraped data
+const DmrSource = "dmr"
 
-//go:embed ScrapeVehicle.js
-var scrapeVehicleScript string
+// DmrVehicleRecipe - the recipe describing how a vehicle is scraped from DMR
+const DmrVehicleRecipe = "dmr-vehicle"
 
-func toBase64(b []byte) string {
-	return base64.StdEncoding.EncodeToString(b)
-}
-
-func ScrapeVehicle(searchType string, value string, timeout time.Duration) (map[string]interface{}, error) {
-
-	ctx, cancel := chromedp.NewContext(context.Background())
-	defer cancel()
-
-	ctx, cancel = context.WithTimeout(ctx, timeout)
-	defer cancel()
-
-	var output = map[string]interface{}{}
-	var res = map[string]interface{}{}
-	var image []byte
-	chromedp.UserAgent("Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/104.0.0.0 Safari/537.36")
-
-	err := chromedp.Run(ctx,
-		chromedp.Navigate(`https://motorregister.skat.dk/dmr-kerne/koeretoejdetaljer/visKoeretoej`),
-		chromedp.WaitReady(searchType),
-		chromedp.Click(searchType),
-		chromedp.SetValue("#soegeord", value),
-		chromedp.Submit("#searchForm"),
-		chromedp.WaitReady("#visKTTabset"),
-		chromedp.FullScreenshot(&image, 90),
-		chromedp.Evaluate(scrapeVehicleScript, &res),
-	)
-	if err != nil {
-		return map[string]interface{}{}, err
+// ScrapeVehicle - scrapes the queried tabs of a vehicle from DMR, if no tabs are queried every available tab is scraped
+func ScrapeVehicle(query VehicleQuery, options RunOptions) (map[string]interface{}, error) {
+	tabs := query.Tabs
+	if len(tabs) == 0 {
+		tabs = AllVehicleTabs
 	}
-
-	output = res
-	output["vehicle_image"] = toBase64(image)
-
-	res = map[string]interface{}{}
-	image = []byte{}
-
-	err = chromedp.Run(ctx,
-		chromedp.Click("#li-visKTTabset-1 a"),
-		chromedp.WaitReady("#visKTTabset"),
-		chromedp.FullScreenshot(&image, 90),
-		chromedp.Evaluate(scrapeVehicleScript, &res),
-	)
-
-	if err != nil {
-		return map[string]interface{}{}, err
+	tabNames := []interface{}{}
+	for _, tab := range tabs {
+		tabNames = append(tabNames, tab.String())
 	}
 
-	output = lo.Assign(output, res)
-	output["technical_details_image"] = toBase64(image)
-
-	return output, nil
+	options.Profile = query.Profile
+	options.Screenshot = query.Screenshot
+	return RunRecipe(DmrVehicleRecipe, map[string]interface{}{
+		"search_selector": query.SearchType.selector,
+		"value":           query.Value,
+		"tabs":            tabNames,
+	}, options)
 }
@@ -0,0 +1,262 @@
+package fixture
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const manifestName = "manifest.json"
+
+// skippedHeaders - headers that no longer describe the body once it is stored decoded
+var skippedHeaders = []string{"content-encoding", "content-length", "transfer-encoding", "connection"}
+
+// Entry - a recorded response to a request
+type Entry struct {
+	Method      string            `json:"method"`
+	URL         string            `json:"url"`
+	RequestHash string            `json:"request_hash,omitempty"`
+	Status      int               `json:"status"`
+	Headers     map[string]string `json:"headers"`
+	Body        string            `json:"body"`
+}
+
+// Manifest - describes the scrape a fixture archive was recorded from and the responses it holds
+type Manifest struct {
+	Recipe     string                 `json:"recipe"`
+	Engine     string                 `json:"engine"`
+	Variables  map[string]interface{} `json:"variables"`
+	RecordedAt time.Time              `json:"recorded_at"`
+	Entries    []Entry                `json:"entries"`
+}
+
+// Archive - the responses a scrape saw, stored as a zip of a manifest and the response bodies
+type Archive struct {
+	Manifest Manifest
+	bodies   map[string][]byte
+}
+
+// Open - reads a fixture archive from a file
+func Open(filename string) (*Archive, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return Read(bytes.NewReader(data), int64(len(data)))
+}
+
+// Read - reads a fixture archive
+func Read(r io.ReaderAt, size int64) (*Archive, error) {
+	reader, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fixture archive: %w", err)
+	}
+	archive := &Archive{
+		bodies: map[string][]byte{},
+	}
+	for _, file := range reader.File {
+		content, err := readZipFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if file.Name == manifestName {
+			err = json.Unmarshal(content, &archive.Manifest)
+			if err != nil {
+				return nil, fmt.Errorf("invalid fixture manifest: %w", err)
+			}
+			continue
+		}
+		archive.bodies[file.Name] = content
+	}
+	if len(archive.Manifest.Recipe) == 0 {
+		return nil, errors.New("fixture archive has no manifest")
+	}
+	return archive, nil
+}
+
+func readZipFile(file *zip.File) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// Write - writes the archive as a zip
+func (a *Archive) Write(w io.Writer) error {
+	archive := zip.NewWriter(w)
+	manifest, err := json.MarshalIndent(a.Manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	files := map[string][]byte{manifestName: manifest}
+	for name, body := range a.bodies {
+		files[name] = body
+	}
+	for name, content := range files {
+		file, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     name,
+			Method:   zip.Deflate,
+			Modified: a.Manifest.RecordedAt,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = file.Write(content)
+		if err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// Save - writes the archive to a file
+func (a *Archive) Save(filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	err = a.Write(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Replayer - serves the responses of the archive, repeated requests get the responses in the order they
+// were recorded and the last one once the recorded ones are used up
+func (a *Archive) Replayer() *Replayer {
+	return &Replayer{
+		archive: a,
+		served:  map[string]int{},
+	}
+}
+
+// Replayer - the state of one replay of an archive
+type Replayer struct {
+	archive *Archive
+	lock    sync.Mutex
+	served  map[string]int
+}
+
+// Find - the recorded response to the request
+func (r *Replayer) Find(method string, url string, requestBody []byte) (Entry, []byte, bool) {
+	key := requestKey(method, url, hashBody(requestBody))
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	matches := []Entry{}
+	for _, entry := range r.archive.Manifest.Entries {
+		if requestKey(entry.Method, entry.URL, entry.RequestHash) == key {
+			matches = append(matches, entry)
+		}
+	}
+	if len(matches) == 0 {
+		return Entry{}, nil, false
+	}
+	index := r.served[key]
+	if index >= len(matches) {
+		index = len(matches) - 1
+	}
+	r.served[key]++
+	entry := matches[index]
+	return entry, r.archive.bodies[entry.Body], true
+}
+
+// Recorder - collects the responses of a scrape into a new archive
+type Recorder struct {
+	lock    sync.Mutex
+	archive *Archive
+}
+
+// NewRecorder - creates a recorder with an empty archive
+func NewRecorder() *Recorder {
+	return &Recorder{
+		archive: &Archive{
+			Manifest: Manifest{
+				Entries:    []Entry{},
+				RecordedAt: time.Now(),
+			},
+			bodies: map[string][]byte{},
+		},
+	}
+}
+
+// Describe - stores the recipe and variables the recorded scrape was run with
+func (r *Recorder) Describe(recipe string, engine string, variables map[string]interface{}) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.archive.Manifest.Recipe = recipe
+	r.archive.Manifest.Engine = engine
+	r.archive.Manifest.Variables = variables
+}
+
+// Add - records a response, the body has to be decoded already
+func (r *Recorder) Add(method string, url string, requestBody []byte, status int, headers map[string]string, body []byte) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	name := fmt.Sprintf("bodies/%04d", len(r.archive.Manifest.Entries)+1)
+	stored := map[string]string{}
+	for key, value := range headers {
+		if !isSkippedHeader(key) {
+			stored[key] = value
+		}
+	}
+	r.archive.Manifest.Entries = append(r.archive.Manifest.Entries, Entry{
+		Method:      strings.ToUpper(method),
+		URL:         url,
+		RequestHash: hashBody(requestBody),
+		Status:      status,
+		Headers:     stored,
+		Body:        name,
+	})
+	r.archive.bodies[name] = body
+}
+
+// Archive - the recorded archive
+func (r *Recorder) Archive() *Archive {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.archive
+}
+
+// FlattenHeaders - joins repeated headers like a browser reports them
+func FlattenHeaders(headers http.Header) map[string]string {
+	output := map[string]string{}
+	for key, values := range headers {
+		output[key] = strings.Join(values, ", ")
+	}
+	return output
+}
+
+func isSkippedHeader(name string) bool {
+	for _, skipped := range skippedHeaders {
+		if strings.EqualFold(name, skipped) {
+			return true
+		}
+	}
+	return false
+}
+
+func requestKey(method string, url string, hash string) string {
+	return strings.ToUpper(method) + " " + url + " " + hash
+}
+
+// hashBody - identifies a request body, requests without a body have an empty hash
+func hashBody(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,76 @@
+package fixture
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// RecordingTransport - records every response passing through the base transport
+type RecordingTransport struct {
+	Base     http.RoundTripper
+	Recorder *Recorder
+}
+
+func (t RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	requestBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	res, err := t.Base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	t.Recorder.Add(req.Method, req.URL.String(), requestBody, res.StatusCode, FlattenHeaders(res.Header), body)
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	return res, nil
+}
+
+// ReplayTransport - answers requests from a fixture archive without touching the network
+type ReplayTransport struct {
+	Replayer *Replayer
+}
+
+func (t ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	requestBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	entry, body, found := t.Replayer.Find(req.Method, req.URL.String(), requestBody)
+	if !found {
+		return nil, fmt.Errorf("no recorded response for %s %s", req.Method, req.URL.Redacted())
+	}
+	header := http.Header{}
+	for key, value := range entry.Headers {
+		header.Set(key, value)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", entry.Status, http.StatusText(entry.Status)),
+		StatusCode:    entry.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.GetBody == nil {
+		return nil, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
@@ -0,0 +1,319 @@
+package har
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Redacted - replaces the values of redacted headers
+const Redacted = "[REDACTED]"
+
+// DefaultRedactedHeaders - the headers carrying credentials, redacted unless other rules are given
+var DefaultRedactedHeaders = []string{"authorization", "proxy-authorization", "cookie", "set-cookie", "x-api-key"}
+
+// Options - the size caps and redaction rules of a capture
+type Options struct {
+	// MaxEntries - requests after the first max entries are not captured, 0 captures every request
+	MaxEntries int
+	// MaxBodySize - response bodies larger than this are left out, 0 leaves out every body
+	MaxBodySize int
+	// MaxSize - the bytes of all response bodies kept, bodies exceeding the budget are left out
+	MaxSize int
+	// Redact - patterns of header names whose values are redacted, matched case-insensitively with path.Match
+	Redact []string
+	// Version - the version of the application written as the creator of the file
+	Version string
+}
+
+// ParseRedactRules - parses a comma separated list of header name patterns, an empty list is nil so the
+// default rules are used
+func ParseRedactRules(value string) []string {
+	var output []string
+	for _, pattern := range strings.Split(value, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if len(pattern) > 0 {
+			output = append(output, pattern)
+		}
+	}
+	return output
+}
+
+// pending - an entry waiting for its response to finish, times are monotonic seconds of the browser
+type pending struct {
+	entry       *Entry
+	requestTime float64
+	headersEnd  float64
+}
+
+// Recorder - collects the requests of a session into the entries of a HAR file, requests are identified by
+// the id the browser gives them
+type Recorder struct {
+	options   Options
+	lock      sync.Mutex
+	entries   []*Entry
+	pending   map[string]*pending
+	finished  map[string]*Entry
+	dropped   int
+	bodyBytes int
+	omitted   int
+}
+
+// NewRecorder - creates a recorder for a single session, without redaction rules the default rules are used
+func NewRecorder(options Options) *Recorder {
+	if options.Redact == nil {
+		options.Redact = DefaultRedactedHeaders
+	}
+	return &Recorder{
+		options:  options,
+		pending:  map[string]*pending{},
+		finished: map[string]*Entry{},
+	}
+}
+
+// Start - captures a new request sent at the wall time, the monotonic time is the base of its timings
+func (r *Recorder) Start(id string, request Request, resourceType string, startedAt time.Time, monotonic float64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.options.MaxEntries > 0 && len(r.entries) >= r.options.MaxEntries {
+		r.dropped++
+		return
+	}
+	request.Headers = r.redact(request.Headers)
+	if request.Cookies == nil {
+		request.Cookies = []Cookie{}
+	}
+	request.QueryString = queryString(request.URL)
+	if request.HeadersSize == 0 {
+		request.HeadersSize = -1
+	}
+	entry := &Entry{
+		StartedDateTime: startedAt,
+		Request:         request,
+		Response: Response{
+			Cookies:     []Cookie{},
+			Headers:     []NameValue{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		ResourceType: resourceType,
+		Timings:      Timings{Blocked: -1, DNS: -1, Connect: -1, Send: 0, Wait: 0, Receive: 0, SSL: -1},
+	}
+	r.entries = append(r.entries, entry)
+	r.pending[id] = &pending{entry: entry, requestTime: monotonic}
+}
+
+// Redirect - completes the pending request with the redirect answering it, the browser sends the request the
+// redirect points to with the same id
+func (r *Recorder) Redirect(id string, response Response, timings Timings, monotonic float64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	current, found := r.pending[id]
+	if !found {
+		return
+	}
+	r.respond(current, response, timings, monotonic)
+	current.entry.Time = current.entry.Timings.Total()
+	delete(r.pending, id)
+}
+
+// Respond - adds the response headers received at the monotonic time to the pending request
+func (r *Recorder) Respond(id string, response Response, timings Timings, serverIP string, headersEnd float64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	current, found := r.pending[id]
+	if !found {
+		return
+	}
+	r.respond(current, response, timings, headersEnd)
+	current.entry.ServerIPAddress = serverIP
+}
+
+func (r *Recorder) respond(current *pending, response Response, timings Timings, headersEnd float64) {
+	response.Headers = r.redact(response.Headers)
+	if response.Cookies == nil {
+		response.Cookies = []Cookie{}
+	}
+	if response.HeadersSize == 0 {
+		response.HeadersSize = -1
+	}
+	response.BodySize = -1
+	if len(current.entry.Request.HTTPVersion) == 0 {
+		current.entry.Request.HTTPVersion = response.HTTPVersion
+	}
+	current.entry.Response = response
+	current.entry.Timings = timings
+	current.headersEnd = headersEnd
+}
+
+// Finish - completes the request when its body was received, the size is the number of bytes transferred with
+// the headers. The body size is unknown when the size of the headers is.
+func (r *Recorder) Finish(id string, encodedSize int64, monotonic float64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	current, found := r.pending[id]
+	if !found {
+		return
+	}
+	if current.headersEnd > 0 && monotonic > current.headersEnd {
+		current.entry.Timings.Receive = milliseconds(monotonic - current.headersEnd)
+	}
+	current.entry.Time = current.entry.Timings.Total()
+	if headers := current.entry.Response.HeadersSize; headers > 0 && encodedSize >= headers {
+		current.entry.Response.BodySize = encodedSize - headers
+	}
+	delete(r.pending, id)
+	r.finished[id] = current.entry
+}
+
+// Fail - completes a request that failed, like a blocked request or a dropped connection
+func (r *Recorder) Fail(id string, reason string, monotonic float64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	current, found := r.pending[id]
+	if !found {
+		return
+	}
+	current.entry.Error = reason
+	if monotonic > current.requestTime {
+		current.entry.Time = milliseconds(monotonic - current.requestTime)
+	}
+	delete(r.pending, id)
+}
+
+// WantsBody - if a body of the size still fits the caps, so it is worth fetching
+func (r *Recorder) WantsBody(size int64) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.fits(size)
+}
+
+func (r *Recorder) fits(size int64) bool {
+	if size > int64(r.options.MaxBodySize) {
+		return false
+	}
+	return r.options.MaxSize <= 0 || int64(r.bodyBytes)+size <= int64(r.options.MaxSize)
+}
+
+// Body - adds the body of the finished request, bodies not fitting the caps are left out and binary bodies are
+// written base64 encoded
+func (r *Recorder) Body(id string, body []byte) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	entry, found := r.finished[id]
+	if !found {
+		return
+	}
+	delete(r.finished, id)
+	if !r.fits(int64(len(body))) {
+		r.omit(entry)
+		return
+	}
+	r.bodyBytes += len(body)
+	entry.Response.Content.Size = int64(len(body))
+	if utf8.Valid(body) {
+		entry.Response.Content.Text = string(body)
+		return
+	}
+	entry.Response.Content.Text = base64.StdEncoding.EncodeToString(body)
+	entry.Response.Content.Encoding = "base64"
+}
+
+// OmitBody - notes that the body of the finished request was left out
+func (r *Recorder) OmitBody(id string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	entry, found := r.finished[id]
+	if !found {
+		return
+	}
+	delete(r.finished, id)
+	r.omit(entry)
+}
+
+func (r *Recorder) omit(entry *Entry) {
+	if r.options.MaxBodySize > 0 {
+		entry.Response.Content.Comment = "body left out by the size caps"
+		r.omitted++
+	}
+}
+
+// Len - the number of captured entries
+func (r *Recorder) Len() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return len(r.entries)
+}
+
+// Encode - the captured entries as a HAR file, requests still pending are written without a response
+func (r *Recorder) Encode() ([]byte, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	file := File{Log: Log{
+		Version: Version,
+		Creator: Creator{Name: "go-scrape-this", Version: r.options.Version},
+		Entries: make([]Entry, 0, len(r.entries)),
+	}}
+	for _, entry := range r.entries {
+		file.Log.Entries = append(file.Log.Entries, *entry)
+	}
+	comments := []string{}
+	if r.dropped > 0 {
+		comments = append(comments, fmt.Sprintf("%d requests after the first %d were not captured", r.dropped, r.options.MaxEntries))
+	}
+	if r.omitted > 0 {
+		comments = append(comments, fmt.Sprintf("%d response bodies were left out", r.omitted))
+	}
+	file.Log.Comment = strings.Join(comments, ", ")
+	return json.Marshal(file)
+}
+
+// redact - replaces the values of the headers matching a redaction rule
+func (r *Recorder) redact(headers []NameValue) []NameValue {
+	output := make([]NameValue, 0, len(headers))
+	for _, header := range headers {
+		name := strings.ToLower(header.Name)
+		for _, pattern := range r.options.Redact {
+			if matched, err := path.Match(strings.ToLower(pattern), name); err == nil && matched {
+				header.Value = Redacted
+				break
+			}
+		}
+		output = append(output, header)
+	}
+	return output
+}
+
+// milliseconds - the seconds in milliseconds, rounded to microseconds
+func milliseconds(seconds float64) float64 {
+	return math.Round(seconds*1000000) / 1000
+}
+
+func queryString(rawURL string) []NameValue {
+	output := []NameValue{}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return output
+	}
+	for _, pair := range strings.Split(parsed.RawQuery, "&") {
+		if len(pair) == 0 {
+			continue
+		}
+		name, value, _ := strings.Cut(pair, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if unescaped, err := url.QueryUnescape(value); err == nil {
+			value = unescaped
+		}
+		output = append(output, NameValue{Name: name, Value: value})
+	}
+	return output
+}
@@ -0,0 +1,211 @@
+package har
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// record - captures a finished request for every body, the bodies are added in order
+func record(recorder *Recorder, bodies ...string) {
+	started := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i, body := range bodies {
+		id := string(rune('a' + i))
+		recorder.Start(id, Request{Method: "GET", URL: "https://example.com/" + id + "?q=1"}, "Document", started, 1)
+		recorder.Respond(id, Response{Status: 200, StatusText: "OK", HTTPVersion: "HTTP/1.1", HeadersSize: 100}, Timings{Send: 1, Wait: 2}, "127.0.0.1", 1.5)
+		recorder.Finish(id, int64(100+len(body)), 2)
+		if recorder.WantsBody(int64(len(body))) {
+			recorder.Body(id, []byte(body))
+		} else {
+			recorder.OmitBody(id)
+		}
+	}
+}
+
+func decode(t *testing.T, recorder *Recorder) File {
+	data, err := recorder.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file File
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestRecorderCaps(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  Options
+		bodies   []string
+		entries  int
+		captured []bool
+		comment  string
+	}{
+		{
+			name:     "without caps every body is left out",
+			options:  Options{},
+			bodies:   []string{"one", "two"},
+			entries:  2,
+			captured: []bool{false, false},
+		},
+		{
+			name:     "bodies up to the max body size",
+			options:  Options{MaxBodySize: 4},
+			bodies:   []string{"one", "three", "four"},
+			entries:  3,
+			captured: []bool{true, false, true},
+			comment:  "1 response bodies were left out",
+		},
+		{
+			name:     "bodies within the total budget",
+			options:  Options{MaxBodySize: 10, MaxSize: 7},
+			bodies:   []string{"one", "two", "six"},
+			entries:  3,
+			captured: []bool{true, true, false},
+			comment:  "1 response bodies were left out",
+		},
+		{
+			name:     "requests after the max entries",
+			options:  Options{MaxEntries: 2, MaxBodySize: 10},
+			bodies:   []string{"one", "two", "six"},
+			entries:  2,
+			captured: []bool{true, true},
+			comment:  "1 requests after the first 2 were not captured",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := NewRecorder(test.options)
+			record(recorder, test.bodies...)
+			file := decode(t, recorder)
+			if len(file.Log.Entries) != test.entries {
+				t.Fatalf("expected %d entries, got %d", test.entries, len(file.Log.Entries))
+			}
+			for i, entry := range file.Log.Entries {
+				captured := len(entry.Response.Content.Text) > 0
+				if captured != test.captured[i] {
+					t.Errorf("expected body %d captured %v, got %v", i, test.captured[i], captured)
+				}
+				if captured && entry.Response.Content.Text != test.bodies[i] {
+					t.Errorf("expected body %q, got %q", test.bodies[i], entry.Response.Content.Text)
+				}
+			}
+			if file.Log.Comment != test.comment {
+				t.Errorf("expected comment %q, got %q", test.comment, file.Log.Comment)
+			}
+		})
+	}
+}
+
+func TestRecorderRedaction(t *testing.T) {
+	tests := []struct {
+		name     string
+		redact   []string
+		header   string
+		redacted bool
+	}{
+		{"default rules", nil, "Authorization", true},
+		{"default rules match any case", nil, "COOKIE", true},
+		{"default rules keep other headers", nil, "Accept", false},
+		{"pattern", []string{"x-*-token"}, "X-Session-Token", true},
+		{"pattern replaces the default rules", []string{"x-*-token"}, "Authorization", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := NewRecorder(Options{Redact: test.redact})
+			headers := []NameValue{{Name: test.header, Value: "secret"}}
+			recorder.Start("a", Request{Method: "GET", URL: "https://example.com/", Headers: headers}, "Document", time.Now(), 1)
+			recorder.Respond("a", Response{Status: 200, Headers: headers}, Timings{}, "", 1)
+			file := decode(t, recorder)
+			expected := "secret"
+			if test.redacted {
+				expected = Redacted
+			}
+			entry := file.Log.Entries[0]
+			if value := entry.Request.Headers[0].Value; value != expected {
+				t.Errorf("expected request header %q, got %q", expected, value)
+			}
+			if value := entry.Response.Headers[0].Value; value != expected {
+				t.Errorf("expected response header %q, got %q", expected, value)
+			}
+		})
+	}
+}
+
+func TestRecorderEncodesValidHAR(t *testing.T) {
+	recorder := NewRecorder(Options{MaxBodySize: 100, Version: "test"})
+	record(recorder, "<html></html>", "\xff\xfe")
+	recorder.Start("c", Request{Method: "GET", URL: "https://example.com/blocked"}, "Image", time.Now(), 1)
+	recorder.Fail("c", "net::ERR_BLOCKED_BY_CLIENT", 1.25)
+	recorder.Start("d", Request{Method: "GET", URL: "https://example.com/pending"}, "XHR", time.Now(), 1)
+
+	data, err := recorder.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// HAR readers expect the arrays and sizes to be present, even when empty or unknown
+	var raw map[string]map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	if raw["log"]["version"] != Version {
+		t.Errorf("expected version %s, got %v", Version, raw["log"]["version"])
+	}
+	entries, _ := raw["log"]["entries"].([]interface{})
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(entries))
+	}
+	for i, item := range entries {
+		entry := item.(map[string]interface{})
+		for _, section := range []string{"request", "response"} {
+			fields := entry[section].(map[string]interface{})
+			for _, name := range []string{"cookies", "headers", "headersSize", "bodySize", "httpVersion"} {
+				if _, found := fields[name]; !found {
+					t.Errorf("entry %d: %s is missing %s", i, section, name)
+				}
+			}
+		}
+		for _, name := range []string{"startedDateTime", "time", "cache", "timings"} {
+			if _, found := entry[name]; !found {
+				t.Errorf("entry %d is missing %s", i, name)
+			}
+		}
+	}
+
+	file := decode(t, recorder)
+	document := file.Log.Entries[0]
+	if document.Response.BodySize != int64(len("<html></html>")) {
+		t.Errorf("expected the body size without the headers, got %d", document.Response.BodySize)
+	}
+	if document.Time != 503 {
+		t.Errorf("expected a time of 503ms, got %v", document.Time)
+	}
+	if len(document.Request.QueryString) != 1 || document.Request.QueryString[0] != (NameValue{Name: "q", Value: "1"}) {
+		t.Errorf("unexpected query string %v", document.Request.QueryString)
+	}
+	binary := file.Log.Entries[1].Response.Content
+	if binary.Encoding != "base64" || binary.Text != "//4=" {
+		t.Errorf("expected the binary body base64 encoded, got %v", binary)
+	}
+	blocked := file.Log.Entries[2]
+	if !strings.Contains(blocked.Error, "BLOCKED") || blocked.Time != 250 || blocked.Response.BodySize != -1 {
+		t.Errorf("unexpected failed entry %v", blocked)
+	}
+	if pending := file.Log.Entries[3]; pending.Response.Status != 0 || pending.Response.HeadersSize != -1 {
+		t.Errorf("unexpected pending entry %v", pending)
+	}
+}
+
+func TestRecorderBodySizeWithoutHeadersSize(t *testing.T) {
+	recorder := NewRecorder(Options{})
+	recorder.Start("a", Request{Method: "GET", URL: "https://example.com/"}, "Document", time.Now(), 1)
+	recorder.Respond("a", Response{Status: 200}, Timings{}, "", 1)
+	recorder.Finish("a", 512, 2)
+	file := decode(t, recorder)
+	if size := file.Log.Entries[0].Response.BodySize; size != -1 {
+		t.Errorf("expected an unknown body size, got %d", size)
+	}
+}
@@ -0,0 +1,126 @@
+package scrape
+
+import (
+	"fmt"
+	"go-scrape-this/server/app/scrape/danish"
+	"go-scrape-this/server/app/utils"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HistoryEvent - an entry of the history of a vehicle, like a change of registration number or owner
+type HistoryEvent struct {
+	Tab     string            `json:"tab"`
+	Type    string            `json:"type"`
+	Date    *time.Time        `json:"date"`
+	Details map[string]string `json:"details"`
+}
+
+// dateLabels - parts of labels naming the date an event happened on, preferred over other dates of the event
+var dateLabels = []string{"dato", "fra", "gyldig", "ændret"}
+
+// VehicleHistory - the history events of every tab of a scraped vehicle
+func VehicleHistory(result map[string]interface{}) []HistoryEvent {
+	output := []HistoryEvent{}
+	tabs := []string{}
+	for tab := range result {
+		tabs = append(tabs, tab)
+	}
+	sort.Strings(tabs)
+	for _, tab := range tabs {
+		section, ok := result[tab].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		entries, ok := section["history"].([]interface{})
+		if !ok {
+			continue
+		}
+		for _, entry := range entries {
+			event, ok := historyEvent(tab, entry)
+			if ok {
+				output = append(output, event)
+			}
+		}
+	}
+	return output
+}
+
+func historyEvent(tab string, entry interface{}) (HistoryEvent, bool) {
+	fields, ok := entry.(map[string]interface{})
+	if !ok {
+		return HistoryEvent{}, false
+	}
+	details, ok := fields["details"].(map[string]interface{})
+	if !ok || len(details) == 0 {
+		return HistoryEvent{}, false
+	}
+	event := HistoryEvent{
+		Tab:     tab,
+		Type:    strings.TrimSpace(fmt.Sprint(fields["type"])),
+		Details: map[string]string{},
+	}
+	for label, value := range details {
+		event.Details[label] = strings.TrimSpace(fmt.Sprint(value))
+	}
+	event.Date = historyDate(event.Details)
+	return event, true
+}
+
+// historyDate - the date of an event, a date with a date label wins over the first date in label order
+func historyDate(details map[string]string) *time.Time {
+	labels := []string{}
+	for label := range details {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	var first *time.Time
+	for _, label := range labels {
+		date := parseHistoryDate(details[label])
+		if date == nil {
+			continue
+		}
+		lower := strings.ToLower(label)
+		for _, dateLabel := range dateLabels {
+			if strings.Contains(lower, dateLabel) {
+				return date
+			}
+		}
+		if first == nil {
+			first = date
+		}
+	}
+	return first
+}
+
+func parseHistoryDate(value string) *time.Time {
+	parsed := danish.Parse(value)
+	var date time.Time
+	var err error
+	switch parsed.Type {
+	case danish.DATE:
+		date, err = time.ParseInLocation("2006-01-02", parsed.Value.(string), danish.Location)
+	case danish.DATETIME:
+		date, err = time.Parse(time.RFC3339, parsed.Value.(string))
+	default:
+		return nil
+	}
+	if err != nil {
+		return nil
+	}
+	return &date
+}
+
+// VehicleVin - the VIN of a scraped vehicle, empty if the result has none
+func VehicleVin(result map[string]interface{}) string {
+	for key, value := range utils.Flatten(result, ".") {
+		if !strings.HasSuffix(key, ".Stelnummer") {
+			continue
+		}
+		if vin, err := VIN.Normalize(value); err == nil {
+			return vin
+		}
+	}
+	return ""
+}
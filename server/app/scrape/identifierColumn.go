@@ -0,0 +1,142 @@
+package scrape
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var headerReplacer = strings.NewReplacer(" ", "_", "-", "_", ".", "_")
+
+var identifierHeaders = map[string]*SearchType{
+	"registration_number": REGISTRATION_NUMBER,
+	"registration":        REGISTRATION_NUMBER,
+	"plate":               REGISTRATION_NUMBER,
+	"regnr":               REGISTRATION_NUMBER,
+	"reg_nr":              REGISTRATION_NUMBER,
+	"registreringsnummer": REGISTRATION_NUMBER,
+	"nummerplade":         REGISTRATION_NUMBER,
+	"vin":                 VIN,
+	"stelnummer":          VIN,
+	"stelnr":              VIN,
+	"vehicle_id":          VEHICLE_ID,
+	"koeretoej_id":        VEHICLE_ID,
+	"køretøj_id":          VEHICLE_ID,
+	"køretøjs_id":         VEHICLE_ID,
+}
+
+// IdentifierColumn - the column of a spreadsheet holding the vehicle identifiers
+type IdentifierColumn struct {
+	Index      int
+	SearchType *SearchType
+	HasHeader  bool
+}
+
+func normalizeHeader(value string) string {
+	return headerReplacer.Replace(strings.ToLower(strings.TrimSpace(value)))
+}
+
+// DetectIdentifierColumn - finds the identifier column by its header, or by the amount of values that are
+// valid identifiers if there is no known header. The search type and column may be given to narrow the search,
+// a column is either a header name or a 1 based column number.
+func DetectIdentifierColumn(rows [][]string, searchType *SearchType, column string) (IdentifierColumn, error) {
+	if len(rows) == 0 {
+		return IdentifierColumn{}, errors.New("spreadsheet contains no rows")
+	}
+
+	candidates := []int{}
+	column = strings.TrimSpace(column)
+	if len(column) > 0 {
+		index, err := resolveColumn(rows[0], column)
+		if err != nil {
+			return IdentifierColumn{}, err
+		}
+		candidates = append(candidates, index)
+	} else {
+		for i := 0; i < rowWidth(rows); i++ {
+			candidates = append(candidates, i)
+		}
+	}
+
+	for _, index := range candidates {
+		if index >= len(rows[0]) {
+			continue
+		}
+		headerType, found := identifierHeaders[normalizeHeader(rows[0][index])]
+		if found && (searchType == nil || searchType == headerType) {
+			return IdentifierColumn{
+				Index:      index,
+				SearchType: headerType,
+				HasHeader:  true,
+			}, nil
+		}
+	}
+
+	searchTypes := []*SearchType{VIN, REGISTRATION_NUMBER}
+	if searchType != nil {
+		searchTypes = []*SearchType{searchType}
+	}
+
+	best := IdentifierColumn{Index: -1}
+	bestScore := 0
+	for _, index := range candidates {
+		for _, candidateType := range searchTypes {
+			score := 0
+			for _, row := range rows {
+				if index < len(row) && isIdentifier(candidateType, row[index], searchType != nil) {
+					score++
+				}
+			}
+			if score > bestScore {
+				bestScore = score
+				best = IdentifierColumn{
+					Index:      index,
+					SearchType: candidateType,
+				}
+			}
+		}
+	}
+	if best.Index < 0 {
+		return IdentifierColumn{}, errors.New("could not detect a column with vehicle identifiers")
+	}
+	best.HasHeader = best.Index >= len(rows[0]) || !isIdentifier(best.SearchType, rows[0][best.Index], searchType != nil)
+	return best, nil
+}
+
+// isIdentifier - checks if the value is valid for the search type, detected registration numbers
+// are required to contain a digit as most other short words would be valid registration numbers
+func isIdentifier(searchType *SearchType, value string, explicit bool) bool {
+	normalized, err := searchType.Normalize(value)
+	if err != nil {
+		return false
+	}
+	if !explicit && searchType == REGISTRATION_NUMBER {
+		return strings.IndexFunc(normalized, unicode.IsDigit) >= 0
+	}
+	return true
+}
+
+func resolveColumn(header []string, column string) (int, error) {
+	normalized := normalizeHeader(column)
+	for i := range header {
+		if normalizeHeader(header[i]) == normalized {
+			return i, nil
+		}
+	}
+	number, err := strconv.Atoi(column)
+	if err != nil || number < 1 {
+		return 0, errors.New("unknown column: \"" + column + "\"")
+	}
+	return number - 1, nil
+}
+
+func rowWidth(rows [][]string) int {
+	width := 0
+	for _, row := range rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	return width
+}
@@ -0,0 +1,76 @@
+package scrape
+
+import (
+	"errors"
+	"go-scrape-this/server/app/scrape/recipe"
+	"os"
+	"sort"
+	"sync"
+)
+
+// DefaultProfile - the name of the profile used when neither the recipe nor the job asks for one
+const DefaultProfile = "default"
+
+var (
+	profilesMutex sync.RWMutex
+	profiles      = map[string]recipe.Profile{
+		DefaultProfile: recipe.DefaultProfile,
+	}
+)
+
+// LoadProfiles - loads the named browser profiles of the file, every profile is applied on top of the
+// default profile and a profile named "default" replaces the built-in one
+func LoadProfiles(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	parsed, err := recipe.ParseProfiles(filename, data)
+	if err != nil {
+		return err
+	}
+	loaded := map[string]recipe.Profile{
+		DefaultProfile: recipe.DefaultProfile,
+	}
+	for name, profile := range parsed {
+		loaded[name] = recipe.DefaultProfile.Merge(profile)
+	}
+	profilesMutex.Lock()
+	defer profilesMutex.Unlock()
+	profiles = loaded
+	return nil
+}
+
+// Profile - finds a named profile
+func Profile(name string) (recipe.Profile, error) {
+	profilesMutex.RLock()
+	defer profilesMutex.RUnlock()
+	profile, found := profiles[name]
+	if !found {
+		return recipe.Profile{}, errors.New("unknown profile: \"" + name + "\"")
+	}
+	return profile, nil
+}
+
+// ProfileNames - the sorted names of the loaded profiles
+func ProfileNames() []string {
+	profilesMutex.RLock()
+	defer profilesMutex.RUnlock()
+	output := []string{}
+	for name := range profiles {
+		output = append(output, name)
+	}
+	sort.Strings(output)
+	return output
+}
+
+// resolveProfile - the profile of the job if given, else the profile of the recipe, else the default
+func resolveProfile(found recipe.Recipe, name string) (recipe.Profile, error) {
+	if len(name) == 0 {
+		name = found.Profile
+	}
+	if len(name) == 0 {
+		name = DefaultProfile
+	}
+	return Profile(name)
+}
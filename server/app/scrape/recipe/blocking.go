@@ -0,0 +1,482 @@
+package recipe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// blockableResourceTypes - the resource types requests can be blocked by, documents are left out as blocking
+// them would fail the navigation itself
+var blockableResourceTypes = []network.ResourceType{
+	network.ResourceTypeStylesheet,
+	network.ResourceTypeImage,
+	network.ResourceTypeMedia,
+	network.ResourceTypeFont,
+	network.ResourceTypeScript,
+	network.ResourceTypeTextTrack,
+	network.ResourceTypeXHR,
+	network.ResourceTypeFetch,
+	network.ResourceTypePrefetch,
+	network.ResourceTypeEventSource,
+	network.ResourceTypeWebSocket,
+	network.ResourceTypeManifest,
+	network.ResourceTypeSignedExchange,
+	network.ResourceTypePing,
+	network.ResourceTypeCSPViolationReport,
+	network.ResourceTypePreflight,
+	network.ResourceTypeOther,
+}
+
+// BlockRules - the requests of browser runs that are failed before they are sent, by the resource type of the
+// request or by url patterns where "*" matches any number of characters and "?" a single one
+type BlockRules struct {
+	ResourceTypes []string `json:"resource_types,omitempty" yaml:"resource_types,omitempty"`
+	URLs          []string `json:"urls,omitempty" yaml:"urls,omitempty"`
+}
+
+// IsEmpty - if the rules block nothing
+func (r BlockRules) IsEmpty() bool {
+	return len(r.ResourceTypes) == 0 && len(r.URLs) == 0
+}
+
+// Validate - checks that the resource types are known and can be blocked
+func (r BlockRules) Validate() error {
+	for _, value := range r.ResourceTypes {
+		if _, err := parseResourceType(value); err != nil {
+			return err
+		}
+	}
+	for _, pattern := range r.URLs {
+		if len(strings.TrimSpace(pattern)) == 0 {
+			return errors.New("empty url pattern")
+		}
+	}
+	return nil
+}
+
+// parseResourceType - the resource type named by the value, regardless of case
+func parseResourceType(value string) (network.ResourceType, error) {
+	for _, resourceType := range blockableResourceTypes {
+		if strings.EqualFold(resourceType.String(), strings.TrimSpace(value)) {
+			return resourceType, nil
+		}
+	}
+	return "", errors.New("unknown or unblockable resource type: \"" + value + "\"")
+}
+
+// urlPattern - the url pattern as a regular expression, a backslash escapes the character following it the
+// same way the browser reads the patterns
+func urlPattern(pattern string) *regexp.Regexp {
+	var expression strings.Builder
+	expression.WriteString("^")
+	escaped := false
+	for _, char := range pattern {
+		switch {
+		case escaped:
+			expression.WriteString(regexp.QuoteMeta(string(char)))
+			escaped = false
+		case char == '\\':
+			escaped = true
+		case char == '*':
+			expression.WriteString(".*")
+		case char == '?':
+			expression.WriteString(".")
+		default:
+			expression.WriteString(regexp.QuoteMeta(string(char)))
+		}
+	}
+	expression.WriteString("$")
+	return regexp.MustCompile(expression.String())
+}
+
+// BlockStats - the requests blocked by a run and what blocking them is estimated to have saved
+type BlockStats struct {
+	Requests      int            `json:"requests"`
+	ResourceTypes map[string]int `json:"resource_types"`
+	BytesSaved    int64          `json:"bytes_saved"`
+	// TimeSavedMs - the summed load time of the blocked requests, the page finishes sooner by less as the browser
+	// loads resources in parallel
+	TimeSavedMs int64 `json:"time_saved_ms"`
+	// Unmeasured - blocked requests for which no earlier load was seen and that could not be measured, they are
+	// left out of the savings
+	Unmeasured int `json:"unmeasured"`
+}
+
+// BlockCounter - counts the requests blocked by a run, safe for concurrent use
+type BlockCounter struct {
+	lock      sync.Mutex
+	stats     BlockStats
+	timeSaved time.Duration
+}
+
+func NewBlockCounter() *BlockCounter {
+	return &BlockCounter{stats: BlockStats{ResourceTypes: map[string]int{}}}
+}
+
+func (c *BlockCounter) add(resourceType network.ResourceType, cost resourceCost, measured bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.stats.Requests++
+	c.stats.ResourceTypes[resourceType.String()]++
+	if !measured {
+		c.stats.Unmeasured++
+		return
+	}
+	c.stats.BytesSaved += cost.bytes
+	c.timeSaved += cost.time
+}
+
+// Stats - the counts so far
+func (c *BlockCounter) Stats() BlockStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	output := c.stats
+	output.TimeSavedMs = c.timeSaved.Milliseconds()
+	output.ResourceTypes = map[string]int{}
+	for resourceType, count := range c.stats.ResourceTypes {
+		output.ResourceTypes[resourceType] = count
+	}
+	return output
+}
+
+// measureTimeout - how long measuring the cost of a blocked resource may take
+const measureTimeout = time.Second * 5
+
+// resourceBlocker - decides which requests of a run are blocked by the rules of its recipe. Resources blocked by
+// their type are measured with a HEAD request by the client when no earlier load of the type was seen, unless the
+// client is nil.
+type resourceBlocker struct {
+	types   []network.ResourceType
+	urls    []string
+	matches []*regexp.Regexp
+	counter *BlockCounter
+	costs   *costTable
+
+	client       *http.Client
+	userAgent    string
+	lock         sync.Mutex
+	measuring    map[string][]network.ResourceType
+	unmeasurable map[string]bool
+	measurements sync.WaitGroup
+}
+
+// newResourceBlocker - the blocker of the rules, nil when they block nothing
+func newResourceBlocker(rules BlockRules, counter *BlockCounter, client *http.Client, userAgent string) *resourceBlocker {
+	if rules.IsEmpty() {
+		return nil
+	}
+	blocker := &resourceBlocker{
+		counter:      counter,
+		costs:        learnedCosts,
+		client:       client,
+		userAgent:    userAgent,
+		measuring:    map[string][]network.ResourceType{},
+		unmeasurable: map[string]bool{},
+	}
+	for _, value := range rules.ResourceTypes {
+		// the rules of a recipe are validated when it is loaded
+		if resourceType, err := parseResourceType(value); err == nil {
+			blocker.types = append(blocker.types, resourceType)
+		}
+	}
+	for _, pattern := range rules.URLs {
+		blocker.urls = append(blocker.urls, pattern)
+		blocker.matches = append(blocker.matches, urlPattern(pattern))
+	}
+	return blocker
+}
+
+// blocks - if the request is blocked by the rules
+func (b *resourceBlocker) blocks(resourceType network.ResourceType, target string) bool {
+	if b.blocksType(resourceType) {
+		return true
+	}
+	for _, match := range b.matches {
+		if match.MatchString(target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *resourceBlocker) blocksType(resourceType network.ResourceType) bool {
+	for _, blocked := range b.types {
+		if resourceType == blocked {
+			return true
+		}
+	}
+	return false
+}
+
+// block - counts the blocked request with the cost of its earlier loads, or the one measured for it. Requests
+// blocked by their url are never measured, as url rules keep trackers and the like from being contacted at all.
+func (b *resourceBlocker) block(resourceType network.ResourceType, target string) {
+	if b.counter == nil {
+		return
+	}
+	cost, measured := b.costs.estimate(resourceType, target)
+	if measured || b.client == nil || !b.blocksType(resourceType) {
+		b.counter.add(resourceType, cost, measured)
+		return
+	}
+	b.measure(resourceType, target)
+}
+
+// measure - learns the cost of the resource from the size a HEAD request reports for it, the time is the one
+// the request took. Requests of the url blocked while it is measured are counted once it is, and urls that can't
+// be measured are counted as unmeasured for the rest of the run.
+func (b *resourceBlocker) measure(resourceType network.ResourceType, target string) {
+	key := costKey(target)
+	b.lock.Lock()
+	if b.unmeasurable[key] {
+		b.lock.Unlock()
+		b.counter.add(resourceType, resourceCost{}, false)
+		return
+	}
+	if waiting, found := b.measuring[key]; found {
+		b.measuring[key] = append(waiting, resourceType)
+		b.lock.Unlock()
+		return
+	}
+	b.measuring[key] = []network.ResourceType{resourceType}
+	b.measurements.Add(1)
+	b.lock.Unlock()
+	go func() {
+		defer b.measurements.Done()
+		bytes, duration, err := b.head(target)
+		if err == nil {
+			b.costs.learn(resourceType, target, bytes, duration)
+		}
+		b.lock.Lock()
+		waiting := b.measuring[key]
+		delete(b.measuring, key)
+		if err != nil {
+			b.unmeasurable[key] = true
+		}
+		b.lock.Unlock()
+		for _, blocked := range waiting {
+			b.counter.add(blocked, resourceCost{bytes: bytes, time: duration, loads: 1}, err == nil)
+		}
+	}()
+}
+
+// measureClient - the client measuring blocked resources through the proxy of the profile and restricted to the
+// allowed hosts, nil when the proxy is invalid
+func measureClient(profile Profile, hosts HostAllowlist) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if len(profile.Proxy) > 0 {
+		proxy, err := profile.proxyURL()
+		if err != nil {
+			return nil
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	var roundTripper http.RoundTripper = transport
+	if hosts != nil {
+		roundTripper = hosts.Transport(roundTripper)
+	}
+	return &http.Client{Transport: roundTripper}
+}
+
+// head - the content length of the resource and the time the HEAD request took
+func (b *resourceBlocker) head(target string) (int64, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), measureTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodHead, target, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	if request.URL.Scheme != "http" && request.URL.Scheme != "https" {
+		return 0, 0, errors.New("unsupported scheme: \"" + request.URL.Scheme + "\"")
+	}
+	request.Header.Set("User-Agent", b.userAgent)
+	// the size is the one transferred, as when the browser loads the resource
+	request.Header.Set("Accept-Encoding", "gzip, deflate, br")
+	started := time.Now()
+	response, err := b.client.Do(request)
+	if err != nil {
+		return 0, 0, err
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK || response.ContentLength <= 0 {
+		return 0, 0, fmt.Errorf("no content length in response with status %d", response.StatusCode)
+	}
+	return response.ContentLength, time.Since(started), nil
+}
+
+// wait - waits for the measurements still running, at most until the timeout
+func (b *resourceBlocker) wait(timeout time.Duration) {
+	waitFor(&b.measurements, timeout)
+}
+
+// patterns - the requests paused by the browser for the rules
+func (b *resourceBlocker) patterns() []*fetch.RequestPattern {
+	output := []*fetch.RequestPattern{}
+	for _, resourceType := range b.types {
+		output = append(output, &fetch.RequestPattern{URLPattern: "*", ResourceType: resourceType, RequestStage: fetch.RequestStageRequest})
+	}
+	for _, pattern := range b.urls {
+		output = append(output, &fetch.RequestPattern{URLPattern: pattern, RequestStage: fetch.RequestStageRequest})
+	}
+	return output
+}
+
+// interceptRequests - fails the requests of the tab to hosts that are not on the list and those blocked by the
+// blocker, returns the action enabling the interception. Both are handled by a single interception as enabling
+// the fetch domain again replaces the patterns enabled before.
+func interceptRequests(ctx context.Context, allowed HostAllowlist, blocker *resourceBlocker) chromedp.Action {
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		paused, ok := ev.(*fetch.EventRequestPaused)
+		if !ok {
+			return
+		}
+		// the listener must not block, the browser is answered from a new goroutine
+		go func() {
+			executor := cdp.WithExecutor(ctx, chromedp.FromContext(ctx).Target)
+			if allowed != nil && !allowed.AllowsURL(paused.Request.URL) {
+				_ = fetch.FailRequest(paused.RequestID, network.ErrorReasonBlockedByClient).Do(executor)
+				return
+			}
+			if blocker != nil && blocker.blocks(paused.ResourceType, paused.Request.URL) {
+				blocker.block(paused.ResourceType, paused.Request.URL)
+				_ = fetch.FailRequest(paused.RequestID, network.ErrorReasonBlockedByClient).Do(executor)
+				return
+			}
+			_ = fetch.ContinueRequest(paused.RequestID).Do(executor)
+		}()
+	})
+	patterns := []*fetch.RequestPattern{{URLPattern: "*", RequestStage: fetch.RequestStageRequest}}
+	if allowed == nil {
+		patterns = blocker.patterns()
+	}
+	return fetch.Enable().WithPatterns(patterns)
+}
+
+// unblocksScreenshots - if a screenshot of the run asks for the resources it depicts, the options of the steps
+// are merged the way they are when the screenshots are taken
+func (r *run) unblocksScreenshots(steps []Step) bool {
+	for _, step := range steps {
+		if step.Action == SCREENSHOT {
+			options := r.screenshotOptions(step)
+			if mode, err := options.ScreenshotMode(); options.Unblock && err == nil && mode != SCREENSHOT_OFF {
+				return true
+			}
+		}
+		if r.unblocksScreenshots(step.Steps) {
+			return true
+		}
+	}
+	return false
+}
+
+// maxLearnedURLs - the number of urls the costs of loading them are kept for, resources of new urls only add to
+// the costs of their resource type once it is reached
+const maxLearnedURLs = 10000
+
+// resourceCost - the bytes received and time taken by loads of a resource
+type resourceCost struct {
+	bytes int64
+	time  time.Duration
+	loads int64
+}
+
+func (c resourceCost) add(bytes int64, duration time.Duration) resourceCost {
+	return resourceCost{bytes: c.bytes + bytes, time: c.time + duration, loads: c.loads + 1}
+}
+
+func (c resourceCost) average() resourceCost {
+	return resourceCost{bytes: c.bytes / c.loads, time: c.time / time.Duration(c.loads), loads: 1}
+}
+
+// costTable - the costs of the resources loaded by browser runs of the process, blocked requests are estimated
+// to cost what earlier loads of the same url did, or resources of the same type when the url was never loaded
+type costTable struct {
+	lock  sync.Mutex
+	urls  map[string]resourceCost
+	types map[network.ResourceType]resourceCost
+}
+
+var learnedCosts = &costTable{
+	urls:  map[string]resourceCost{},
+	types: map[network.ResourceType]resourceCost{},
+}
+
+// costKey - the url without its query and fragment, which often only bust caches or track the visit
+func costKey(target string) string {
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return target
+	}
+	parsed.RawQuery = ""
+	parsed.Fragment = ""
+	return parsed.String()
+}
+
+func (t *costTable) learn(resourceType network.ResourceType, target string, bytes int64, duration time.Duration) {
+	key := costKey(target)
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if cost, found := t.urls[key]; found || len(t.urls) < maxLearnedURLs {
+		t.urls[key] = cost.add(bytes, duration)
+	}
+	t.types[resourceType] = t.types[resourceType].add(bytes, duration)
+}
+
+func (t *costTable) estimate(resourceType network.ResourceType, target string) (resourceCost, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if cost, found := t.urls[costKey(target)]; found {
+		return cost.average(), true
+	}
+	if cost, found := t.types[resourceType]; found {
+		return cost.average(), true
+	}
+	return resourceCost{}, false
+}
+
+// learnCosts - adds the costs of the resources loaded by the tab to the learned costs, resources served from the
+// cache cost nothing and are left out
+func learnCosts(ctx context.Context) {
+	type started struct {
+		resourceType network.ResourceType
+		url          string
+		at           float64
+	}
+	var lock sync.Mutex
+	pending := map[network.RequestID]started{}
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		lock.Lock()
+		defer lock.Unlock()
+		switch ev := ev.(type) {
+		case *network.EventRequestWillBeSent:
+			if request, found := pending[ev.RequestID]; found && ev.RedirectResponse != nil {
+				// the redirect is loaded as part of the request it answered
+				request.url = ev.Request.URL
+				pending[ev.RequestID] = request
+				return
+			}
+			pending[ev.RequestID] = started{resourceType: ev.Type, url: ev.Request.URL, at: monotonicSeconds(ev.Timestamp)}
+		case *network.EventLoadingFinished:
+			request, found := pending[ev.RequestID]
+			delete(pending, ev.RequestID)
+			if !found || ev.EncodedDataLength <= 0 {
+				return
+			}
+			duration := time.Duration((monotonicSeconds(ev.Timestamp) - request.at) * float64(time.Second))
+			learnedCosts.learn(request.resourceType, request.url, int64(ev.EncodedDataLength), duration)
+		case *network.EventLoadingFailed:
+			delete(pending, ev.RequestID)
+		}
+	})
+}
@@ -0,0 +1,182 @@
+package recipe
+
+import (
+	"github.com/chromedp/cdproto/network"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newCostTable() *costTable {
+	return &costTable{
+		urls:  map[string]resourceCost{},
+		types: map[network.ResourceType]resourceCost{},
+	}
+}
+
+func TestURLPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		url     string
+		matches bool
+	}{
+		{"*://*.google-analytics.com/*", "https://www.google-analytics.com/analytics.js", true},
+		{"*://*.google-analytics.com/*", "https://google-analytics.com/analytics.js", false},
+		// wildcards match across the parts of the url, as they do in the browser
+		{"*://*.google-analytics.com/*", "https://example.com/?next=https://www.google-analytics.com/", true},
+		{"*.png", "https://example.com/logo.png", true},
+		{"*.png", "https://example.com/logo.png?v=1", false},
+		{"https://example.com/?", "https://example.com/a", true},
+		{"https://example.com/?", "https://example.com/ab", false},
+		{"https://example.com/a.b", "https://example.com/axb", false},
+		{"https://example.com/(a)+[b]", "https://example.com/(a)+[b]", true},
+		{`https://example.com/\*`, "https://example.com/*", true},
+		{`https://example.com/\*`, "https://example.com/anything", false},
+		{`https://example.com/\?`, "https://example.com/?", true},
+		{`https://example.com/\?`, "https://example.com/a", false},
+		{`https://example.com/a\\b`, `https://example.com/a\b`, true},
+	}
+	for _, test := range tests {
+		t.Run(test.pattern+" "+test.url, func(t *testing.T) {
+			if matches := urlPattern(test.pattern).MatchString(test.url); matches != test.matches {
+				t.Errorf("expected %v, got %v", test.matches, matches)
+			}
+		})
+	}
+}
+
+func TestCostTable(t *testing.T) {
+	table := newCostTable()
+	if _, measured := table.estimate(network.ResourceTypeImage, "https://example.com/logo.png"); measured {
+		t.Fatal("expected an empty table to estimate nothing")
+	}
+	table.learn(network.ResourceTypeImage, "https://example.com/logo.png?v=1", 1000, time.Millisecond*100)
+	table.learn(network.ResourceTypeImage, "https://example.com/logo.png?v=2#top", 3000, time.Millisecond*300)
+	table.learn(network.ResourceTypeImage, "https://example.com/photo.jpg", 8000, time.Millisecond*800)
+
+	tests := []struct {
+		name         string
+		resourceType network.ResourceType
+		url          string
+		bytes        int64
+		time         time.Duration
+		measured     bool
+	}{
+		{"url without query", network.ResourceTypeImage, "https://example.com/logo.png", 2000, time.Millisecond * 200, true},
+		{"url with another query", network.ResourceTypeImage, "https://example.com/logo.png?v=3", 2000, time.Millisecond * 200, true},
+		{"unknown url of a known type", network.ResourceTypeImage, "https://example.com/other.gif", 4000, time.Millisecond * 400, true},
+		{"known url of another type", network.ResourceTypeFont, "https://example.com/photo.jpg", 8000, time.Millisecond * 800, true},
+		{"unknown url of an unknown type", network.ResourceTypeFont, "https://example.com/font.woff2", 0, 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cost, measured := table.estimate(test.resourceType, test.url)
+			if measured != test.measured || cost.bytes != test.bytes || cost.time != test.time {
+				t.Errorf("expected %d bytes in %v (%v), got %d bytes in %v (%v)", test.bytes, test.time, test.measured, cost.bytes, cost.time, measured)
+			}
+		})
+	}
+}
+
+func TestCostTableKeepsMaxURLs(t *testing.T) {
+	table := newCostTable()
+	for i := 0; i < maxLearnedURLs+10; i++ {
+		table.learn(network.ResourceTypeScript, "https://example.com/"+strconv.Itoa(i)+".js", 100, time.Millisecond)
+	}
+	if len(table.urls) != maxLearnedURLs {
+		t.Errorf("expected %d learned urls, got %d", maxLearnedURLs, len(table.urls))
+	}
+	if table.types[network.ResourceTypeScript].loads != int64(maxLearnedURLs+10) {
+		t.Errorf("expected every load to add to the type, got %d", table.types[network.ResourceTypeScript].loads)
+	}
+	// urls learned before the limit keep being updated
+	table.learn(network.ResourceTypeScript, "https://example.com/0.js", 300, time.Millisecond*3)
+	cost, _ := table.estimate(network.ResourceTypeScript, "https://example.com/0.js")
+	if cost.bytes != 200 {
+		t.Errorf("expected the average of both loads, got %d", cost.bytes)
+	}
+}
+
+func TestResourceBlockerMeasuresBlockedTypes(t *testing.T) {
+	var heads int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("expected a HEAD request, got %s", r.Method)
+		}
+		atomic.AddInt64(&heads, 1)
+		if r.URL.Path == "/missing.png" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", "5000")
+	}))
+	defer server.Close()
+
+	counter := NewBlockCounter()
+	blocker := newResourceBlocker(BlockRules{
+		ResourceTypes: []string{"image"},
+		URLs:          []string{"*/tracker.js"},
+	}, counter, server.Client(), DefaultUserAgent)
+	blocker.costs = newCostTable()
+	blocker.block(network.ResourceTypeImage, server.URL+"/missing.png")
+	blocker.block(network.ResourceTypeScript, server.URL+"/tracker.js")
+	blocker.wait(time.Second * 5)
+	blocker.block(network.ResourceTypeImage, server.URL+"/logo.png")
+	blocker.wait(time.Second * 5)
+	// the type is known once the first image was measured
+	blocker.block(network.ResourceTypeImage, server.URL+"/photo.png")
+
+	if heads := atomic.LoadInt64(&heads); heads != 2 {
+		t.Errorf("expected 2 HEAD requests, got %d", heads)
+	}
+	stats := counter.Stats()
+	if stats.Requests != 4 || stats.ResourceTypes["Image"] != 3 || stats.ResourceTypes["Script"] != 1 {
+		t.Errorf("unexpected counts %v", stats)
+	}
+	if stats.BytesSaved != 10000 {
+		t.Errorf("expected 10000 bytes saved, got %d", stats.BytesSaved)
+	}
+	if stats.Unmeasured != 2 {
+		t.Errorf("expected the missing image and the tracker unmeasured, got %d", stats.Unmeasured)
+	}
+}
+
+func TestDmrVehicleBlocksByDefault(t *testing.T) {
+	data, err := os.ReadFile("../recipes/dmr-vehicle.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := Parse("dmr-vehicle.yaml", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		screenshot ScreenshotOptions
+		blocks     bool
+	}{
+		{"default screenshot", ScreenshotOptions{}, true},
+		{"screenshot of another mode", ScreenshotOptions{Mode: "viewport"}, true},
+		{"screenshot asking for the resources", ScreenshotOptions{Unblock: true}, false},
+		{"screenshots turned off", ScreenshotOptions{Mode: "off", Unblock: true}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			state := run{
+				engine:           NewEngine(nil, Profile{}).WithScreenshot(test.screenshot),
+				variables:        Variables{},
+				recipeScreenshot: parsed.Screenshot,
+			}
+			if blocks := !state.unblocksScreenshots(parsed.Steps); blocks != test.blocks {
+				t.Errorf("expected blocking %v, got %v", test.blocks, blocks)
+			}
+		})
+	}
+	if newResourceBlocker(parsed.Block, NewBlockCounter(), nil, DefaultUserAgent) == nil {
+		t.Error("expected the recipe to block resources")
+	}
+}
@@ -0,0 +1,278 @@
+package recipe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/inspector"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+	"golang.org/x/net/html"
+	"math"
+	"strings"
+	"sync"
+)
+
+// maxScreenshotAttempts - how often a screenshot over its max bytes is captured again at a smaller scale, the
+// last attempt is kept even if it is still too large
+const maxScreenshotAttempts = 4
+
+// elementRectScript - the position of the element matching the JSON encoded selector relative to the page
+const elementRectScript = `(function(selector) {
+    const rect = document.querySelector(selector).getBoundingClientRect();
+    return {x: rect.left + window.scrollX, y: rect.top + window.scrollY, width: rect.width, height: rect.height};
+})(%s)`
+
+// browserAllocatorOptions - the options the browser is started with for the profile
+func browserAllocatorOptions(profile Profile) []chromedp.ExecAllocatorOption {
+	options := append([]chromedp.ExecAllocatorOption{}, chromedp.DefaultExecAllocatorOptions[:]...)
+	if len(profile.UserAgent) > 0 {
+		options = append(options, chromedp.UserAgent(profile.UserAgent))
+	}
+	if profile.Width > 0 && profile.Height > 0 {
+		options = append(options, chromedp.WindowSize(profile.Width, profile.Height))
+	}
+	if len(profile.Locale) > 0 {
+		options = append(options, chromedp.Flag("lang", profile.Locale))
+	}
+	if len(profile.Proxy) > 0 {
+		options = append(options, chromedp.ProxyServer(profile.Proxy))
+	}
+	return options
+}
+
+// browserEmulation - the actions overriding the viewport, locale, timezone and headers of the tab, the network
+// domain has to be enabled for the headers
+func browserEmulation(profile Profile) []chromedp.Action {
+	actions := []chromedp.Action{}
+	if profile.Width > 0 && profile.Height > 0 {
+		actions = append(actions, emulation.SetDeviceMetricsOverride(int64(profile.Width), int64(profile.Height), 1, profile.IsMobile()))
+	}
+	if len(profile.UserAgent) > 0 {
+		actions = append(actions, emulation.SetUserAgentOverride(profile.UserAgent).WithAcceptLanguage(profile.AcceptLanguage()))
+	}
+	if len(profile.Locale) > 0 {
+		actions = append(actions, emulation.SetLocaleOverride().WithLocale(strings.ReplaceAll(profile.Locale, "-", "_")))
+	}
+	if len(profile.Timezone) > 0 {
+		actions = append(actions, emulation.SetTimezoneOverride(profile.Timezone))
+	}
+	if len(profile.Headers) > 0 {
+		headers := network.Headers{}
+		for key, value := range profile.Headers {
+			headers[key] = value
+		}
+		actions = append(actions, network.SetExtraHTTPHeaders(headers))
+	}
+	return actions
+}
+
+// browserDriver - runs the steps in a chromedp browser tab
+type browserDriver struct {
+	events   eventLog
+	lock     sync.Mutex
+	requests map[network.RequestID]string
+}
+
+func newBrowserDriver() *browserDriver {
+	return &browserDriver{
+		requests: map[network.RequestID]string{},
+	}
+}
+
+// listen - collects console messages, exceptions and failed requests of the tab for the diagnostics
+func (d *browserDriver) listen(ctx context.Context) {
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		switch ev := ev.(type) {
+		case *runtime.EventConsoleAPICalled:
+			parts := []string{}
+			for _, arg := range ev.Args {
+				if len(arg.Value) > 0 {
+					parts = append(parts, string(arg.Value))
+				} else {
+					parts = append(parts, arg.Description)
+				}
+			}
+			d.events.addConsole(ConsoleMessage{Type: ev.Type.String(), Text: strings.Join(parts, " ")})
+		case *runtime.EventExceptionThrown:
+			text := ev.ExceptionDetails.Text
+			if ev.ExceptionDetails.Exception != nil && len(ev.ExceptionDetails.Exception.Description) > 0 {
+				text = ev.ExceptionDetails.Exception.Description
+			}
+			d.events.addException(text)
+		case *inspector.EventTargetCrashed:
+			d.events.setCrashed()
+		case *network.EventRequestWillBeSent:
+			d.lock.Lock()
+			d.requests[ev.RequestID] = ev.Request.URL
+			d.lock.Unlock()
+		case *network.EventResponseReceived:
+			if ev.Response.Status >= 400 {
+				d.events.addNetworkError(NetworkError{URL: ev.Response.URL, Status: int(ev.Response.Status)})
+			}
+		case *network.EventLoadingFailed:
+			d.lock.Lock()
+			url := d.requests[ev.RequestID]
+			d.lock.Unlock()
+			d.events.addNetworkError(NetworkError{URL: url, Error: ev.ErrorText})
+		case *network.EventLoadingFinished:
+			d.lock.Lock()
+			delete(d.requests, ev.RequestID)
+			d.lock.Unlock()
+		}
+	})
+}
+
+func (d *browserDriver) navigate(ctx context.Context, url string) error {
+	return chromedp.Run(ctx, chromedp.Navigate(url))
+}
+
+func (d *browserDriver) wait(ctx context.Context, selector string) error {
+	return chromedp.Run(ctx, chromedp.WaitReady(selector, chromedp.ByQuery))
+}
+
+func (d *browserDriver) click(ctx context.Context, selector string) error {
+	return chromedp.Run(ctx, chromedp.Click(selector, chromedp.ByQuery))
+}
+
+func (d *browserDriver) setValue(ctx context.Context, selector string, value string) error {
+	return chromedp.Run(ctx, chromedp.SetValue(selector, value, chromedp.ByQuery))
+}
+
+func (d *browserDriver) submit(ctx context.Context, selector string) error {
+	return chromedp.Run(ctx, chromedp.Submit(selector, chromedp.ByQuery))
+}
+
+func (d *browserDriver) screenshot(ctx context.Context, options ScreenshotOptions) ([]byte, error) {
+	mode, err := options.ScreenshotMode()
+	if err != nil {
+		return nil, err
+	}
+	format, err := options.ImageFormat()
+	if err != nil {
+		return nil, err
+	}
+	var area page.Viewport
+	err = chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		area, err = screenshotArea(ctx, mode, options.Selector)
+		return err
+	}))
+	if err != nil {
+		return nil, err
+	}
+	area.Scale = fitScale(area.Width, area.Height, options.MaxWidth, options.MaxHeight)
+	var image []byte
+	for attempt := 1; ; attempt++ {
+		err = chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+			capture := page.CaptureScreenshot().
+				WithFormat(format.format).
+				WithCaptureBeyondViewport(mode != SCREENSHOT_VIEWPORT).
+				WithClip(&area)
+			if format.lossy && options.Quality > 0 {
+				capture = capture.WithQuality(int64(options.Quality))
+			}
+			image, err = capture.Do(ctx)
+			return err
+		}))
+		if err != nil {
+			return nil, err
+		}
+		// the size of an image grows with its area, so the scale shrinks by the root of the excess
+		if options.MaxBytes == 0 || len(image) <= options.MaxBytes || attempt == maxScreenshotAttempts {
+			return image, nil
+		}
+		area.Scale *= math.Max(0.25, 0.9*math.Sqrt(float64(options.MaxBytes)/float64(len(image))))
+	}
+}
+
+// screenshotArea - the part of the page captured by the mode, in CSS pixels of the page
+func screenshotArea(ctx context.Context, mode *ScreenshotMode, selector string) (page.Viewport, error) {
+	if mode == SCREENSHOT_ELEMENT {
+		var rect struct {
+			X      float64 `json:"x"`
+			Y      float64 `json:"y"`
+			Width  float64 `json:"width"`
+			Height float64 `json:"height"`
+		}
+		encoded, err := json.Marshal(selector)
+		if err != nil {
+			return page.Viewport{}, err
+		}
+		err = chromedp.Run(ctx,
+			chromedp.WaitVisible(selector, chromedp.ByQuery),
+			chromedp.Evaluate(fmt.Sprintf(elementRectScript, encoded), &rect),
+		)
+		if err != nil {
+			return page.Viewport{}, err
+		}
+		return page.Viewport{X: rect.X, Y: rect.Y, Width: rect.Width, Height: rect.Height}, nil
+	}
+	_, _, contentSize, _, cssVisualViewport, cssContentSize, err := page.GetLayoutMetrics().Do(ctx)
+	if err != nil {
+		return page.Viewport{}, err
+	}
+	if mode == SCREENSHOT_VIEWPORT && cssVisualViewport != nil {
+		return page.Viewport{
+			X:      cssVisualViewport.PageX,
+			Y:      cssVisualViewport.PageY,
+			Width:  cssVisualViewport.ClientWidth,
+			Height: cssVisualViewport.ClientHeight,
+		}, nil
+	}
+	// protocol v90 renamed the content size to the css content size
+	if cssContentSize != nil {
+		contentSize = cssContentSize
+	}
+	return page.Viewport{Width: contentSize.Width, Height: contentSize.Height}, nil
+}
+
+// fitScale - the scale fitting an area into the max dimensions, areas are never scaled up
+func fitScale(width float64, height float64, maxWidth int, maxHeight int) float64 {
+	scale := 1.0
+	if maxWidth > 0 && width > float64(maxWidth) {
+		scale = math.Min(scale, float64(maxWidth)/width)
+	}
+	if maxHeight > 0 && height > float64(maxHeight) {
+		scale = math.Min(scale, float64(maxHeight)/height)
+	}
+	return scale
+}
+
+func (d *browserDriver) evaluate(ctx context.Context, script string, result interface{}) error {
+	return chromedp.Run(ctx, chromedp.Evaluate(script, result))
+}
+
+func (d *browserDriver) document(ctx context.Context) (*html.Node, error) {
+	var source string
+	err := chromedp.Run(ctx, chromedp.OuterHTML("html", &source, chromedp.ByQuery))
+	if err != nil {
+		return nil, err
+	}
+	return html.Parse(strings.NewReader(source))
+}
+
+func (d *browserDriver) diagnose(ctx context.Context) Diagnostics {
+	output := d.events.diagnostics()
+	var location string
+	var readyState string
+	var image []byte
+	var source string
+	// each part is captured on its own so a page that can't be screenshot still gives its url and html
+	if chromedp.Run(ctx, chromedp.Location(&location)) == nil {
+		output.URL = location
+	}
+	if chromedp.Run(ctx, chromedp.Evaluate("document.readyState", &readyState)) == nil {
+		output.ReadyState = readyState
+	}
+	// a quality below 100 captures a JPEG
+	if chromedp.Run(ctx, chromedp.FullScreenshot(&image, 80)) == nil {
+		output.Screenshot = image
+	}
+	if chromedp.Run(ctx, chromedp.OuterHTML("html", &source, chromedp.ByQuery)) == nil {
+		output.HTML = source
+	}
+	return output
+}
@@ -0,0 +1,119 @@
+package recipe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/andybalholm/cascadia"
+	"github.com/chromedp/chromedp"
+	"golang.org/x/exp/slices"
+	"golang.org/x/net/html"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// errNoElement - a selector of a step matched nothing on the loaded page
+var errNoElement = errors.New("no element matches")
+
+// selectorActions - actions that fail when their selector is missing from the page
+var selectorActions = []string{WAIT, CLICK, SET_VALUE, SUBMIT}
+
+// Outcome - recognizes a failure by the page it left behind, e.g. a "no vehicle found" message. The selector
+// has to match an element and the pattern the text of that element, or of the whole page without a selector.
+type Outcome struct {
+	Kind     string `json:"kind" yaml:"kind"`
+	Selector string `json:"selector,omitempty" yaml:"selector,omitempty"`
+	Pattern  string `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	Status   int    `json:"status,omitempty" yaml:"status,omitempty"`
+}
+
+// Validate - checks the kind, selector and pattern of the outcome
+func (o Outcome) Validate() error {
+	if _, err := ParseErrorKind(o.Kind); err != nil {
+		return err
+	}
+	if len(o.Selector) == 0 && len(o.Pattern) == 0 && o.Status == 0 {
+		return errors.New("selector, pattern or status is required")
+	}
+	if len(o.Selector) > 0 {
+		if _, err := cascadia.Compile(o.Selector); err != nil {
+			return fmt.Errorf("invalid selector \"%s\": %w", o.Selector, err)
+		}
+	}
+	if len(o.Pattern) > 0 {
+		if _, err := regexp.Compile("(?i)" + o.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern \"%s\": %w", o.Pattern, err)
+		}
+	}
+	return nil
+}
+
+func (o Outcome) matches(diagnostics Diagnostics, root *html.Node) bool {
+	if o.Status > 0 && !hasStatus(diagnostics, o.Status) {
+		return false
+	}
+	if len(o.Selector) == 0 && len(o.Pattern) == 0 {
+		return true
+	}
+	if root == nil {
+		return false
+	}
+	node := root
+	if len(o.Selector) > 0 {
+		node = cascadia.Query(root, cascadia.MustCompile(o.Selector))
+		if node == nil {
+			return false
+		}
+	}
+	if len(o.Pattern) == 0 {
+		return true
+	}
+	return regexp.MustCompile("(?i)" + o.Pattern).MatchString(text(node))
+}
+
+// classify - decides the kind of a failure, the outcomes of the recipe are checked before the built-in rules
+func classify(err error, diagnostics Diagnostics, outcomes []Outcome) *ErrorKind {
+	var root *html.Node
+	if len(diagnostics.HTML) > 0 {
+		root, _ = html.Parse(strings.NewReader(diagnostics.HTML))
+	}
+	for _, outcome := range outcomes {
+		if outcome.matches(diagnostics, root) {
+			kind, _ := ParseErrorKind(outcome.Kind)
+			return kind
+		}
+	}
+
+	switch {
+	case diagnostics.Crashed,
+		errors.Is(err, exec.ErrNotFound),
+		errors.Is(err, chromedp.ErrChannelClosed),
+		errors.Is(err, chromedp.ErrInvalidTarget),
+		errors.Is(err, chromedp.ErrInvalidContext):
+		return BROWSER_CRASH
+	case hasStatus(diagnostics, 429) || hasStatus(diagnostics, 403):
+		return BLOCKED
+	case hasStatus(diagnostics, 503):
+		return MAINTENANCE
+	case errors.Is(err, errNoElement):
+		return LAYOUT_CHANGED
+	case errors.Is(err, context.DeadlineExceeded):
+		// a selector that never showed up on a fully loaded page is a changed layout, not a slow site
+		if slices.Contains(selectorActions, diagnostics.Action) && diagnostics.ReadyState == "complete" {
+			return LAYOUT_CHANGED
+		}
+		return TIMEOUT
+	}
+	return UNKNOWN
+}
+
+// hasStatus - if the page itself was answered with the status
+func hasStatus(diagnostics Diagnostics, status int) bool {
+	for _, networkError := range diagnostics.NetworkErrors {
+		if networkError.Status == status && (len(diagnostics.URL) == 0 || networkError.URL == diagnostics.URL) {
+			return true
+		}
+	}
+	return false
+}
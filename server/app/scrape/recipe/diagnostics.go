@@ -0,0 +1,159 @@
+package recipe
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// maxDiagnosticEntries - the most console messages, exceptions and network errors kept per run
+const maxDiagnosticEntries = 200
+
+// diagnosticsTimeout - how long capturing the state of the page may take after a step failed
+const diagnosticsTimeout = time.Second * 10
+
+// ConsoleMessage - a message logged to the browser console
+type ConsoleMessage struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// NetworkError - a request that failed or was answered with an error status
+type NetworkError struct {
+	URL    string `json:"url"`
+	Status int    `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Diagnostics - the state of the page when a step of a recipe failed
+type Diagnostics struct {
+	Step          string           `json:"step"`
+	Action        string           `json:"action"`
+	Error         string           `json:"error"`
+	URL           string           `json:"url"`
+	ReadyState    string           `json:"ready_state,omitempty"`
+	Crashed       bool             `json:"crashed,omitempty"`
+	Console       []ConsoleMessage `json:"console"`
+	Exceptions    []string         `json:"exceptions"`
+	NetworkErrors []NetworkError   `json:"network_errors"`
+	CapturedAt    time.Time        `json:"captured_at"`
+	Screenshot    []byte           `json:"-"`
+	HTML          string           `json:"-"`
+}
+
+// Files - the diagnostics as the files of a bundle
+func (d Diagnostics) Files() map[string][]byte {
+	output := map[string][]byte{}
+	summary, err := json.MarshalIndent(d, "", "  ")
+	if err == nil {
+		output["diagnostics.json"] = summary
+	}
+	if len(d.Screenshot) > 0 {
+		output["screenshot.jpg"] = d.Screenshot
+	}
+	if len(d.HTML) > 0 {
+		output["page.html"] = []byte(d.HTML)
+	}
+	return output
+}
+
+// Failure - a failed run of a recipe with its kind and the diagnostics captured when it failed
+type Failure struct {
+	Err         error
+	Kind        *ErrorKind
+	Diagnostics Diagnostics
+}
+
+func (f *Failure) Error() string {
+	return f.Err.Error()
+}
+
+func (f *Failure) Unwrap() error {
+	return f.Err
+}
+
+// ErrorKind - the name of the kind of the failure, stored with the failed job
+func (f *Failure) ErrorKind() string {
+	return f.Kind.String()
+}
+
+// Retryable - if another attempt may succeed
+func (f *Failure) Retryable() bool {
+	return f.Kind.Retryable()
+}
+
+// Artifacts - the files of the diagnostics bundle, stored with the failed job
+func (f *Failure) Artifacts() map[string][]byte {
+	if f.Diagnostics.CapturedAt.IsZero() {
+		return nil
+	}
+	return f.Diagnostics.Files()
+}
+
+// newFailure - wraps the error of a step with the diagnostics of the page and classifies it
+func newFailure(err error, diagnostics Diagnostics, outcomes []Outcome) *Failure {
+	var stepErr *StepError
+	if errors.As(err, &stepErr) {
+		diagnostics.Step = stepErr.Position
+		diagnostics.Action = stepErr.Action
+	}
+	diagnostics.Error = err.Error()
+	diagnostics.CapturedAt = time.Now()
+	return &Failure{
+		Err:         err,
+		Kind:        classify(err, diagnostics, outcomes),
+		Diagnostics: diagnostics,
+	}
+}
+
+// eventLog - collects what happens on the page while a recipe runs
+type eventLog struct {
+	lock          sync.Mutex
+	crashed       bool
+	console       []ConsoleMessage
+	exceptions    []string
+	networkErrors []NetworkError
+}
+
+func (l *eventLog) addConsole(message ConsoleMessage) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if len(l.console) < maxDiagnosticEntries {
+		l.console = append(l.console, message)
+	}
+}
+
+func (l *eventLog) addException(exception string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if len(l.exceptions) < maxDiagnosticEntries {
+		l.exceptions = append(l.exceptions, exception)
+	}
+}
+
+func (l *eventLog) addNetworkError(networkError NetworkError) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if len(l.networkErrors) < maxDiagnosticEntries {
+		l.networkErrors = append(l.networkErrors, networkError)
+	}
+}
+
+func (l *eventLog) setCrashed() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.crashed = true
+}
+
+// diagnostics - copies the collected events into new diagnostics
+func (l *eventLog) diagnostics() Diagnostics {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return Diagnostics{
+		Crashed:       l.crashed,
+		Console:       append([]ConsoleMessage{}, l.console...),
+		Exceptions:    append([]string{}, l.exceptions...),
+		NetworkErrors: append([]NetworkError{}, l.networkErrors...),
+	}
+}
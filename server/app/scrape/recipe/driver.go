@@ -0,0 +1,19 @@
+package recipe
+
+import (
+	"context"
+	"golang.org/x/net/html"
+)
+
+// driver - carries out the actions of the steps for an engine
+type driver interface {
+	navigate(ctx context.Context, url string) error
+	wait(ctx context.Context, selector string) error
+	click(ctx context.Context, selector string) error
+	setValue(ctx context.Context, selector string, value string) error
+	submit(ctx context.Context, selector string) error
+	screenshot(ctx context.Context, options ScreenshotOptions) ([]byte, error)
+	evaluate(ctx context.Context, script string, result interface{}) error
+	document(ctx context.Context) (*html.Node, error)
+	diagnose(ctx context.Context) Diagnostics
+}
@@ -0,0 +1,461 @@
+package recipe
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"go-scrape-this/server/app/scrape/fixture"
+	"go-scrape-this/server/app/scrape/har"
+	"go-scrape-this/server/app/scrape/warc"
+	"golang.org/x/exp/slices"
+	"io/fs"
+	"time"
+)
+
+// Engine - interprets recipes in a browser or with plain http requests depending on the engine of the
+// recipe, script files are read from the given filesystem
+type Engine struct {
+	files      fs.FS
+	profile    Profile
+	screenshot ScreenshotOptions
+	recorder   *fixture.Recorder
+	replay     *fixture.Archive
+	hosts      HostAllowlist
+	har        *har.Recorder
+	archiver   *warc.Archiver
+	blocking   *BlockCounter
+}
+
+// NewEngine - creates a new recipe engine presenting itself with the profile
+func NewEngine(files fs.FS, profile Profile) *Engine {
+	return &Engine{
+		files:   files,
+		profile: profile,
+	}
+}
+
+// WithScreenshot - overrides the screenshot options of the recipe and its steps
+func (e *Engine) WithScreenshot(options ScreenshotOptions) *Engine {
+	e.screenshot = options
+	return e
+}
+
+// WithRecorder - records the responses the recipe receives into the recorder
+func (e *Engine) WithRecorder(recorder *fixture.Recorder) *Engine {
+	e.recorder = recorder
+	return e
+}
+
+// WithReplay - answers the requests of the recipe from the fixture archive instead of the network
+func (e *Engine) WithReplay(archive *fixture.Archive) *Engine {
+	e.replay = archive
+	return e
+}
+
+// WithAllowedHosts - restricts the requests of the recipe to the hosts on the list, nil allows every host
+func (e *Engine) WithAllowedHosts(hosts HostAllowlist) *Engine {
+	e.hosts = hosts
+	return e
+}
+
+// WithHAR - records the network activity of browser runs into the HAR recorder, runs with plain http requests
+// are not captured
+func (e *Engine) WithHAR(recorder *har.Recorder) *Engine {
+	e.har = recorder
+	return e
+}
+
+// WithArchive - archives the exchanges of the recipe into the WARC archiver
+func (e *Engine) WithArchive(archiver *warc.Archiver) *Engine {
+	e.archiver = archiver
+	return e
+}
+
+// WithBlockCounter - counts the requests blocked by the rules of the recipe into the counter
+func (e *Engine) WithBlockCounter(counter *BlockCounter) *Engine {
+	e.blocking = counter
+	return e
+}
+
+type run struct {
+	engine           *Engine
+	driver           driver
+	variables        Variables
+	output           map[string]interface{}
+	recipeScreenshot ScreenshotOptions
+}
+
+// Run - runs the recipe, the variables given override the defaults of the recipe
+func (e *Engine) Run(ctx context.Context, recipe Recipe, variables map[string]interface{}) (map[string]interface{}, error) {
+	engineType, err := recipe.EngineType()
+	if err != nil {
+		return map[string]interface{}{}, err
+	}
+	state := run{
+		engine:           e,
+		variables:        Variables{},
+		output:           map[string]interface{}{},
+		recipeScreenshot: recipe.Screenshot,
+	}
+	if e.hosts != nil && (e.recorder != nil || e.replay != nil) {
+		return map[string]interface{}{}, errHostsWithFixtures
+	}
+	var replayer *fixture.Replayer
+	if e.replay != nil {
+		replayer = e.replay.Replayer()
+	}
+	if e.recorder != nil {
+		e.recorder.Describe(recipe.Name, engineType.String(), variables)
+	}
+	// the diagnostics are captured in a context outliving the deadline of the steps, so a timed out page
+	// can still be inspected
+	diagnosticsCtx := context.Background()
+	switch engineType {
+	case STATIC:
+		state.driver, err = newStaticDriver(e.profile, e.recorder, replayer, e.hosts, e.archiver)
+		if err != nil {
+			return map[string]interface{}{}, err
+		}
+	default:
+		if e.profile.hasProxyCredentials() {
+			return map[string]interface{}{}, errBrowserProxyCredentials
+		}
+		// the browser lives until shortly after the deadline of the run, which bounds starting it and leaves
+		// time to diagnose a page that timed out
+		lifetimeCtx, cancelLifetime := outliving(ctx, diagnosticsTimeout)
+		defer cancelLifetime()
+		allocatorCtx, cancelAllocator := chromedp.NewExecAllocator(lifetimeCtx, browserAllocatorOptions(e.profile)...)
+		defer cancelAllocator()
+		browserCtx, cancelBrowser := chromedp.NewContext(allocatorCtx)
+		defer cancelBrowser()
+		browser := newBrowserDriver()
+		browser.listen(browserCtx)
+		if e.har != nil {
+			capture := captureHAR(browserCtx, e.har)
+			// runs before the browser is stopped by the earlier defers, so pending bodies can still be fetched
+			defer capture.wait(captureBodyTimeout)
+		}
+		if e.archiver != nil {
+			capture := captureWARC(browserCtx, e.archiver)
+			defer capture.wait(captureBodyTimeout)
+		}
+		setup := []chromedp.Action{network.Enable()}
+		if intercept := interceptFixtures(browserCtx, e.recorder, replayer); intercept != nil {
+			setup = append(setup, intercept)
+		}
+		if replayer == nil {
+			// replayed responses cost nothing to load
+			learnCosts(browserCtx)
+		}
+		var blocker *resourceBlocker
+		if e.recorder == nil && replayer == nil && !state.unblocksScreenshots(recipe.Steps) {
+			// fixtures hold every response of a scrape, so their runs are never blocked
+			blocker = newResourceBlocker(recipe.Block, e.blocking, measureClient(e.profile, e.hosts), e.profile.UserAgent)
+		}
+		if blocker != nil {
+			defer blocker.wait(measureTimeout)
+		}
+		if e.hosts != nil || blocker != nil {
+			setup = append(setup, interceptRequests(browserCtx, e.hosts, blocker))
+		}
+		if e.hosts != nil {
+			// web sockets are never paused by the fetch domain, so they are blocked whatever their host
+			setup = append(setup, network.SetBlockedURLS(blockedSocketURLs))
+		}
+		// the first run starts the browser, which is stopped again when the context of that run ends
+		err = chromedp.Run(browserCtx)
+		if err != nil {
+			return map[string]interface{}{}, &Failure{Err: err, Kind: classify(err, Diagnostics{}, nil)}
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = boundTo(browserCtx, ctx)
+		defer cancel()
+		err = chromedp.Run(ctx, append(setup, browserEmulation(e.profile)...)...)
+		if err != nil {
+			err = fmt.Errorf("failed to apply browser profile: %w", err)
+			return map[string]interface{}{}, &Failure{Err: err, Kind: classify(err, Diagnostics{}, nil)}
+		}
+		state.driver = browser
+		diagnosticsCtx = browserCtx
+	}
+	for key, value := range recipe.Variables {
+		state.variables[key] = value
+	}
+	for key, value := range variables {
+		state.variables[key] = value
+	}
+	err = state.steps(ctx, recipe.Steps, "")
+	if err != nil {
+		captureCtx, cancel := context.WithTimeout(diagnosticsCtx, diagnosticsTimeout)
+		defer cancel()
+		return map[string]interface{}{}, newFailure(err, state.driver.diagnose(captureCtx), recipe.Outcomes)
+	}
+	return state.output, nil
+}
+
+// outliving - a context that isn't cancelled with the parent but ends the grace period after its deadline
+func outliving(parent context.Context, grace time.Duration) (context.Context, context.CancelFunc) {
+	if deadline, ok := parent.Deadline(); ok {
+		return context.WithDeadline(context.Background(), deadline.Add(grace))
+	}
+	return context.WithCancel(context.Background())
+}
+
+// boundTo - a child of the context that ends with the parent, at its deadline or when it is cancelled
+func boundTo(ctx context.Context, parent context.Context) (context.Context, context.CancelFunc) {
+	var cancel context.CancelFunc
+	if deadline, ok := parent.Deadline(); ok {
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	go func() {
+		select {
+		case <-parent.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+func (r *run) steps(ctx context.Context, steps []Step, prefix string) error {
+	for i, step := range steps {
+		if len(step.If) > 0 && !r.variables.Truthy(step.If) {
+			continue
+		}
+		if len(step.Unless) > 0 && r.variables.Truthy(step.Unless) {
+			continue
+		}
+		err := r.step(ctx, step, fmt.Sprintf("%s%d", prefix, i+1))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *run) step(ctx context.Context, step Step, position string) error {
+	if step.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Second*time.Duration(step.Timeout))
+		defer cancel()
+	}
+	var err error
+	switch step.Action {
+	case NAVIGATE:
+		err = r.driver.navigate(ctx, r.variables.Expand(step.URL))
+	case WAIT:
+		err = r.driver.wait(ctx, r.variables.Expand(step.Selector))
+	case CLICK:
+		err = r.driver.click(ctx, r.variables.Expand(step.Selector))
+	case SET_VALUE:
+		err = r.driver.setValue(ctx, r.variables.Expand(step.Selector), r.variables.Expand(step.Value))
+	case SUBMIT:
+		err = r.driver.submit(ctx, r.variables.Expand(step.Selector))
+	case SCREENSHOT:
+		err = r.screenshot(ctx, step)
+	case EVALUATE:
+		err = r.evaluate(ctx, step)
+	case EXTRACT:
+		err = r.extract(ctx, step)
+	case TABS:
+		err = r.tabs(ctx, step, position)
+	default:
+		err = errors.New("unknown action \"" + step.Action + "\"")
+	}
+	if err != nil {
+		var stepErr *StepError
+		if errors.As(err, &stepErr) {
+			return err
+		}
+		return &StepError{Position: position, Action: step.Action, Err: err}
+	}
+	return nil
+}
+
+func (r *run) screenshot(ctx context.Context, step Step) error {
+	options := r.screenshotOptions(step)
+	mode, err := options.ScreenshotMode()
+	if err != nil {
+		return err
+	}
+	if mode == SCREENSHOT_OFF {
+		return nil
+	}
+	format, err := options.ImageFormat()
+	if err != nil {
+		return err
+	}
+	image, err := r.driver.screenshot(ctx, options)
+	if err != nil {
+		return err
+	}
+	into := r.variables.Expand(step.Into)
+	r.storeImage(into, image, format)
+	if options.Thumbnail > 0 {
+		scaled, scaledFormat, err := thumbnail(image, format, options.Thumbnail, options.Quality)
+		if err != nil {
+			return err
+		}
+		r.storeImage(thumbnailKey(into), scaled, scaledFormat)
+	}
+	return nil
+}
+
+// storeImage - stores the image base64 encoded under the key and its format under the same key of the
+// screenshots of the output
+func (r *run) storeImage(key string, image []byte, format *ImageFormat) {
+	r.output[key] = base64.StdEncoding.EncodeToString(image)
+	images, ok := r.output[ScreenshotsKey].(map[string]interface{})
+	if !ok {
+		images = map[string]interface{}{}
+		r.output[ScreenshotsKey] = images
+	}
+	images[key] = newImageInfo(format)
+}
+
+// screenshotOptions - the options of a screenshot step, from the defaults over the recipe and the step to the
+// options the engine was run with
+func (r *run) screenshotOptions(step Step) ScreenshotOptions {
+	options := DefaultScreenshotOptions.
+		Merge(r.recipeScreenshot).
+		Merge(step.screenshotOptions()).
+		Merge(r.engine.screenshot)
+	options.Selector = r.variables.Expand(options.Selector)
+	return options
+}
+
+func (r *run) evaluate(ctx context.Context, step Step) error {
+	script, err := r.script(step)
+	if err != nil {
+		return err
+	}
+	var result interface{}
+	err = r.driver.evaluate(ctx, script, &result)
+	if err != nil {
+		return err
+	}
+	r.store(step, result)
+	return nil
+}
+
+func (r *run) extract(ctx context.Context, step Step) error {
+	root, err := r.driver.document(ctx)
+	if err != nil {
+		return err
+	}
+	result, err := Extract(root, step.Fields)
+	if err != nil {
+		return err
+	}
+	r.store(step, result)
+	return nil
+}
+
+// store - keeps the result of a step in a variable, under a key of the output or merged into the output
+// when neither is given
+func (r *run) store(step Step, result interface{}) {
+	if len(step.Var) > 0 {
+		r.variables[step.Var] = result
+	}
+	if len(step.Into) > 0 {
+		r.output[r.variables.Expand(step.Into)] = result
+		return
+	}
+	if fields, ok := result.(map[string]interface{}); ok && len(step.Var) == 0 {
+		for key, value := range fields {
+			r.output[key] = value
+		}
+	}
+}
+
+// tabs - lists the tabs with the script and runs the nested steps for each of them, the list is
+// refreshed before every tab as clicking a tab changes which one is selected
+func (r *run) tabs(ctx context.Context, step Step, position string) error {
+	script, err := r.script(step)
+	if err != nil {
+		return err
+	}
+	as := step.As
+	if len(as) == 0 {
+		as = "tab"
+	}
+	nameField := step.NameField
+	if len(nameField) == 0 {
+		nameField = "name"
+	}
+
+	var available []map[string]interface{}
+	err = r.driver.evaluate(ctx, script, &available)
+	if err != nil {
+		return err
+	}
+	names := []string{}
+	for _, tab := range available {
+		names = append(names, fmt.Sprint(tab[nameField]))
+	}
+
+	wanted := names
+	if len(step.Only) > 0 {
+		if only := r.variables.Strings(step.Only); len(only) > 0 {
+			wanted = only
+		}
+	}
+	unavailable := []string{}
+	for _, name := range wanted {
+		if !slices.Contains(names, name) {
+			unavailable = append(unavailable, name)
+			continue
+		}
+		var current []map[string]interface{}
+		err = r.driver.evaluate(ctx, script, &current)
+		if err != nil {
+			return err
+		}
+		tab, found := findTab(current, nameField, name)
+		if !found {
+			unavailable = append(unavailable, name)
+			continue
+		}
+		previous, hadPrevious := r.variables[as]
+		r.variables[as] = tab
+		err = r.steps(ctx, step.Steps, position+".")
+		if hadPrevious {
+			r.variables[as] = previous
+		} else {
+			delete(r.variables, as)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(step.UnavailableInto) > 0 && len(unavailable) > 0 {
+		r.output[step.UnavailableInto] = unavailable
+	}
+	return nil
+}
+
+func (r *run) script(step Step) (string, error) {
+	if len(step.Script) > 0 {
+		return step.Script, nil
+	}
+	data, err := fs.ReadFile(r.engine.files, step.ScriptFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read script file \"%s\": %w", step.ScriptFile, err)
+	}
+	return string(data), nil
+}
+
+func findTab(tabs []map[string]interface{}, nameField string, name string) (map[string]interface{}, bool) {
+	for _, tab := range tabs {
+		if fmt.Sprint(tab[nameField]) == name {
+			return tab, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,32 @@
+package recipe
+
+import (
+	"errors"
+	"strings"
+)
+
+// EngineType - how a recipe is run, in a browser or by plain http requests
+type EngineType struct {
+	value string
+}
+
+func (t EngineType) String() string {
+	return t.value
+}
+
+// ParseEngineType - parses the engine of a recipe, recipes without an engine are run in the browser
+func ParseEngineType(value string) (*EngineType, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch value {
+	case "", BROWSER.String():
+		return BROWSER, nil
+	case STATIC.String():
+		return STATIC, nil
+	}
+	return nil, errors.New("unknown engine: \"" + value + "\"")
+}
+
+var (
+	BROWSER = &EngineType{value: "browser"}
+	STATIC  = &EngineType{value: "static"}
+)
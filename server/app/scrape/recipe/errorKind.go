@@ -0,0 +1,52 @@
+package recipe
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrorKind - the class of a failed scrape, deciding if it is retried and how it is answered
+type ErrorKind struct {
+	value     string
+	retryable bool
+}
+
+func (k ErrorKind) String() string {
+	return k.value
+}
+
+// Retryable - if another attempt may succeed
+func (k ErrorKind) Retryable() bool {
+	return k.retryable
+}
+
+func ParseErrorKind(value string) (*ErrorKind, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	for _, kind := range ErrorKinds {
+		if kind.String() == value {
+			return kind, nil
+		}
+	}
+	return nil, errors.New("unknown error kind: \"" + value + "\"")
+}
+
+var (
+	NOT_FOUND      = &ErrorKind{value: "not_found"}
+	AMBIGUOUS      = &ErrorKind{value: "ambiguous"}
+	BLOCKED        = &ErrorKind{value: "blocked", retryable: true}
+	MAINTENANCE    = &ErrorKind{value: "maintenance", retryable: true}
+	LAYOUT_CHANGED = &ErrorKind{value: "layout_changed"}
+	TIMEOUT        = &ErrorKind{value: "timeout", retryable: true}
+	BROWSER_CRASH  = &ErrorKind{value: "browser_crash", retryable: true}
+	UNKNOWN        = &ErrorKind{value: "unknown"}
+	ErrorKinds     = []*ErrorKind{
+		NOT_FOUND,
+		AMBIGUOUS,
+		BLOCKED,
+		MAINTENANCE,
+		LAYOUT_CHANGED,
+		TIMEOUT,
+		BROWSER_CRASH,
+		UNKNOWN,
+	}
+)
@@ -0,0 +1,19 @@
+package recipe
+
+import "fmt"
+
+// StepError - an error raised by a step, the position is the 1 based index of the step with nested steps
+// separated by dots
+type StepError struct {
+	Position string
+	Action   string
+	Err      error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("step %s (%s): %v", e.Position, e.Action, e.Err)
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,171 @@
+package recipe
+
+import (
+	"errors"
+	"fmt"
+	"github.com/andybalholm/cascadia"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xpath"
+	"golang.org/x/net/html"
+	"sort"
+	"strings"
+)
+
+// Rule - extracts a field from the document by a CSS selector or an XPath expression, the text of the
+// matched element is used unless an attribute is given, nested fields extract an object per match
+type Rule struct {
+	Selector  string          `json:"selector,omitempty" yaml:"selector,omitempty"`
+	XPath     string          `json:"xpath,omitempty" yaml:"xpath,omitempty"`
+	Attribute string          `json:"attribute,omitempty" yaml:"attribute,omitempty"`
+	Multiple  bool            `json:"multiple,omitempty" yaml:"multiple,omitempty"`
+	Fields    map[string]Rule `json:"fields,omitempty" yaml:"fields,omitempty"`
+}
+
+// Validate - checks that the rule has exactly one valid selector or expression
+func (r Rule) Validate() error {
+	if (len(r.Selector) > 0) == (len(r.XPath) > 0) {
+		return errors.New("either selector or xpath is required")
+	}
+	if len(r.Selector) > 0 {
+		if _, err := cascadia.Compile(r.Selector); err != nil {
+			return fmt.Errorf("invalid selector \"%s\": %w", r.Selector, err)
+		}
+	} else if _, err := xpath.Compile(r.XPath); err != nil {
+		return fmt.Errorf("invalid xpath \"%s\": %w", r.XPath, err)
+	}
+	return validateRules(r.Fields)
+}
+
+func validateRules(rules map[string]Rule) error {
+	for _, name := range sortedRuleNames(rules) {
+		if err := rules[name].Validate(); err != nil {
+			return fmt.Errorf("field \"%s\": %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Extract - extracts the fields of the rules from the node, fields without a match are nil
+func Extract(node *html.Node, rules map[string]Rule) (map[string]interface{}, error) {
+	output := map[string]interface{}{}
+	for _, name := range sortedRuleNames(rules) {
+		rule := rules[name]
+		matches, err := rule.find(node)
+		if err != nil {
+			return nil, fmt.Errorf("field \"%s\": %w", name, err)
+		}
+		if !rule.Multiple {
+			if len(matches) == 0 {
+				output[name] = nil
+				continue
+			}
+			output[name], err = rule.value(matches[0])
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		values := []interface{}{}
+		for _, match := range matches {
+			value, err := rule.value(match)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		output[name] = values
+	}
+	return output, nil
+}
+
+func (r Rule) find(node *html.Node) ([]*html.Node, error) {
+	if len(r.Selector) > 0 {
+		selector, err := cascadia.Compile(r.Selector)
+		if err != nil {
+			return nil, err
+		}
+		return cascadia.QueryAll(node, selector), nil
+	}
+	return htmlquery.QueryAll(node, r.XPath)
+}
+
+func (r Rule) value(node *html.Node) (interface{}, error) {
+	if len(r.Fields) > 0 {
+		return Extract(node, r.Fields)
+	}
+	if len(r.Attribute) > 0 {
+		value, found := attribute(node, r.Attribute)
+		if !found {
+			return nil, nil
+		}
+		return value, nil
+	}
+	return text(node), nil
+}
+
+func sortedRuleNames(rules map[string]Rule) []string {
+	output := []string{}
+	for name := range rules {
+		output = append(output, name)
+	}
+	sort.Strings(output)
+	return output
+}
+
+var blockElements = map[string]bool{
+	"br": true, "p": true, "div": true, "li": true, "tr": true, "td": true, "th": true,
+	"dt": true, "dd": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// text - the text content of the node with whitespace collapsed like a browser renders it
+func text(node *html.Node) string {
+	var builder strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			builder.WriteString(n.Data)
+		case html.ElementNode:
+			if n.Data == "script" || n.Data == "style" {
+				return
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+		if n.Type == html.ElementNode && blockElements[n.Data] {
+			builder.WriteString(" ")
+		}
+	}
+	walk(node)
+	return strings.Join(strings.Fields(builder.String()), " ")
+}
+
+func attribute(node *html.Node, name string) (string, bool) {
+	for _, attr := range node.Attr {
+		if attr.Key == name {
+			return attr.Val, true
+		}
+	}
+	return "", false
+}
+
+func setAttribute(node *html.Node, name string, value string) {
+	for i, attr := range node.Attr {
+		if attr.Key == name {
+			node.Attr[i].Val = value
+			return
+		}
+	}
+	node.Attr = append(node.Attr, html.Attribute{Key: name, Val: value})
+}
+
+func removeAttribute(node *html.Node, name string) {
+	attrs := []html.Attribute{}
+	for _, attr := range node.Attr {
+		if attr.Key != name {
+			attrs = append(attrs, attr)
+		}
+	}
+	node.Attr = attrs
+}
@@ -0,0 +1,208 @@
+package recipe
+
+import (
+	"context"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"go-scrape-this/server/app/scrape/har"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// captureBodyTimeout - how long the bodies still being fetched by a capture are waited for when the run ends
+const captureBodyTimeout = time.Second * 5
+
+// harCapture - feeds the network events of a tab into a HAR recorder, the bodies of finished responses are
+// fetched in the background
+type harCapture struct {
+	recorder *har.Recorder
+	bodies   sync.WaitGroup
+}
+
+// captureHAR - records the network activity of the tab, network events are sent once the network domain is
+// enabled by the setup of the run
+func captureHAR(ctx context.Context, recorder *har.Recorder) *harCapture {
+	capture := &harCapture{recorder: recorder}
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		switch ev := ev.(type) {
+		case *network.EventRequestWillBeSent:
+			id := ev.RequestID.String()
+			if ev.RedirectResponse != nil {
+				timings, headersEnd := harTimings(ev.RedirectResponse.Timing, monotonicSeconds(ev.Timestamp))
+				response := harResponse(ev.RedirectResponse)
+				response.RedirectURL = ev.Request.URL
+				capture.recorder.Redirect(id, response, timings, headersEnd)
+			}
+			capture.recorder.Start(id, harRequest(ev.Request), string(ev.Type), wallTime(ev.WallTime), monotonicSeconds(ev.Timestamp))
+		case *network.EventResponseReceived:
+			timings, headersEnd := harTimings(ev.Response.Timing, monotonicSeconds(ev.Timestamp))
+			capture.recorder.Respond(ev.RequestID.String(), harResponse(ev.Response), timings, ev.Response.RemoteIPAddress, headersEnd)
+		case *network.EventLoadingFinished:
+			id := ev.RequestID.String()
+			capture.recorder.Finish(id, int64(ev.EncodedDataLength), monotonicSeconds(ev.Timestamp))
+			if !capture.recorder.WantsBody(int64(ev.EncodedDataLength)) {
+				capture.recorder.OmitBody(id)
+				return
+			}
+			capture.bodies.Add(1)
+			// the listener must not block, the body is fetched from a new goroutine
+			go func() {
+				defer capture.bodies.Done()
+				executor := cdp.WithExecutor(ctx, chromedp.FromContext(ctx).Target)
+				body, err := network.GetResponseBody(ev.RequestID).Do(executor)
+				if err != nil {
+					capture.recorder.OmitBody(id)
+					return
+				}
+				capture.recorder.Body(id, body)
+			}()
+		case *network.EventLoadingFailed:
+			reason := ev.ErrorText
+			if len(ev.BlockedReason) > 0 {
+				reason += " (" + ev.BlockedReason.String() + ")"
+			}
+			capture.recorder.Fail(ev.RequestID.String(), reason, monotonicSeconds(ev.Timestamp))
+		}
+	})
+	return capture
+}
+
+// wait - waits for the bodies still being fetched, at most until the timeout
+func (c *harCapture) wait(timeout time.Duration) {
+	waitFor(&c.bodies, timeout)
+}
+
+// waitFor - waits for the group, at most until the timeout
+func waitFor(group *sync.WaitGroup, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		group.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+}
+
+func harRequest(request *network.Request) har.Request {
+	output := har.Request{
+		Method:   request.Method,
+		URL:      request.URL + request.URLFragment,
+		Headers:  harHeaders(request.Headers),
+		BodySize: 0,
+	}
+	if request.HasPostData || len(request.PostData) > 0 {
+		output.PostData = &har.PostData{
+			MimeType: headerValue(request.Headers, "content-type"),
+			Text:     request.PostData,
+		}
+		output.BodySize = int64(len(request.PostData))
+	}
+	return output
+}
+
+func harResponse(response *network.Response) har.Response {
+	return har.Response{
+		Status:      int(response.Status),
+		StatusText:  response.StatusText,
+		HTTPVersion: httpVersion(response.Protocol),
+		Headers:     harHeaders(response.Headers),
+		Content:     har.Content{MimeType: response.MimeType},
+		RedirectURL: headerValue(response.Headers, "location"),
+		// the bytes received when the response arrives are those of the headers
+		HeadersSize: int64(response.EncodedDataLength),
+	}
+}
+
+// harTimings - the phases of a request from the timing of its response, which are milliseconds after the
+// request time. The monotonic time the headers were received at is returned as well, responses without timing,
+// like those served from the cache, were received at the time of the event.
+func harTimings(timing *network.ResourceTiming, eventTime float64) (har.Timings, float64) {
+	timings := har.Timings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1}
+	if timing == nil {
+		return timings, eventTime
+	}
+	timings.Blocked = firstPhaseStart(timing.DNSStart, timing.ConnectStart, timing.SendStart)
+	if timing.DNSStart >= 0 {
+		timings.DNS = timing.DNSEnd - timing.DNSStart
+	}
+	if timing.ConnectStart >= 0 {
+		timings.Connect = timing.ConnectEnd - timing.ConnectStart
+	}
+	if timing.SslStart >= 0 {
+		timings.SSL = timing.SslEnd - timing.SslStart
+	}
+	timings.Send = timing.SendEnd - timing.SendStart
+	timings.Wait = timing.ReceiveHeadersEnd - timing.SendEnd
+	return timings, timing.RequestTime + timing.ReceiveHeadersEnd/1000
+}
+
+func firstPhaseStart(starts ...float64) float64 {
+	for _, start := range starts {
+		if start >= 0 {
+			return start
+		}
+	}
+	return -1
+}
+
+// harHeaders - the headers sorted by name, the browser joins repeated headers with new lines
+func harHeaders(headers network.Headers) []har.NameValue {
+	names := []string{}
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	output := []har.NameValue{}
+	for _, name := range names {
+		value, ok := headers[name].(string)
+		if !ok {
+			continue
+		}
+		for _, line := range strings.Split(value, "\n") {
+			output = append(output, har.NameValue{Name: name, Value: line})
+		}
+	}
+	return output
+}
+
+func headerValue(headers network.Headers, name string) string {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			text, _ := value.(string)
+			return text
+		}
+	}
+	return ""
+}
+
+// httpVersion - the protocol as written in HAR files, e.g. "h2" as "HTTP/2"
+func httpVersion(protocol string) string {
+	switch strings.ToLower(protocol) {
+	case "":
+		return ""
+	case "h2":
+		return "HTTP/2"
+	case "h3", "h3-29":
+		return "HTTP/3"
+	}
+	return strings.ToUpper(protocol)
+}
+
+func monotonicSeconds(timestamp *cdp.MonotonicTime) float64 {
+	if timestamp == nil {
+		return 0
+	}
+	return timestamp.Time().Sub(*cdp.MonotonicTimeEpoch).Seconds()
+}
+
+func wallTime(timestamp *cdp.TimeSinceEpoch) time.Time {
+	if timestamp == nil {
+		return time.Now()
+	}
+	return timestamp.Time()
+}
@@ -0,0 +1,80 @@
+package recipe
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var errHostsWithFixtures = errors.New("allowed hosts can't be combined with recording or replaying fixtures")
+
+// blockedSocketURLs - the patterns of the web socket urls browser runs restricted to allowed hosts refuse
+var blockedSocketURLs = []string{"ws://*", "wss://*"}
+
+// HostAllowlist - the hosts a run may send requests to, an entry starting with "*." also allows every
+// subdomain of the host
+type HostAllowlist []string
+
+// ParseHostAllowlist - parses a comma separated list of hosts
+func ParseHostAllowlist(value string) HostAllowlist {
+	output := HostAllowlist{}
+	for _, host := range strings.Split(value, ",") {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if len(host) > 0 {
+			output = append(output, host)
+		}
+	}
+	return output
+}
+
+// Allows - checks if the host, without its port, is on the list
+func (l HostAllowlist) Allows(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, entry := range l {
+		if strings.HasPrefix(entry, "*.") {
+			wildcard := entry[2:]
+			if host == wildcard || strings.HasSuffix(host, "."+wildcard) {
+				return true
+			}
+			continue
+		}
+		if host == entry {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsURL - checks if the url is a http(s) url of a host on the list
+func (l HostAllowlist) AllowsURL(target string) bool {
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return false
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		// data and blob urls are made by the page itself and never leave the browser
+		return parsed.Scheme == "data" || parsed.Scheme == "blob"
+	}
+	return l.Allows(parsed.Hostname())
+}
+
+// Transport - wraps the round tripper so requests, including redirects, to hosts that are not on the list are
+// refused
+func (l HostAllowlist) Transport(base http.RoundTripper) http.RoundTripper {
+	return hostRestrictedTransport{base: base, allowed: l}
+}
+
+// hostRestrictedTransport - refuses requests, including redirects, to hosts that are not on the list
+type hostRestrictedTransport struct {
+	base    http.RoundTripper
+	allowed HostAllowlist
+}
+
+func (t hostRestrictedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.allowed.AllowsURL(req.URL.String()) {
+		return nil, fmt.Errorf("host not allowed: \"%s\"", req.URL.Hostname())
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,126 @@
+package recipe
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultUserAgent - the user agent of the default profile
+const DefaultUserAgent = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/104.0.0.0 Safari/537.36"
+
+// Profile - how the engines present themselves to the scraped sites, empty fields keep the defaults.
+// Credentials in the proxy url are only sent by the static engine, chrome ignores them.
+type Profile struct {
+	UserAgent string            `json:"user_agent,omitempty" yaml:"user_agent,omitempty"`
+	Width     int               `json:"width,omitempty" yaml:"width,omitempty"`
+	Height    int               `json:"height,omitempty" yaml:"height,omitempty"`
+	Mobile    *bool             `json:"mobile,omitempty" yaml:"mobile,omitempty"`
+	Locale    string            `json:"locale,omitempty" yaml:"locale,omitempty"`
+	Timezone  string            `json:"timezone,omitempty" yaml:"timezone,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Proxy     string            `json:"proxy,omitempty" yaml:"proxy,omitempty"`
+}
+
+// DefaultProfile - the profile every other profile is applied on top of
+var DefaultProfile = Profile{
+	UserAgent: DefaultUserAgent,
+	Width:     1920,
+	Height:    1080,
+}
+
+// Merge - applies the set fields of the override on top of the profile, headers are merged
+func (p Profile) Merge(override Profile) Profile {
+	output := p
+	if len(override.UserAgent) > 0 {
+		output.UserAgent = override.UserAgent
+	}
+	if override.Width > 0 {
+		output.Width = override.Width
+	}
+	if override.Height > 0 {
+		output.Height = override.Height
+	}
+	if override.Mobile != nil {
+		output.Mobile = override.Mobile
+	}
+	if len(override.Locale) > 0 {
+		output.Locale = override.Locale
+	}
+	if len(override.Timezone) > 0 {
+		output.Timezone = override.Timezone
+	}
+	if len(override.Proxy) > 0 {
+		output.Proxy = override.Proxy
+	}
+	output.Headers = map[string]string{}
+	for key, value := range p.Headers {
+		output.Headers[key] = value
+	}
+	for key, value := range override.Headers {
+		output.Headers[key] = value
+	}
+	return output
+}
+
+var errBrowserProxyCredentials = errors.New("the browser engine does not support proxy credentials, use a proxy without a username and password")
+
+// Validate - checks the viewport, timezone and proxy of the profile
+func (p Profile) Validate() error {
+	if p.Width < 0 || p.Height < 0 {
+		return errors.New("viewport must not be negative")
+	}
+	if len(p.Timezone) > 0 {
+		if _, err := time.LoadLocation(p.Timezone); err != nil {
+			return errors.New("unknown timezone: \"" + p.Timezone + "\"")
+		}
+	}
+	if len(p.Proxy) > 0 {
+		if _, err := p.proxyURL(); err != nil {
+			return err
+		}
+	}
+	for key := range p.Headers {
+		if len(strings.TrimSpace(key)) == 0 {
+			return errors.New("header names must not be empty")
+		}
+	}
+	return nil
+}
+
+// AcceptLanguage - the Accept-Language header matching the locale, e.g. "da-DK,da;q=0.9"
+func (p Profile) AcceptLanguage() string {
+	if len(p.Locale) == 0 {
+		return ""
+	}
+	language, _, found := strings.Cut(p.Locale, "-")
+	if !found {
+		return p.Locale
+	}
+	return p.Locale + "," + language + ";q=0.9"
+}
+
+// IsMobile - if the tab is emulated as a mobile device
+func (p Profile) IsMobile() bool {
+	return p.Mobile != nil && *p.Mobile
+}
+
+// hasProxyCredentials - if the proxy url has a username or password
+func (p Profile) hasProxyCredentials() bool {
+	proxy, err := p.proxyURL()
+	return err == nil && proxy.User != nil
+}
+
+func (p Profile) proxyURL() (*url.URL, error) {
+	parsed, err := url.Parse(p.Proxy)
+	if err != nil || len(parsed.Host) == 0 {
+		return nil, fmt.Errorf("invalid proxy: \"%s\"", p.Proxy)
+	}
+	switch parsed.Scheme {
+	case "http", "https", "socks5":
+		return parsed, nil
+	}
+	return nil, fmt.Errorf("unsupported proxy scheme: \"%s\"", parsed.Scheme)
+}
@@ -0,0 +1,188 @@
+package recipe
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/exp/slices"
+	"gopkg.in/yaml.v3"
+	"path"
+	"strings"
+)
+
+const (
+	NAVIGATE   = "navigate"
+	WAIT       = "wait"
+	CLICK      = "click"
+	SET_VALUE  = "set_value"
+	SUBMIT     = "submit"
+	SCREENSHOT = "screenshot"
+	EVALUATE   = "evaluate"
+	EXTRACT    = "extract"
+	TABS       = "tabs"
+)
+
+// browserActions - actions that need a browser and can't be run by the static engine
+var browserActions = []string{SCREENSHOT, EVALUATE, TABS}
+
+// Step - a single action of a recipe, string fields may reference variables as {{name}} or {{name.field}}
+type Step struct {
+	Action          string             `json:"action" yaml:"action"`
+	URL             string             `json:"url,omitempty" yaml:"url,omitempty"`
+	Selector        string             `json:"selector,omitempty" yaml:"selector,omitempty"`
+	Value           string             `json:"value,omitempty" yaml:"value,omitempty"`
+	Script          string             `json:"script,omitempty" yaml:"script,omitempty"`
+	ScriptFile      string             `json:"script_file,omitempty" yaml:"script_file,omitempty"`
+	Into            string             `json:"into,omitempty" yaml:"into,omitempty"`
+	Var             string             `json:"var,omitempty" yaml:"var,omitempty"`
+	Quality         int                `json:"quality,omitempty" yaml:"quality,omitempty"`
+	Timeout         int                `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	If              string             `json:"if,omitempty" yaml:"if,omitempty"`
+	Unless          string             `json:"unless,omitempty" yaml:"unless,omitempty"`
+	As              string             `json:"as,omitempty" yaml:"as,omitempty"`
+	NameField       string             `json:"name_field,omitempty" yaml:"name_field,omitempty"`
+	Only            string             `json:"only,omitempty" yaml:"only,omitempty"`
+	UnavailableInto string             `json:"unavailable_into,omitempty" yaml:"unavailable_into,omitempty"`
+	Fields          map[string]Rule    `json:"fields,omitempty" yaml:"fields,omitempty"`
+	Screenshot      *ScreenshotOptions `json:"screenshot,omitempty" yaml:"screenshot,omitempty"`
+	Steps           []Step             `json:"steps,omitempty" yaml:"steps,omitempty"`
+}
+
+// Recipe - a scrape flow described as data, interpreted by the Engine
+type Recipe struct {
+	Name        string                 `json:"name" yaml:"name"`
+	Description string                 `json:"description,omitempty" yaml:"description,omitempty"`
+	Engine      string                 `json:"engine,omitempty" yaml:"engine,omitempty"`
+	Profile     string                 `json:"profile,omitempty" yaml:"profile,omitempty"`
+	Variables   map[string]interface{} `json:"variables,omitempty" yaml:"variables,omitempty"`
+	Steps       []Step                 `json:"steps" yaml:"steps"`
+	Outcomes    []Outcome              `json:"outcomes,omitempty" yaml:"outcomes,omitempty"`
+	Schema      Schema                 `json:"schema,omitempty" yaml:"schema,omitempty"`
+	Screenshot  ScreenshotOptions      `json:"screenshot,omitempty" yaml:"screenshot,omitempty"`
+	Block       BlockRules             `json:"block,omitempty" yaml:"block,omitempty"`
+}
+
+// Parse - parses a JSON or YAML recipe, the format is chosen by the extension of the filename
+func Parse(filename string, data []byte) (Recipe, error) {
+	var output Recipe
+	err := unmarshal(filename, data, &output)
+	if err != nil {
+		return Recipe{}, fmt.Errorf("failed to parse recipe \"%s\": %w", filename, err)
+	}
+	if len(output.Name) == 0 {
+		output.Name = strings.TrimSuffix(path.Base(filename), path.Ext(filename))
+	}
+	err = output.Validate()
+	if err != nil {
+		return Recipe{}, fmt.Errorf("invalid recipe \"%s\": %w", filename, err)
+	}
+	return output, nil
+}
+
+// ParseProfiles - parses a JSON or YAML file mapping profile names to profiles
+func ParseProfiles(filename string, data []byte) (map[string]Profile, error) {
+	output := map[string]Profile{}
+	err := unmarshal(filename, data, &output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse profiles \"%s\": %w", filename, err)
+	}
+	for name, profile := range output {
+		if err := profile.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid profile \"%s\": %w", name, err)
+		}
+	}
+	return output, nil
+}
+
+func unmarshal(filename string, data []byte, v interface{}) error {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".json":
+		return json.Unmarshal(data, v)
+	case ".yaml", ".yml":
+		return yaml.Unmarshal(data, v)
+	}
+	return errors.New("unsupported format: \"" + path.Ext(filename) + "\"")
+}
+
+// Validate - checks that every step has a known action and the fields the action requires
+func (r Recipe) Validate() error {
+	if len(r.Steps) == 0 {
+		return errors.New("recipe has no steps")
+	}
+	engine, err := r.EngineType()
+	if err != nil {
+		return err
+	}
+	for i, outcome := range r.Outcomes {
+		if err := outcome.Validate(); err != nil {
+			return fmt.Errorf("outcome %d: %w", i+1, err)
+		}
+	}
+	if err := r.Schema.Validate(); err != nil {
+		return fmt.Errorf("schema: %w", err)
+	}
+	if err := r.Screenshot.Validate(); err != nil {
+		return fmt.Errorf("screenshot: %w", err)
+	}
+	if err := r.Block.Validate(); err != nil {
+		return fmt.Errorf("block: %w", err)
+	}
+	if engine == STATIC && !r.Block.IsEmpty() {
+		return errors.New("block: blocking requests requires the browser engine")
+	}
+	return validateSteps(r.Steps, engine, "")
+}
+
+// EngineType - the engine the recipe is run with
+func (r Recipe) EngineType() (*EngineType, error) {
+	return ParseEngineType(r.Engine)
+}
+
+func validateSteps(steps []Step, engine *EngineType, prefix string) error {
+	for i, step := range steps {
+		position := fmt.Sprintf("%s%d", prefix, i+1)
+		if engine == STATIC && slices.Contains(browserActions, step.Action) {
+			return fmt.Errorf("step %s: action \"%s\" requires the browser engine", position, step.Action)
+		}
+		var err error
+		switch step.Action {
+		case NAVIGATE:
+			err = require(step.URL, "url")
+		case WAIT, CLICK, SUBMIT:
+			err = require(step.Selector, "selector")
+		case SET_VALUE:
+			err = require(step.Selector, "selector")
+		case SCREENSHOT:
+			err = require(step.Into, "into")
+			if err == nil {
+				err = step.screenshotOptions().Validate()
+			}
+		case EVALUATE:
+			err = require(step.Script+step.ScriptFile, "script or script_file")
+		case EXTRACT:
+			if len(step.Fields) == 0 {
+				err = errors.New("missing fields")
+			} else {
+				err = validateRules(step.Fields)
+			}
+		case TABS:
+			err = require(step.Script+step.ScriptFile, "script or script_file")
+			if err == nil {
+				err = validateSteps(step.Steps, engine, position+".")
+			}
+		default:
+			err = errors.New("unknown action \"" + step.Action + "\"")
+		}
+		if err != nil {
+			return fmt.Errorf("step %s: %w", position, err)
+		}
+	}
+	return nil
+}
+
+func require(value string, name string) error {
+	if len(strings.TrimSpace(value)) == 0 {
+		return errors.New("missing " + name)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package recipe
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Registry - the recipes and script files of one or more layers, later layers override files of earlier ones
+type Registry struct {
+	files   layeredFS
+	recipes map[string]Recipe
+}
+
+// NewRegistry - loads every recipe (*.yaml, *.yml, *.json) found in the root of the layers
+func NewRegistry(layers ...fs.FS) (*Registry, error) {
+	files := layeredFS(layers)
+	recipes := map[string]Recipe{}
+	names, err := files.names()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		switch strings.ToLower(path.Ext(name)) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+		data, err := fs.ReadFile(files, name)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := Parse(name, data)
+		if err != nil {
+			return nil, err
+		}
+		recipes[parsed.Name] = parsed
+	}
+	return &Registry{
+		files:   files,
+		recipes: recipes,
+	}, nil
+}
+
+// Get - finds a recipe by name
+func (r *Registry) Get(name string) (Recipe, error) {
+	recipe, found := r.recipes[name]
+	if !found {
+		return Recipe{}, errors.New("unknown recipe: \"" + name + "\"")
+	}
+	return recipe, nil
+}
+
+// Names - the sorted names of the loaded recipes
+func (r *Registry) Names() []string {
+	output := []string{}
+	for name := range r.recipes {
+		output = append(output, name)
+	}
+	sort.Strings(output)
+	return output
+}
+
+// Files - the filesystem the script files of the recipes are read from
+func (r *Registry) Files() fs.FS {
+	return r.files
+}
+
+// layeredFS - opens a file from the last layer that has it
+type layeredFS []fs.FS
+
+func (l layeredFS) Open(name string) (fs.File, error) {
+	for i := len(l) - 1; i >= 0; i-- {
+		file, err := l[i].Open(name)
+		if err == nil {
+			return file, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+func (l layeredFS) names() ([]string, error) {
+	seen := map[string]bool{}
+	output := []string{}
+	for _, layer := range l {
+		entries, err := fs.ReadDir(layer, ".")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list recipes: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || seen[entry.Name()] {
+				continue
+			}
+			seen[entry.Name()] = true
+			output = append(output, entry.Name())
+		}
+	}
+	sort.Strings(output)
+	return output, nil
+}
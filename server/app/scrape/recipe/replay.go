@@ -0,0 +1,81 @@
+package recipe
+
+import (
+	"context"
+	"encoding/base64"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"go-scrape-this/server/app/scrape/fixture"
+	"sort"
+)
+
+// interceptFixtures - records the responses the tab receives or answers its requests from a fixture archive,
+// returns the action enabling the interception or nil when neither is asked for
+func interceptFixtures(ctx context.Context, recorder *fixture.Recorder, replayer *fixture.Replayer) chromedp.Action {
+	if recorder == nil && replayer == nil {
+		return nil
+	}
+	stage := fetch.RequestStageResponse
+	if replayer != nil {
+		stage = fetch.RequestStageRequest
+	}
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		paused, ok := ev.(*fetch.EventRequestPaused)
+		if !ok {
+			return
+		}
+		// the listener must not block, the browser is answered from a new goroutine
+		go func() {
+			executor := cdp.WithExecutor(ctx, chromedp.FromContext(ctx).Target)
+			if replayer != nil {
+				replayRequest(executor, replayer, paused)
+				return
+			}
+			recordResponse(executor, recorder, paused)
+		}()
+	})
+	return fetch.Enable().WithPatterns([]*fetch.RequestPattern{{URLPattern: "*", RequestStage: stage}})
+}
+
+func replayRequest(ctx context.Context, replayer *fixture.Replayer, paused *fetch.EventRequestPaused) {
+	entry, body, found := replayer.Find(paused.Request.Method, paused.Request.URL, []byte(paused.Request.PostData))
+	if !found {
+		_ = fetch.FailRequest(paused.RequestID, network.ErrorReasonInternetDisconnected).Do(ctx)
+		return
+	}
+	headers := []*fetch.HeaderEntry{}
+	for _, name := range sortedHeaderNames(entry.Headers) {
+		headers = append(headers, &fetch.HeaderEntry{Name: name, Value: entry.Headers[name]})
+	}
+	_ = fetch.FulfillRequest(paused.RequestID, int64(entry.Status)).
+		WithResponseHeaders(headers).
+		WithBody(base64.StdEncoding.EncodeToString(body)).
+		Do(ctx)
+}
+
+func recordResponse(ctx context.Context, recorder *fixture.Recorder, paused *fetch.EventRequestPaused) {
+	if len(paused.ResponseErrorReason) == 0 {
+		headers := map[string]string{}
+		for _, header := range paused.ResponseHeaders {
+			headers[header.Name] = header.Value
+		}
+		var body []byte
+		// redirects have no body to fetch
+		if paused.ResponseStatusCode < 300 || paused.ResponseStatusCode >= 400 {
+			body, _ = fetch.GetResponseBody(paused.RequestID).Do(ctx)
+		}
+		recorder.Add(paused.Request.Method, paused.Request.URL, []byte(paused.Request.PostData), int(paused.ResponseStatusCode), headers, body)
+	}
+	_ = fetch.ContinueRequest(paused.RequestID).Do(ctx)
+}
+
+func sortedHeaderNames(headers map[string]string) []string {
+	output := []string{}
+	for name := range headers {
+		output = append(output, name)
+	}
+	sort.Strings(output)
+	return output
+}
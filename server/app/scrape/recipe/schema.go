@@ -0,0 +1,82 @@
+package recipe
+
+import (
+	"errors"
+	"fmt"
+	"go-scrape-this/server/app/utils"
+	"path"
+	"strings"
+)
+
+// Schema - the fields a recipe is expected to produce, as patterns matched against the flattened result
+// (e.g. "vehicle.ptr_dmr_portlet_*"). A pattern is satisfied by any matching field. The first segment of a
+// pattern names a section of the result, patterns of sections missing from a result are not checked,
+// so fields of tabs that weren't requested don't count as missing.
+type Schema struct {
+	Required []string `json:"required,omitempty" yaml:"required,omitempty"`
+	Optional []string `json:"optional,omitempty" yaml:"optional,omitempty"`
+}
+
+// FieldCheck - the outcome of checking a result for one field of the schema
+type FieldCheck struct {
+	Field    string `json:"field"`
+	Required bool   `json:"required"`
+	Missing  bool   `json:"missing"`
+}
+
+// Validate - checks the patterns of the schema
+func (s Schema) Validate() error {
+	for _, pattern := range append(append([]string{}, s.Required...), s.Optional...) {
+		if len(pattern) == 0 {
+			return errors.New("empty field pattern")
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid field pattern \"%s\": %w", pattern, err)
+		}
+		if strings.ContainsAny(strings.SplitN(pattern, ".", 2)[0], "*?[\\") {
+			return fmt.Errorf("field pattern \"%s\" has to start with the name of a section", pattern)
+		}
+	}
+	return nil
+}
+
+// IsEmpty - if the schema expects nothing
+func (s Schema) IsEmpty() bool {
+	return len(s.Required) == 0 && len(s.Optional) == 0
+}
+
+// Check - checks the result against the schema, fields of absent sections are left out
+func (s Schema) Check(result map[string]interface{}) []FieldCheck {
+	flattened := utils.Flatten(result, ".")
+	output := []FieldCheck{}
+	check := func(pattern string, required bool) {
+		section := strings.SplitN(pattern, ".", 2)[0]
+		if _, found := result[section]; !found {
+			return
+		}
+		output = append(output, FieldCheck{
+			Field:    pattern,
+			Required: required,
+			Missing:  !hasField(flattened, pattern),
+		})
+	}
+	for _, pattern := range s.Required {
+		check(pattern, true)
+	}
+	for _, pattern := range s.Optional {
+		check(pattern, false)
+	}
+	return output
+}
+
+func hasField(flattened map[string]string, pattern string) bool {
+	for key, value := range flattened {
+		if len(value) == 0 {
+			continue
+		}
+		if matched, _ := path.Match(pattern, key); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,261 @@
+package recipe
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/andybalholm/cascadia"
+	"github.com/chromedp/cdproto/page"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"strings"
+)
+
+// ScreenshotMode - what a screenshot captures
+type ScreenshotMode struct {
+	value string
+}
+
+func (m ScreenshotMode) String() string {
+	return m.value
+}
+
+func ParseScreenshotMode(value string) (*ScreenshotMode, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	for _, mode := range ScreenshotModes {
+		if mode.String() == value {
+			return mode, nil
+		}
+	}
+	return nil, errors.New("unknown screenshot mode: \"" + value + "\"")
+}
+
+var (
+	SCREENSHOT_OFF       = &ScreenshotMode{value: "off"}
+	SCREENSHOT_FULL_PAGE = &ScreenshotMode{value: "full_page"}
+	SCREENSHOT_VIEWPORT  = &ScreenshotMode{value: "viewport"}
+	SCREENSHOT_ELEMENT   = &ScreenshotMode{value: "element"}
+	ScreenshotModes      = []*ScreenshotMode{
+		SCREENSHOT_OFF,
+		SCREENSHOT_FULL_PAGE,
+		SCREENSHOT_VIEWPORT,
+		SCREENSHOT_ELEMENT,
+	}
+)
+
+// ImageFormat - the encoding of a screenshot
+type ImageFormat struct {
+	value  string
+	format page.CaptureScreenshotFormat
+	lossy  bool
+}
+
+func (f ImageFormat) String() string {
+	return f.value
+}
+
+// MimeType - the media type of images of the format
+func (f ImageFormat) MimeType() string {
+	return "image/" + f.value
+}
+
+func ParseImageFormat(value string) (*ImageFormat, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "jpg" {
+		value = JPEG.String()
+	}
+	for _, format := range ImageFormats {
+		if format.String() == value {
+			return format, nil
+		}
+	}
+	return nil, errors.New("unknown image format: \"" + value + "\"")
+}
+
+var (
+	PNG          = &ImageFormat{value: "png", format: page.CaptureScreenshotFormatPng}
+	JPEG         = &ImageFormat{value: "jpeg", format: page.CaptureScreenshotFormatJpeg, lossy: true}
+	WEBP         = &ImageFormat{value: "webp", format: page.CaptureScreenshotFormatWebp, lossy: true}
+	ImageFormats = []*ImageFormat{PNG, JPEG, WEBP}
+)
+
+// ScreenshotOptions - how screenshots are captured, empty fields keep the defaults. Screenshots larger than the
+// max width or height are scaled down, and scaled down further while they are larger than the max bytes.
+// A thumbnail, the screenshot scaled to fit a square of the given size, is stored next to it when set. Unblock
+// loads the resources blocked by the recipe for runs taking the screenshot, so it depicts the page as it looks.
+type ScreenshotOptions struct {
+	Mode      string `json:"mode,omitempty" yaml:"mode,omitempty"`
+	Selector  string `json:"selector,omitempty" yaml:"selector,omitempty"`
+	Format    string `json:"format,omitempty" yaml:"format,omitempty"`
+	Quality   int    `json:"quality,omitempty" yaml:"quality,omitempty"`
+	MaxWidth  int    `json:"max_width,omitempty" yaml:"max_width,omitempty"`
+	MaxHeight int    `json:"max_height,omitempty" yaml:"max_height,omitempty"`
+	MaxBytes  int    `json:"max_bytes,omitempty" yaml:"max_bytes,omitempty"`
+	Thumbnail int    `json:"thumbnail,omitempty" yaml:"thumbnail,omitempty"`
+	Unblock   bool   `json:"unblock,omitempty" yaml:"unblock,omitempty"`
+}
+
+// DefaultScreenshotOptions - full page JPEG screenshots, as scrapes took them before they were configurable
+var DefaultScreenshotOptions = ScreenshotOptions{
+	Format:  JPEG.String(),
+	Quality: 90,
+}
+
+// Merge - applies the set fields of the override on top of the options. A selector without a mode captures
+// the element, so it replaces the mode of the options unless the override sets one as well.
+func (o ScreenshotOptions) Merge(override ScreenshotOptions) ScreenshotOptions {
+	output := o
+	if len(override.Selector) > 0 {
+		output.Selector = override.Selector
+		output.Mode = ""
+	}
+	if len(override.Mode) > 0 {
+		output.Mode = override.Mode
+	}
+	if len(override.Format) > 0 {
+		output.Format = override.Format
+	}
+	if override.Quality > 0 {
+		output.Quality = override.Quality
+	}
+	if override.MaxWidth > 0 {
+		output.MaxWidth = override.MaxWidth
+	}
+	if override.MaxHeight > 0 {
+		output.MaxHeight = override.MaxHeight
+	}
+	if override.MaxBytes > 0 {
+		output.MaxBytes = override.MaxBytes
+	}
+	if override.Thumbnail > 0 {
+		output.Thumbnail = override.Thumbnail
+	}
+	if override.Unblock {
+		output.Unblock = true
+	}
+	return output
+}
+
+// IsEmpty - if the options set nothing
+func (o ScreenshotOptions) IsEmpty() bool {
+	return o == ScreenshotOptions{}
+}
+
+// ScreenshotMode - the mode of the options, without one elements are captured when a selector is set and
+// the full page otherwise
+func (o ScreenshotOptions) ScreenshotMode() (*ScreenshotMode, error) {
+	if len(o.Mode) == 0 {
+		if len(o.Selector) > 0 {
+			return SCREENSHOT_ELEMENT, nil
+		}
+		return SCREENSHOT_FULL_PAGE, nil
+	}
+	return ParseScreenshotMode(o.Mode)
+}
+
+// ImageFormat - the format of the options, PNG when none is set
+func (o ScreenshotOptions) ImageFormat() (*ImageFormat, error) {
+	if len(o.Format) == 0 {
+		return PNG, nil
+	}
+	return ParseImageFormat(o.Format)
+}
+
+// Validate - checks the mode, format, quality and limits of the options, the selector may reference variables
+// so it is only checked without them
+func (o ScreenshotOptions) Validate() error {
+	mode, err := o.ScreenshotMode()
+	if err != nil {
+		return err
+	}
+	if mode == SCREENSHOT_ELEMENT && len(o.Selector) == 0 {
+		return errors.New("element screenshots require a selector")
+	}
+	if len(o.Selector) > 0 && !strings.Contains(o.Selector, "{{") {
+		if _, err := cascadia.Compile(o.Selector); err != nil {
+			return fmt.Errorf("invalid selector \"%s\": %w", o.Selector, err)
+		}
+	}
+	if _, err := o.ImageFormat(); err != nil {
+		return err
+	}
+	if o.Quality < 0 || o.Quality > 100 {
+		return errors.New("quality must be between 0 and 100")
+	}
+	if o.MaxWidth < 0 || o.MaxHeight < 0 || o.MaxBytes < 0 || o.Thumbnail < 0 {
+		return errors.New("screenshot limits must not be negative")
+	}
+	return nil
+}
+
+// screenshotOptions - the screenshot options of a step, its selector and quality are shorthands for the
+// selector and quality of the options
+func (s Step) screenshotOptions() ScreenshotOptions {
+	output := ScreenshotOptions{Selector: s.Selector, Quality: s.Quality}
+	if s.Screenshot != nil {
+		output = output.Merge(*s.Screenshot)
+	}
+	return output
+}
+
+// ScreenshotsKey - the key of the output describing the images of the other keys, as the screenshots are
+// stored as plain base64 strings
+const ScreenshotsKey = "screenshots"
+
+// ImageInfo - how an image of the output is encoded
+type ImageInfo struct {
+	Format   string `json:"format"`
+	MimeType string `json:"mime_type"`
+}
+
+func newImageInfo(format *ImageFormat) ImageInfo {
+	return ImageInfo{Format: format.String(), MimeType: format.MimeType()}
+}
+
+// thumbnail - scales the captured image down to fit a square of the size. Images are never scaled up, and
+// WebP images, which can't be encoded without cgo, get a JPEG thumbnail.
+func thumbnail(data []byte, format *ImageFormat, size int, quality int) ([]byte, *ImageFormat, error) {
+	var source image.Image
+	var err error
+	switch format {
+	case PNG:
+		source, err = png.Decode(bytes.NewReader(data))
+	case JPEG:
+		source, err = jpeg.Decode(bytes.NewReader(data))
+	default:
+		source, err = webp.Decode(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode screenshot: %w", err)
+	}
+	bounds := source.Bounds()
+	scale := fitScale(float64(bounds.Dx()), float64(bounds.Dy()), size, size)
+	width := int(math.Max(1, math.Round(float64(bounds.Dx())*scale)))
+	height := int(math.Max(1, math.Round(float64(bounds.Dy())*scale)))
+	scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(scaled, scaled.Bounds(), source, bounds, draw.Src, nil)
+
+	var output bytes.Buffer
+	if format == PNG {
+		err = png.Encode(&output, scaled)
+		return output.Bytes(), PNG, err
+	}
+	options := &jpeg.Options{Quality: jpeg.DefaultQuality}
+	if quality > 0 {
+		options.Quality = quality
+	}
+	err = jpeg.Encode(&output, scaled, options)
+	return output.Bytes(), JPEG, err
+}
+
+// thumbnailKey - the output key of the thumbnail of a screenshot, keys of images keep their "_image" suffix
+func thumbnailKey(into string) string {
+	if strings.HasSuffix(into, "_image") {
+		return strings.TrimSuffix(into, "_image") + "_thumbnail_image"
+	}
+	return into + "_thumbnail"
+}
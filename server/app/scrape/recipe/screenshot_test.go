@@ -0,0 +1,73 @@
+package recipe
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodeImage(t *testing.T, format *ImageFormat, width int, height int) []byte {
+	source := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			source.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var output bytes.Buffer
+	var err error
+	if format == PNG {
+		err = png.Encode(&output, source)
+	} else {
+		err = jpeg.Encode(&output, source, nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return output.Bytes()
+}
+
+func TestThumbnail(t *testing.T) {
+	tests := []struct {
+		format *ImageFormat
+		width  int
+		height int
+		size   int
+		scaled image.Point
+	}{
+		{PNG, 400, 200, 100, image.Pt(100, 50)},
+		{JPEG, 200, 400, 100, image.Pt(50, 100)},
+		{PNG, 1000, 1, 100, image.Pt(100, 1)},
+		{JPEG, 80, 60, 100, image.Pt(80, 60)},
+	}
+	for _, test := range tests {
+		data, format, err := thumbnail(encodeImage(t, test.format, test.width, test.height), test.format, test.size, 80)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if format != test.format {
+			t.Errorf("%s: expected a %s thumbnail, got %s", test.format, test.format, format)
+		}
+		decoded, decodedFormat, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decodedFormat != format.String() || decoded.Bounds().Size() != test.scaled {
+			t.Errorf("%dx%d: expected a %s of %v, got a %s of %v", test.width, test.height, format, test.scaled, decodedFormat, decoded.Bounds().Size())
+		}
+	}
+	if _, _, err := thumbnail([]byte("not an image"), PNG, 100, 0); err == nil {
+		t.Error("expected an invalid image to fail")
+	}
+}
+
+func TestThumbnailKey(t *testing.T) {
+	if key := thumbnailKey("vehicle_image"); key != "vehicle_thumbnail_image" {
+		t.Errorf("unexpected key %s", key)
+	}
+	if key := thumbnailKey("page"); key != "page_thumbnail" {
+		t.Errorf("unexpected key %s", key)
+	}
+}
@@ -0,0 +1,397 @@
+package recipe
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/andybalholm/cascadia"
+	"go-scrape-this/server/app/scrape/fixture"
+	"go-scrape-this/server/app/scrape/warc"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/net/publicsuffix"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+)
+
+// maxDocumentSize - pages larger than this are cut off before parsing
+const maxDocumentSize = 10 << 20
+
+var errNoBrowser = errors.New("not supported by the static engine")
+
+// staticDriver - runs the steps with plain http requests, forms are filled in and submitted on the parsed
+// document the same way a browser without javascript would
+type staticDriver struct {
+	events   eventLog
+	client   *http.Client
+	profile  Profile
+	location *url.URL
+	root     *html.Node
+}
+
+func newStaticDriver(profile Profile, recorder *fixture.Recorder, replayer *fixture.Replayer, hosts HostAllowlist, archiver *warc.Archiver) (*staticDriver, error) {
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if len(profile.Proxy) > 0 {
+		proxy, err := profile.proxyURL()
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	var roundTripper http.RoundTripper = transport
+	if replayer != nil {
+		roundTripper = fixture.ReplayTransport{Replayer: replayer}
+	}
+	if recorder != nil {
+		roundTripper = fixture.RecordingTransport{Base: roundTripper, Recorder: recorder}
+	}
+	if archiver != nil {
+		roundTripper = warc.Transport{Base: roundTripper, Archiver: archiver}
+	}
+	if hosts != nil {
+		roundTripper = hostRestrictedTransport{base: roundTripper, allowed: hosts}
+	}
+	return &staticDriver{
+		client:  &http.Client{Jar: jar, Transport: roundTripper},
+		profile: profile,
+	}, nil
+}
+
+func (d *staticDriver) navigate(ctx context.Context, target string) error {
+	return d.request(ctx, http.MethodGet, target, nil)
+}
+
+func (d *staticDriver) wait(ctx context.Context, selector string) error {
+	_, err := d.query(selector)
+	return err
+}
+
+func (d *staticDriver) click(ctx context.Context, selector string) error {
+	node, err := d.query(selector)
+	if err != nil {
+		return err
+	}
+	switch node.Data {
+	case "a":
+		href, found := attribute(node, "href")
+		if !found {
+			return errors.New("link has no href")
+		}
+		return d.navigate(ctx, href)
+	case "input":
+		inputType, _ := attribute(node, "type")
+		switch strings.ToLower(inputType) {
+		case "radio":
+			name, _ := attribute(node, "name")
+			for _, other := range formControls(closestForm(node, d.root)) {
+				otherType, _ := attribute(other, "type")
+				otherName, _ := attribute(other, "name")
+				if strings.ToLower(otherType) == "radio" && otherName == name {
+					removeAttribute(other, "checked")
+				}
+			}
+			setAttribute(node, "checked", "checked")
+			return nil
+		case "checkbox":
+			if _, checked := attribute(node, "checked"); checked {
+				removeAttribute(node, "checked")
+			} else {
+				setAttribute(node, "checked", "checked")
+			}
+			return nil
+		case "submit", "image":
+			return d.submitForm(ctx, node, node)
+		}
+	case "button":
+		buttonType, _ := attribute(node, "type")
+		if buttonType == "" || strings.ToLower(buttonType) == "submit" {
+			return d.submitForm(ctx, node, node)
+		}
+	}
+	return fmt.Errorf("clicking <%s> requires a browser", node.Data)
+}
+
+func (d *staticDriver) setValue(ctx context.Context, selector string, value string) error {
+	node, err := d.query(selector)
+	if err != nil {
+		return err
+	}
+	switch node.Data {
+	case "input":
+		setAttribute(node, "value", value)
+	case "textarea":
+		for node.FirstChild != nil {
+			node.RemoveChild(node.FirstChild)
+		}
+		node.AppendChild(&html.Node{Type: html.TextNode, Data: value})
+	case "select":
+		found := false
+		for _, option := range cascadia.QueryAll(node, cascadia.MustCompile("option")) {
+			if optionValue(option) == value {
+				setAttribute(option, "selected", "selected")
+				found = true
+			} else {
+				removeAttribute(option, "selected")
+			}
+		}
+		if !found {
+			return errors.New("select has no option \"" + value + "\"")
+		}
+	default:
+		return fmt.Errorf("cannot set the value of <%s>", node.Data)
+	}
+	return nil
+}
+
+func (d *staticDriver) submit(ctx context.Context, selector string) error {
+	node, err := d.query(selector)
+	if err != nil {
+		return err
+	}
+	return d.submitForm(ctx, node, nil)
+}
+
+func (d *staticDriver) screenshot(ctx context.Context, options ScreenshotOptions) ([]byte, error) {
+	return nil, errNoBrowser
+}
+
+func (d *staticDriver) evaluate(ctx context.Context, script string, result interface{}) error {
+	return errNoBrowser
+}
+
+func (d *staticDriver) document(ctx context.Context) (*html.Node, error) {
+	if d.root == nil {
+		return nil, errors.New("no page has been loaded")
+	}
+	return d.root, nil
+}
+
+func (d *staticDriver) diagnose(ctx context.Context) Diagnostics {
+	output := d.events.diagnostics()
+	if d.location != nil {
+		output.URL = d.location.String()
+	}
+	if d.root != nil {
+		output.ReadyState = "complete"
+		var source strings.Builder
+		if html.Render(&source, d.root) == nil {
+			output.HTML = source.String()
+		}
+	}
+	return output
+}
+
+func (d *staticDriver) query(selector string) (*html.Node, error) {
+	if d.root == nil {
+		return nil, errors.New("no page has been loaded")
+	}
+	compiled, err := cascadia.Compile(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector \"%s\": %w", selector, err)
+	}
+	node := cascadia.Query(d.root, compiled)
+	if node == nil {
+		return nil, fmt.Errorf("%w \"%s\"", errNoElement, selector)
+	}
+	return node, nil
+}
+
+// submitForm - submits the form of the node with the values of its controls, the submitter is the button
+// that was clicked if any
+func (d *staticDriver) submitForm(ctx context.Context, node *html.Node, submitter *html.Node) error {
+	form := closestForm(node, nil)
+	if form == nil {
+		return errors.New("element is not part of a form")
+	}
+	values := formValues(form, submitter)
+	method, _ := attribute(form, "method")
+	action, _ := attribute(form, "action")
+	if submitter != nil {
+		if override, found := attribute(submitter, "formaction"); found {
+			action = override
+		}
+		if override, found := attribute(submitter, "formmethod"); found {
+			method = override
+		}
+	}
+	if strings.ToLower(method) == "post" {
+		return d.request(ctx, http.MethodPost, action, values)
+	}
+	target, err := d.resolve(action)
+	if err != nil {
+		return err
+	}
+	target.RawQuery = values.Encode()
+	return d.request(ctx, http.MethodGet, target.String(), nil)
+}
+
+func (d *staticDriver) resolve(target string) (*url.URL, error) {
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	if d.location != nil {
+		parsed = d.location.ResolveReference(parsed)
+	}
+	if !parsed.IsAbs() {
+		return nil, errors.New("url is not absolute: \"" + target + "\"")
+	}
+	return parsed, nil
+}
+
+// request - loads a page, redirects are followed and the body is decoded from the charset of the response
+func (d *staticDriver) request(ctx context.Context, method string, target string, form url.Values) error {
+	resolved, err := d.resolve(target)
+	if err != nil {
+		return err
+	}
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, resolved.String(), body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.8")
+	for key, value := range d.profile.Headers {
+		req.Header.Set(key, value)
+	}
+	if len(d.profile.UserAgent) > 0 {
+		req.Header.Set("User-Agent", d.profile.UserAgent)
+	}
+	if language := d.profile.AcceptLanguage(); len(language) > 0 {
+		req.Header.Set("Accept-Language", language)
+	}
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if d.location != nil {
+		req.Header.Set("Referer", d.location.String())
+	}
+	res, err := d.client.Do(req)
+	if err != nil {
+		d.events.addNetworkError(NetworkError{URL: resolved.Redacted(), Error: err.Error()})
+		return err
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxDocumentSize))
+	if err != nil {
+		return err
+	}
+	var reader io.Reader = bytes.NewReader(data)
+	if len(data) > 0 {
+		reader, err = charset.NewReader(reader, res.Header.Get("Content-Type"))
+		if err != nil {
+			return err
+		}
+	}
+	root, err := html.Parse(reader)
+	if err != nil {
+		return err
+	}
+	// error pages are kept as the current page so their content can tell why the request failed
+	d.location = res.Request.URL
+	d.root = root
+	if res.StatusCode >= 400 {
+		d.events.addNetworkError(NetworkError{URL: res.Request.URL.Redacted(), Status: res.StatusCode})
+		return fmt.Errorf("%s %s: unexpected status %d", method, resolved.Redacted(), res.StatusCode)
+	}
+	return nil
+}
+
+// closestForm - the form containing the node, falls back to searching the root when the node is not in a form
+func closestForm(node *html.Node, root *html.Node) *html.Node {
+	for current := node; current != nil; current = current.Parent {
+		if current.Type == html.ElementNode && current.Data == "form" {
+			return current
+		}
+	}
+	return root
+}
+
+func formControls(form *html.Node) []*html.Node {
+	if form == nil {
+		return nil
+	}
+	return cascadia.QueryAll(form, cascadia.MustCompile("input, select, textarea, button"))
+}
+
+// formValues - the values a browser would send for the controls of the form
+func formValues(form *html.Node, submitter *html.Node) url.Values {
+	values := url.Values{}
+	for _, control := range formControls(form) {
+		name, _ := attribute(control, "name")
+		if len(name) == 0 {
+			continue
+		}
+		if _, disabled := attribute(control, "disabled"); disabled {
+			continue
+		}
+		switch control.Data {
+		case "input":
+			inputType, _ := attribute(control, "type")
+			value, _ := attribute(control, "value")
+			switch strings.ToLower(inputType) {
+			case "checkbox", "radio":
+				if _, checked := attribute(control, "checked"); !checked {
+					continue
+				}
+				if _, found := attribute(control, "value"); !found {
+					value = "on"
+				}
+			case "submit", "image", "button", "reset", "file":
+				if control != submitter {
+					continue
+				}
+			}
+			values.Add(name, value)
+		case "button":
+			if control == submitter {
+				value, _ := attribute(control, "value")
+				values.Add(name, value)
+			}
+		case "textarea":
+			values.Add(name, textContent(control))
+		case "select":
+			options := cascadia.QueryAll(control, cascadia.MustCompile("option"))
+			_, multiple := attribute(control, "multiple")
+			selected := false
+			for _, option := range options {
+				if _, found := attribute(option, "selected"); found {
+					values.Add(name, optionValue(option))
+					selected = true
+				}
+			}
+			if !selected && !multiple && len(options) > 0 {
+				values.Add(name, optionValue(options[0]))
+			}
+		}
+	}
+	return values
+}
+
+func optionValue(option *html.Node) string {
+	if value, found := attribute(option, "value"); found {
+		return value
+	}
+	return text(option)
+}
+
+func textContent(node *html.Node) string {
+	var builder strings.Builder
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.TextNode {
+			builder.WriteString(child.Data)
+		}
+	}
+	return builder.String()
+}
@@ -0,0 +1,74 @@
+package recipe
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var variablePattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.\-]+)\s*\}\}`)
+
+// Variables - the named values available to the steps of a recipe
+type Variables map[string]interface{}
+
+// Expand - replaces every variable reference in the value
+func (v Variables) Expand(value string) string {
+	return variablePattern.ReplaceAllStringFunc(value, func(match string) string {
+		name := variablePattern.FindStringSubmatch(match)[1]
+		resolved, found := v.Resolve(name)
+		if !found || resolved == nil {
+			return ""
+		}
+		return fmt.Sprint(resolved)
+	})
+}
+
+// Resolve - looks up a variable, nested fields of maps are reached with dots
+func (v Variables) Resolve(name string) (interface{}, bool) {
+	parts := strings.Split(name, ".")
+	var current interface{} = map[string]interface{}(v)
+	for _, part := range parts {
+		fields, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = fields[part]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// Truthy - expands the condition and checks if the result counts as true
+func (v Variables) Truthy(condition string) bool {
+	switch strings.ToLower(strings.TrimSpace(v.Expand(condition))) {
+	case "", "false", "0", "no", "null":
+		return false
+	}
+	return true
+}
+
+// Strings - resolves a variable holding a list and returns the items as strings
+func (v Variables) Strings(name string) []string {
+	value, found := v.Resolve(name)
+	if !found {
+		return nil
+	}
+	output := []string{}
+	switch list := value.(type) {
+	case []string:
+		output = append(output, list...)
+	case []interface{}:
+		for _, item := range list {
+			output = append(output, fmt.Sprint(item))
+		}
+	case string:
+		for _, item := range strings.Split(list, ",") {
+			if len(strings.TrimSpace(item)) > 0 {
+				output = append(output, strings.TrimSpace(item))
+			}
+		}
+	}
+	return output
+}
@@ -0,0 +1,124 @@
+package recipe
+
+import (
+	"context"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"go-scrape-this/server/app/scrape/warc"
+	"sync"
+	"time"
+)
+
+// warcCapture - feeds the exchanges of a tab into a WARC archiver, an exchange is archived once its body was
+// fetched
+type warcCapture struct {
+	archiver *warc.Archiver
+	lock     sync.Mutex
+	pending  map[network.RequestID]*warc.Exchange
+	bodies   sync.WaitGroup
+}
+
+// captureWARC - archives the exchanges of the tab, network events are sent once the network domain is enabled
+// by the setup of the run
+func captureWARC(ctx context.Context, archiver *warc.Archiver) *warcCapture {
+	capture := &warcCapture{
+		archiver: archiver,
+		pending:  map[network.RequestID]*warc.Exchange{},
+	}
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		switch ev := ev.(type) {
+		case *network.EventRequestWillBeSent:
+			if ev.RedirectResponse != nil {
+				// a redirect has no body, the request it answered is complete
+				if exchange := capture.respond(ev.RequestID, ev.RedirectResponse); exchange != nil {
+					capture.archiver.Add(*exchange)
+				}
+			}
+			capture.start(ev)
+		case *network.EventResponseReceived:
+			capture.lock.Lock()
+			if exchange, found := capture.pending[ev.RequestID]; found {
+				setResponse(exchange, ev.Response)
+			}
+			capture.lock.Unlock()
+		case *network.EventLoadingFinished:
+			capture.lock.Lock()
+			exchange, found := capture.pending[ev.RequestID]
+			delete(capture.pending, ev.RequestID)
+			capture.lock.Unlock()
+			if !found {
+				return
+			}
+			capture.bodies.Add(1)
+			// the listener must not block, the body is fetched from a new goroutine
+			go func() {
+				defer capture.bodies.Done()
+				executor := cdp.WithExecutor(ctx, chromedp.FromContext(ctx).Target)
+				body, err := network.GetResponseBody(ev.RequestID).Do(executor)
+				if err == nil {
+					exchange.Body = body
+				}
+				capture.archiver.Add(*exchange)
+			}()
+		case *network.EventLoadingFailed:
+			// a failed request has no response to archive
+			capture.lock.Lock()
+			delete(capture.pending, ev.RequestID)
+			capture.lock.Unlock()
+		}
+	})
+	return capture
+}
+
+func (c *warcCapture) start(ev *network.EventRequestWillBeSent) {
+	exchange := &warc.Exchange{
+		Method:         ev.Request.Method,
+		URL:            ev.Request.URL,
+		RequestHeaders: warcHeaders(ev.Request.Headers),
+		RequestBody:    []byte(ev.Request.PostData),
+		Date:           wallTime(ev.WallTime),
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.pending[ev.RequestID] = exchange
+}
+
+// respond - completes the pending exchange with the response, returns nil when the request is unknown
+func (c *warcCapture) respond(id network.RequestID, response *network.Response) *warc.Exchange {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	exchange, found := c.pending[id]
+	if !found {
+		return nil
+	}
+	delete(c.pending, id)
+	setResponse(exchange, response)
+	return exchange
+}
+
+// wait - waits for the bodies still being fetched, at most until the timeout
+func (c *warcCapture) wait(timeout time.Duration) {
+	waitFor(&c.bodies, timeout)
+}
+
+func setResponse(exchange *warc.Exchange, response *network.Response) {
+	exchange.Status = int(response.Status)
+	exchange.StatusText = response.StatusText
+	exchange.ResponseHeaders = warcHeaders(response.Headers)
+	exchange.RemoteIP = response.RemoteIPAddress
+	// the headers actually sent include those added by the network stack, like cookies
+	if len(response.RequestHeaders) > 0 {
+		exchange.RequestHeaders = warcHeaders(response.RequestHeaders)
+	}
+}
+
+func warcHeaders(headers network.Headers) []warc.Header {
+	values := map[string]string{}
+	for name, value := range headers {
+		if text, ok := value.(string); ok {
+			values[name] = text
+		}
+	}
+	return warc.SortedHeaders(values)
+}
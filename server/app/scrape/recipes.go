@@ -0,0 +1,109 @@
+package scrape
+
+import (
+	"context"
+	"embed"
+	"go-scrape-this/server/app/scrape/fixture"
+	"go-scrape-this/server/app/scrape/har"
+	"go-scrape-this/server/app/scrape/recipe"
+	"go-scrape-this/server/app/scrape/warc"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+//go:embed recipes
+var embeddedRecipes embed.FS
+
+var (
+	recipesMutex sync.RWMutex
+	recipes      = mustLoadEmbeddedRecipes()
+)
+
+func embeddedRecipeFiles() fs.FS {
+	files, err := fs.Sub(embeddedRecipes, "recipes")
+	if err != nil {
+		panic(err)
+	}
+	return files
+}
+
+func mustLoadEmbeddedRecipes() *recipe.Registry {
+	registry, err := recipe.NewRegistry(embeddedRecipeFiles())
+	if err != nil {
+		panic(err)
+	}
+	return registry
+}
+
+// LoadRecipes - loads the recipes and scripts of the directory on top of the embedded ones, files in the
+// directory replace embedded files with the same name
+func LoadRecipes(dir string) error {
+	registry, err := recipe.NewRegistry(embeddedRecipeFiles(), os.DirFS(dir))
+	if err != nil {
+		return err
+	}
+	recipesMutex.Lock()
+	defer recipesMutex.Unlock()
+	recipes = registry
+	return nil
+}
+
+// Recipes - the currently loaded recipes
+func Recipes() *recipe.Registry {
+	recipesMutex.RLock()
+	defer recipesMutex.RUnlock()
+	return recipes
+}
+
+// RunOptions - how a recipe is run
+type RunOptions struct {
+	// Profile - the name of the browser profile, overrides the profile of the recipe
+	Profile string
+	Timeout time.Duration
+	// Screenshot - overrides the screenshot options of the recipe
+	Screenshot recipe.ScreenshotOptions
+	// Recorder - records the responses of the run into a fixture archive
+	Recorder *fixture.Recorder
+	// Replay - answers the requests of the run from a fixture archive instead of the network
+	Replay *fixture.Archive
+	// AllowedHosts - the hosts the run may send requests to, nil allows every host
+	AllowedHosts recipe.HostAllowlist
+	// HAR - records the network activity of browser runs as a HAR file
+	HAR *har.Recorder
+	// Archive - archives the exchanges of the run as a WARC file
+	Archive *warc.Archiver
+	// Blocking - counts the requests blocked by the rules of the recipe
+	Blocking *recipe.BlockCounter
+}
+
+// RunRecipe - runs a loaded recipe with the engine it asks for
+func RunRecipe(name string, variables map[string]interface{}, options RunOptions) (map[string]interface{}, error) {
+	registry := Recipes()
+	found, err := registry.Get(name)
+	if err != nil {
+		return map[string]interface{}{}, err
+	}
+	return runRecipe(registry, found, variables, options)
+}
+
+func runRecipe(registry *recipe.Registry, found recipe.Recipe, variables map[string]interface{}, options RunOptions) (map[string]interface{}, error) {
+	resolved, err := resolveProfile(found, options.Profile)
+	if err != nil {
+		return map[string]interface{}{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), options.Timeout)
+	defer cancel()
+
+	return recipe.NewEngine(registry.Files(), resolved).
+		WithScreenshot(options.Screenshot).
+		WithRecorder(options.Recorder).
+		WithReplay(options.Replay).
+		WithAllowedHosts(options.AllowedHosts).
+		WithHAR(options.HAR).
+		WithArchive(options.Archive).
+		WithBlockCounter(options.Blocking).
+		Run(ctx, found, variables)
+}
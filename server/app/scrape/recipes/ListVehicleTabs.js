@@ -0,0 +1,28 @@
+(function(){
+    let outputData = [];
+    let tabElements = document.querySelectorAll('.tabNav .h-tabs .h-tab-btns li[id^="li-visKTTabset-"]');
+    for(let element of tabElements) {
+        let title = element.querySelector('span.title');
+        if (title === null) {
+            continue;
+        }
+        let tab = 'unknown';
+        if (title.innerHTML.includes('Køretøj')) {
+            tab = 'vehicle';
+        } else if (title.innerHTML.includes('Tekniske oplysninger')) {
+            tab = 'technical_details';
+        } else if (title.innerHTML.includes('Syn')) {
+            tab = 'inspection';
+        } else if (title.innerHTML.includes('Forsikring')) {
+            tab = 'insurance';
+        } else if (title.innerHTML.includes('tilladelser')) {
+            tab = 'permissions';
+        }
+        outputData.push({
+            id: element.id,
+            tab: tab,
+            selected: element.classList.contains('selected')
+        });
+    }
+    return outputData
+})()
@@ -34,6 +34,24 @@
         }
         return null;
     };
+    const findLabel = (e) => {
+        let label = e.closest('.colLabel');
+        if (label === null) {
+            return null;
+        }
+        return label.innerText.trim().replace(/:$/, '');
+    };
+    const findHistoryType = (e) => {
+        while (e.parentNode !== null && e.tagName.toLowerCase() !== 'body') {
+            e = e.parentNode;
+            for (let child of e.children) {
+                if (['h2', 'h3', 'h4'].includes(child.tagName.toLowerCase())) {
+                    return child.innerText.trim();
+                }
+            }
+        }
+        return 'history';
+    };
     if (selectedTab.length === 0) {
         return {
             error: true,
@@ -57,14 +75,29 @@
         outputData["never_inspected"] = document.body.innerHTML.includes('Køretøjet har aldrig været synet.');
         outputData["called_for_inspection"] = !(document.body.innerHTML.includes('Køretøjet er ikke indkaldt til syn.'))
     }
-    if (["vehicle", "technical_details", "inspection"].includes(tab)) {
+    if (["vehicle", "technical_details", "inspection", "insurance", "permissions"].includes(tab)) {
         let elementList = document.querySelectorAll('[id^="ptr-dmr:portlet"]');
+        let historyEvents = {};
         for(let element of elementList) {
             let outputKey = element.id
                 .replaceAll(':', '_')
                 .replaceAll('-', '_')
                 .replaceAll('.', '_')
             if (outputKey.includes('HstrskVsnng')) {
+                // every entry of the history repeats the same fields, the id without its last part names the entry
+                let historyValue = findValueObject(element);
+                if (historyValue === null) {
+                    continue;
+                }
+                let separator = Math.max(element.id.lastIndexOf(':'), element.id.lastIndexOf('.'));
+                let entry = element.id.substring(0, separator);
+                if (typeof historyEvents[entry] !== 'object') {
+                    historyEvents[entry] = {
+                        type: findHistoryType(element),
+                        details: {}
+                    };
+                }
+                historyEvents[entry].details[findLabel(element) || element.id.substring(separator + 1)] = historyValue;
                 continue;
             }
             let outputValue = findValueObject(element)
@@ -72,6 +105,9 @@
                 outputData[outputKey] = outputValue
             }
         }
+        if (Object.keys(historyEvents).length > 0) {
+            outputData["history"] = Object.values(historyEvents);
+        }
     }
     let identityElements = document.querySelectorAll('.bluebox .keyvalue')
     if (identityElements.length > 0) {
@@ -0,0 +1,120 @@
+package scrape
+
+import (
+	"go-scrape-this/server/app/scrape/fixture"
+	"go-scrape-this/server/app/scrape/recipe"
+	"os"
+	"os/exec"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// browserNames - the executables chromedp looks for when starting a browser
+var browserNames = []string{"headless_shell", "headless-shell", "chromium", "chromium-browser", "google-chrome", "google-chrome-stable", "chrome"}
+
+func openFixture(t *testing.T) *fixture.Archive {
+	archive, err := fixture.Open("testdata/dmr-vehicle.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return archive
+}
+
+func TestReplayStaticEngine(t *testing.T) {
+	registry, err := recipe.NewRegistry(embeddedRecipeFiles(), os.DirFS("testdata"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, err := registry.Get("dmr-vehicle-static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := openFixture(t)
+	result, err := runRecipe(registry, found, archive.Manifest.Variables, RunOptions{
+		Timeout: time.Second * 10,
+		Replay:  archive,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"vehicle": map[string]interface{}{
+			"registration_number":  "AB12345",
+			"vin":                  "WVWZZZ1KZ8W012345",
+			"type_approval_number": "0012345",
+		},
+		"technical_details": map[string]interface{}{
+			"fuel": "Benzin",
+			"tabs": []interface{}{"Køretøj", "Tekniske oplysninger", "Syn", "Forsikring", "Tilladelser"},
+		},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("expected %v, got %v", expected, result)
+	}
+}
+
+func TestReplayStaticEngineWithoutRecordedResponse(t *testing.T) {
+	registry, err := recipe.NewRegistry(embeddedRecipeFiles(), os.DirFS("testdata"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, err := registry.Get("dmr-vehicle-static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the search for another vehicle was never recorded, so it must fail instead of reaching the network
+	_, err = runRecipe(registry, found, map[string]interface{}{"value": "XY98765"}, RunOptions{
+		Timeout: time.Second * 10,
+		Replay:  openFixture(t),
+	})
+	if err == nil {
+		t.Fatal("expected the unrecorded search to fail")
+	}
+}
+
+func TestReplayDmrVehicle(t *testing.T) {
+	installed := false
+	for _, name := range browserNames {
+		if _, err := exec.LookPath(name); err == nil {
+			installed = true
+			break
+		}
+	}
+	if !installed {
+		t.Skip("no chrome or chromium installed")
+	}
+	archive := openFixture(t)
+	result, err := RunRecipe(DmrVehicleRecipe, archive.Manifest.Variables, RunOptions{
+		Timeout: time.Second * 60,
+		Replay:  archive,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tab := range AllVehicleTabs {
+		if _, found := result[tab.String()]; !found {
+			t.Errorf("tab %s was not scraped", tab)
+		}
+		if _, found := result[tab.String()+"_image"]; !found {
+			t.Errorf("tab %s has no screenshot", tab)
+		}
+	}
+	vehicle, _ := result[TAB_VEHICLE.String()].(map[string]interface{})
+	if vehicle["ptr_dmr_portlet_stelnr"] != "WVWZZZ1KZ8W012345" {
+		t.Errorf("unexpected vehicle %v", vehicle)
+	}
+	inspection, _ := result[TAB_INSPECTION.String()].(map[string]interface{})
+	if inspection["called_for_inspection"] != false || inspection["never_inspected"] != false {
+		t.Errorf("unexpected inspection %v", inspection)
+	}
+	found, err := Recipes().Get(DmrVehicleRecipe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, check := range found.Schema.Check(result) {
+		if check.Required && check.Missing {
+			t.Errorf("required field %s is missing", check.Field)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package scrape
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var (
+	registrationNumberPattern = regexp.MustCompile(`^[A-ZÆØÅ0-9]{1,7}$`)
+	vinPattern                = regexp.MustCompile(`^[A-HJ-NPR-Z0-9]{17}$`)
+	vehicleIdPattern          = regexp.MustCompile(`^[0-9]{1,20}$`)
+	separatorReplacer         = strings.NewReplacer(" ", "", "-", "", ".", "")
+)
+
+type SearchType struct {
+	value    string
+	selector string
+	pattern  *regexp.Regexp
+}
+
+func (s SearchType) String() string {
+	return s.value
+}
+
+// Normalize - normalizes the given search value and validates it against the search type
+func (s SearchType) Normalize(value string) (string, error) {
+	value = strings.ToUpper(separatorReplacer.Replace(strings.TrimSpace(value)))
+	if !s.pattern.MatchString(value) {
+		return "", errors.New("invalid value for search type \"" + s.value + "\"")
+	}
+	return value, nil
+}
+
+func ParseSearchType(value string) (*SearchType, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch value {
+	case REGISTRATION_NUMBER.String():
+		return REGISTRATION_NUMBER, nil
+	case VIN.String():
+		return VIN, nil
+	case VEHICLE_ID.String():
+		return VEHICLE_ID, nil
+	}
+	return nil, errors.New("unknown or unsupported search type")
+}
+
+var (
+	REGISTRATION_NUMBER = &SearchType{
+		value:    "registration_number",
+		selector: "#regnr",
+		pattern:  registrationNumberPattern,
+	}
+	VIN = &SearchType{
+		value:    "vin",
+		selector: "#stelnr",
+		pattern:  vinPattern,
+	}
+	VEHICLE_ID = &SearchType{
+		value:    "vehicle_id",
+		selector: "#kid",
+		pattern:  vehicleIdPattern,
+	}
+)
@@ -0,0 +1,58 @@
+package scrape
+
+import (
+	"errors"
+	"golang.org/x/exp/slices"
+	"strings"
+)
+
+type VehicleTab struct {
+	value string
+}
+
+func (t VehicleTab) String() string {
+	return t.value
+}
+
+func ParseVehicleTab(value string) (*VehicleTab, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	for _, tab := range AllVehicleTabs {
+		if tab.String() == value {
+			return tab, nil
+		}
+	}
+	return nil, errors.New("unknown vehicle tab: \"" + value + "\"")
+}
+
+// ParseVehicleTabs - parses a list of tab names, an empty list selects every tab
+func ParseVehicleTabs(values []string) ([]*VehicleTab, error) {
+	output := []*VehicleTab{}
+	for _, value := range values {
+		if len(strings.TrimSpace(value)) == 0 {
+			continue
+		}
+		tab, err := ParseVehicleTab(value)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(output, tab) {
+			output = append(output, tab)
+		}
+	}
+	return output, nil
+}
+
+var (
+	TAB_VEHICLE           = &VehicleTab{value: "vehicle"}
+	TAB_TECHNICAL_DETAILS = &VehicleTab{value: "technical_details"}
+	TAB_INSPECTION        = &VehicleTab{value: "inspection"}
+	TAB_INSURANCE         = &VehicleTab{value: "insurance"}
+	TAB_PERMISSIONS       = &VehicleTab{value: "permissions"}
+	AllVehicleTabs        = []*VehicleTab{
+		TAB_VEHICLE,
+		TAB_TECHNICAL_DETAILS,
+		TAB_INSPECTION,
+		TAB_INSURANCE,
+		TAB_PERMISSIONS,
+	}
+)
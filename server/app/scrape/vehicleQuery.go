@@ -0,0 +1,152 @@
+package scrape
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/samber/lo"
+	"go-scrape-this/server/app/scrape/recipe"
+	"sort"
+	"strings"
+)
+
+// VehicleQuery - a validated and normalized vehicle lookup
+type VehicleQuery struct {
+	SearchType *SearchType
+	Value      string
+	Tabs       []*VehicleTab
+	Profile    string
+	Screenshot recipe.ScreenshotOptions
+	// Har - captures the network activity of the lookup as a HAR file, the capture does not change the result
+	// so it is not part of the cache key
+	Har bool
+	// Archive - archives the exchanges of the lookup as a WARC file, not part of the cache key either
+	Archive bool
+}
+
+func NewVehicleQuery(searchType string, value string, tabs []string) (VehicleQuery, error) {
+	parsedType, err := ParseSearchType(searchType)
+	if err != nil {
+		return VehicleQuery{}, err
+	}
+	normalized, err := parsedType.Normalize(value)
+	if err != nil {
+		return VehicleQuery{}, err
+	}
+	parsedTabs, err := ParseVehicleTabs(tabs)
+	if err != nil {
+		return VehicleQuery{}, err
+	}
+	return VehicleQuery{
+		SearchType: parsedType,
+		Value:      normalized,
+		Tabs:       parsedTabs,
+	}, nil
+}
+
+func (q VehicleQuery) TabNames() []string {
+	return lo.Map(q.Tabs, func(t *VehicleTab, _ int) string {
+		return t.String()
+	})
+}
+
+// WithScreenshot - the query scraped with screenshots captured with the options instead of those of the recipe
+func (q VehicleQuery) WithScreenshot(options recipe.ScreenshotOptions) (VehicleQuery, error) {
+	if err := options.Validate(); err != nil {
+		return VehicleQuery{}, fmt.Errorf("invalid screenshot options: %w", err)
+	}
+	q.Screenshot = options
+	return q, nil
+}
+
+// WithProfile - the query scraped with the named browser profile, an empty name uses the profile of the recipe
+func (q VehicleQuery) WithProfile(profile string) (VehicleQuery, error) {
+	if len(profile) > 0 {
+		if _, err := Profile(profile); err != nil {
+			return VehicleQuery{}, err
+		}
+	}
+	q.Profile = profile
+	return q, nil
+}
+
+func (q VehicleQuery) ToMap() map[string]interface{} {
+	output := map[string]interface{}{
+		"search_type": q.SearchType.String(),
+		"value":       q.Value,
+		"tabs":        q.TabNames(),
+	}
+	if len(q.Profile) > 0 {
+		output["profile"] = q.Profile
+	}
+	if !q.Screenshot.IsEmpty() {
+		output["screenshot"] = q.Screenshot
+	}
+	if q.Har {
+		output["har"] = true
+	}
+	if q.Archive {
+		output["archive"] = true
+	}
+	return output
+}
+
+// CacheKey - a key identifying the query, queries for the same tabs in a different order share a key
+func (q VehicleQuery) CacheKey() string {
+	tabs := q.TabNames()
+	if len(tabs) == 0 {
+		tabs = []string{"all"}
+	}
+	sort.Strings(tabs)
+	key := q.SearchType.String() + ":" + q.Value + ":" + strings.Join(tabs, ",")
+	if len(q.Profile) == 0 && q.Screenshot.IsEmpty() {
+		return key
+	}
+	// the profile and screenshot options have no bound on their length, so they are keyed by their digest
+	// to keep the key within the size of the cache key column
+	options, _ := json.Marshal(q.Screenshot)
+	sum := sha256.Sum256([]byte(q.Profile + "\n" + string(options)))
+	return key + ":" + hex.EncodeToString(sum[:])
+}
+
+// VehicleQueryFromMap - restores a query from the map created by ToMap
+func VehicleQueryFromMap(data map[string]interface{}) (VehicleQuery, error) {
+	searchType, ok := data["search_type"].(string)
+	if !ok {
+		return VehicleQuery{}, errors.New("missing search type")
+	}
+	value, ok := data["value"].(string)
+	if !ok {
+		return VehicleQuery{}, errors.New("missing search value")
+	}
+	tabs := []string{}
+	switch list := data["tabs"].(type) {
+	case []string:
+		tabs = list
+	case []interface{}:
+		for _, tab := range list {
+			tabs = append(tabs, fmt.Sprint(tab))
+		}
+	}
+	query, err := NewVehicleQuery(searchType, value, tabs)
+	if err != nil {
+		return VehicleQuery{}, err
+	}
+	profile, _ := data["profile"].(string)
+	query.Profile = profile
+	query.Har = data["har"] == true
+	query.Archive = data["archive"] == true
+	// the options are a struct when the query was just created and a map once the job was stored
+	if screenshot, found := data["screenshot"]; found {
+		encoded, err := json.Marshal(screenshot)
+		if err == nil {
+			err = json.Unmarshal(encoded, &query.Screenshot)
+		}
+		if err != nil {
+			return VehicleQuery{}, fmt.Errorf("invalid screenshot options: %w", err)
+		}
+	}
+	return query, nil
+}
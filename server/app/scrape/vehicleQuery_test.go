@@ -0,0 +1,51 @@
+package scrape
+
+import (
+	"go-scrape-this/server/app/scrape/recipe"
+	"strings"
+	"testing"
+)
+
+func TestVehicleQueryCacheKey(t *testing.T) {
+	query, err := NewVehicleQuery("vehicle_id", strings.Repeat("9", 20), []string{"permissions", "vehicle", "technical_details", "inspection", "insurance"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reordered, err := NewVehicleQuery("vehicle_id", strings.Repeat("9", 20), []string{"vehicle", "insurance", "inspection", "technical_details", "permissions"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := query.CacheKey()
+	if plain != reordered.CacheKey() {
+		t.Errorf("expected the order of the tabs to share a key, got %s and %s", plain, reordered.CacheKey())
+	}
+	if !strings.HasPrefix(plain, "vehicle_id:"+strings.Repeat("9", 20)+":") {
+		t.Errorf("expected a readable key, got %s", plain)
+	}
+
+	long, err := query.WithScreenshot(recipe.ScreenshotOptions{Selector: "#" + strings.Repeat("a", 2000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := query.WithScreenshot(recipe.ScreenshotOptions{Selector: "#" + strings.Repeat("a", 1999)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := map[string]bool{plain: true}
+	for _, key := range []string{long.CacheKey(), other.CacheKey()} {
+		// the size of the cache key column
+		if len(key) > 191 {
+			t.Errorf("expected a key of at most 191 characters, got %d", len(key))
+		}
+		if !strings.HasPrefix(key, plain+":") {
+			t.Errorf("expected the key to start with the readable part, got %s", key)
+		}
+		if keys[key] {
+			t.Errorf("expected different options to get different keys, got %s twice", key)
+		}
+		keys[key] = true
+	}
+	if long.CacheKey() != long.CacheKey() {
+		t.Error("expected the key to be stable")
+	}
+}
@@ -0,0 +1,278 @@
+package warc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// replacedHeaders - headers that no longer describe the body once it is stored decoded, they are kept under
+// a prefixed name
+var replacedHeaders = []string{"content-encoding", "content-length", "transfer-encoding"}
+
+// originalHeaderPrefix - the prefix of replaced headers
+const originalHeaderPrefix = "X-Archive-Orig-"
+
+// Exchange - a request and the response it received, the body is the decoded body
+type Exchange struct {
+	Method          string
+	URL             string
+	RequestHeaders  []Header
+	RequestBody     []byte
+	Status          int
+	StatusText      string
+	ResponseHeaders []Header
+	Body            []byte
+	// Truncated - the body is cut off at the size caps
+	Truncated bool
+	RemoteIP  string
+	Date      time.Time
+}
+
+// Options - the size caps of an archive
+type Options struct {
+	// MaxSize - the bytes of all response bodies kept, bodies exceeding the budget are truncated. 0 keeps every
+	// body in full.
+	MaxSize int
+	// Software - the name and version of the application written into the warcinfo record
+	Software string
+}
+
+// Info - describes the scrape an archive was made by, the job id is referenced by every record
+type Info struct {
+	JobID  string
+	Fields []Header
+	// Metadata - written as a JSON metadata record after the exchanges
+	Metadata map[string]interface{}
+}
+
+// Archiver - collects the exchanges of a scrape, safe for concurrent use
+type Archiver struct {
+	options   Options
+	lock      sync.Mutex
+	exchanges []Exchange
+	bodyBytes int
+}
+
+// NewArchiver - creates an archiver for a single scrape
+func NewArchiver(options Options) *Archiver {
+	return &Archiver{options: options}
+}
+
+// Add - adds an exchange, exchanges of other than http(s) urls never leave the browser and are not archived
+func (a *Archiver) Add(exchange Exchange) {
+	parsed, err := url.Parse(exchange.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return
+	}
+	if exchange.Date.IsZero() {
+		exchange.Date = time.Now()
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.options.MaxSize > 0 {
+		remaining := a.options.MaxSize - a.bodyBytes
+		if remaining < 0 {
+			remaining = 0
+		}
+		if len(exchange.Body) > remaining {
+			exchange.Body = exchange.Body[:remaining]
+			exchange.Truncated = true
+		}
+	}
+	a.bodyBytes += len(exchange.Body)
+	a.exchanges = append(a.exchanges, exchange)
+}
+
+// Len - the number of archived exchanges
+func (a *Archiver) Len() int {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return len(a.exchanges)
+}
+
+// Write - writes the archive as a gzipped WARC file: a warcinfo record, a request and response record for
+// every exchange and a metadata record. Returns the number of records written.
+func (a *Archiver) Write(w io.Writer, info Info) (int, error) {
+	a.lock.Lock()
+	exchanges := append([]Exchange{}, a.exchanges...)
+	a.lock.Unlock()
+
+	writer := NewWriter(w)
+	infoID := NewRecordID()
+	jobField := []Header{}
+	if len(info.JobID) > 0 {
+		// an extension field, so every record can be traced back to the job on its own
+		jobField = append(jobField, Header{Name: "WARC-Job-ID", Value: info.JobID})
+	}
+	jobFields := append([]Header{{Name: "WARC-Warcinfo-ID", Value: infoID}}, jobField...)
+
+	warcinfo := []Header{
+		{Name: "software", Value: a.options.Software},
+		{Name: "format", Value: "WARC File Format 1.1"},
+		{Name: "conformsTo", Value: "https://iipc.github.io/warc-specifications/specifications/warc-format/warc-1.1/"},
+	}
+	if len(info.JobID) > 0 {
+		warcinfo = append(warcinfo, Header{Name: "job-id", Value: info.JobID})
+	}
+	warcinfo = append(warcinfo, info.Fields...)
+	err := writer.Write(Record{
+		Type:        WARCINFO,
+		ID:          infoID,
+		ContentType: "application/warc-fields",
+		Fields:      jobField,
+		Block:       fieldsBlock(warcinfo),
+	})
+	if err != nil {
+		return writer.Written(), err
+	}
+
+	for _, exchange := range exchanges {
+		requestID := NewRecordID()
+		err = writer.Write(Record{
+			Type:        REQUEST,
+			ID:          requestID,
+			Date:        exchange.Date,
+			TargetURI:   exchange.URL,
+			ContentType: "application/http;msgtype=request",
+			Fields:      jobFields,
+			Block:       requestBlock(exchange),
+		})
+		if err != nil {
+			return writer.Written(), err
+		}
+		fields := append([]Header{}, jobFields...)
+		fields = append(fields, Header{Name: "WARC-Concurrent-To", Value: requestID})
+		if len(exchange.RemoteIP) > 0 {
+			fields = append(fields, Header{Name: "WARC-IP-Address", Value: exchange.RemoteIP})
+		}
+		fields = append(fields, Header{Name: "WARC-Payload-Digest", Value: Digest(exchange.Body)})
+		if exchange.Truncated {
+			fields = append(fields, Header{Name: "WARC-Truncated", Value: "length"})
+		}
+		err = writer.Write(Record{
+			Type:        RESPONSE,
+			Date:        exchange.Date,
+			TargetURI:   exchange.URL,
+			ContentType: "application/http;msgtype=response",
+			Fields:      fields,
+			Block:       responseBlock(exchange),
+		})
+		if err != nil {
+			return writer.Written(), err
+		}
+	}
+
+	if info.Metadata != nil {
+		metadata, err := json.Marshal(info.Metadata)
+		if err != nil {
+			return writer.Written(), err
+		}
+		record := Record{
+			Type:        METADATA,
+			ContentType: "application/json",
+			Fields:      jobFields,
+			Block:       metadata,
+		}
+		if len(exchanges) > 0 {
+			record.TargetURI = exchanges[0].URL
+		}
+		err = writer.Write(record)
+		if err != nil {
+			return writer.Written(), err
+		}
+	}
+	return writer.Written(), nil
+}
+
+func fieldsBlock(fields []Header) []byte {
+	var block bytes.Buffer
+	for _, field := range fields {
+		writeField(&block, field.Name, field.Value)
+	}
+	return block.Bytes()
+}
+
+// requestBlock - the request as a http message, the browser may use newer protocols but the message is written
+// as HTTP/1.1 the way archive readers expect it
+func requestBlock(exchange Exchange) []byte {
+	var block bytes.Buffer
+	target := exchange.URL
+	host := ""
+	if parsed, err := url.Parse(exchange.URL); err == nil {
+		target = parsed.RequestURI()
+		host = parsed.Host
+	}
+	block.WriteString(fmt.Sprintf("%s %s HTTP/1.1\r\n", exchange.Method, target))
+	headers := exchange.RequestHeaders
+	if len(host) > 0 && !hasHeader(headers, "host") {
+		headers = append([]Header{{Name: "Host", Value: host}}, headers...)
+	}
+	for _, header := range headers {
+		if strings.HasPrefix(header.Name, ":") {
+			// pseudo headers of http/2 are part of the request line
+			continue
+		}
+		writeField(&block, header.Name, header.Value)
+	}
+	block.WriteString("\r\n")
+	block.Write(exchange.RequestBody)
+	return block.Bytes()
+}
+
+func responseBlock(exchange Exchange) []byte {
+	var block bytes.Buffer
+	block.WriteString(strings.TrimSpace(fmt.Sprintf("HTTP/1.1 %d %s", exchange.Status, exchange.StatusText)) + "\r\n")
+	for _, header := range exchange.ResponseHeaders {
+		name := header.Name
+		if isReplacedHeader(name) {
+			name = originalHeaderPrefix + name
+		}
+		writeField(&block, name, header.Value)
+	}
+	writeField(&block, "Content-Length", fmt.Sprint(len(exchange.Body)))
+	block.WriteString("\r\n")
+	block.Write(exchange.Body)
+	return block.Bytes()
+}
+
+func hasHeader(headers []Header, name string) bool {
+	for _, header := range headers {
+		if strings.EqualFold(header.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func isReplacedHeader(name string) bool {
+	for _, replaced := range replacedHeaders {
+		if strings.EqualFold(name, replaced) {
+			return true
+		}
+	}
+	return false
+}
+
+// SortedHeaders - the headers of a map sorted by name, values holding several lines are split into a header
+// for each line the way browsers report repeated headers
+func SortedHeaders(headers map[string]string) []Header {
+	names := []string{}
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	output := []Header{}
+	for _, name := range names {
+		for _, line := range strings.Split(headers[name], "\n") {
+			output = append(output, Header{Name: name, Value: line})
+		}
+	}
+	return output
+}
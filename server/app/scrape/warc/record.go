@@ -0,0 +1,112 @@
+package warc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"strings"
+	"time"
+)
+
+// Version - the version of the WARC format written
+const Version = "WARC/1.1"
+
+const (
+	WARCINFO = "warcinfo"
+	REQUEST  = "request"
+	RESPONSE = "response"
+	METADATA = "metadata"
+)
+
+// Header - a named field of a record or of a http message, the order of headers is kept
+type Header struct {
+	Name  string
+	Value string
+}
+
+// Record - a record of a WARC file, the fields are written after the mandatory fields
+type Record struct {
+	Type        string
+	ID          string
+	Date        time.Time
+	TargetURI   string
+	ContentType string
+	Fields      []Header
+	Block       []byte
+}
+
+// NewRecordID - a new globally unique record id
+func NewRecordID() string {
+	return "<urn:uuid:" + uuid.New().String() + ">"
+}
+
+// Digest - the SHA-1 digest of the data in the labelled base32 form WARC files use
+func Digest(data []byte) string {
+	sum := sha1.Sum(data)
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+// Writer - writes records as separately gzipped members, so a reader can seek to any record of the file
+type Writer struct {
+	w       io.Writer
+	written int
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Write - writes the record, a missing id or date is filled in
+func (w *Writer) Write(record Record) error {
+	if len(record.ID) == 0 {
+		record.ID = NewRecordID()
+	}
+	if record.Date.IsZero() {
+		record.Date = time.Now()
+	}
+	var header bytes.Buffer
+	header.WriteString(Version + "\r\n")
+	writeField(&header, "WARC-Type", record.Type)
+	writeField(&header, "WARC-Record-ID", record.ID)
+	writeField(&header, "WARC-Date", record.Date.UTC().Format(time.RFC3339))
+	if len(record.TargetURI) > 0 {
+		writeField(&header, "WARC-Target-URI", record.TargetURI)
+	}
+	for _, field := range record.Fields {
+		writeField(&header, field.Name, field.Value)
+	}
+	if len(record.ContentType) > 0 {
+		writeField(&header, "Content-Type", record.ContentType)
+	}
+	writeField(&header, "WARC-Block-Digest", Digest(record.Block))
+	writeField(&header, "Content-Length", fmt.Sprint(len(record.Block)))
+	header.WriteString("\r\n")
+
+	member := gzip.NewWriter(w.w)
+	for _, part := range [][]byte{header.Bytes(), record.Block, []byte("\r\n\r\n")} {
+		if _, err := member.Write(part); err != nil {
+			return err
+		}
+	}
+	if err := member.Close(); err != nil {
+		return err
+	}
+	w.written++
+	return nil
+}
+
+// Written - the number of records written
+func (w *Writer) Written() int {
+	return w.written
+}
+
+// fieldBreaks - line breaks would end a field early, so they are written as spaces
+var fieldBreaks = strings.NewReplacer("\r", " ", "\n", " ")
+
+func writeField(buffer *bytes.Buffer, name string, value string) {
+	buffer.WriteString(name + ": " + fieldBreaks.Replace(value) + "\r\n")
+}
@@ -0,0 +1,191 @@
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readRecord - a record read back from a WARC file, the fields by lowercased name
+type readRecord struct {
+	version string
+	fields  map[string]string
+	block   []byte
+}
+
+// readRecords - reads every gzip member of the file as a record, checking the framing of each
+func readRecords(t *testing.T, data []byte) []readRecord {
+	t.Helper()
+	output := []readRecord{}
+	reader := bytes.NewReader(data)
+	for reader.Len() > 0 {
+		member, err := gzip.NewReader(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// every record is a gzip member of its own
+		member.Multistream(false)
+		content, err := io.ReadAll(member)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := bufio.NewReader(bytes.NewReader(content))
+		version, _ := lines.ReadString('\n')
+		record := readRecord{version: strings.TrimSuffix(version, "\r\n"), fields: map[string]string{}}
+		for {
+			line, err := lines.ReadString('\n')
+			if err != nil {
+				t.Fatalf("record ended in the header: %q", content)
+			}
+			if !strings.HasSuffix(line, "\r\n") {
+				t.Fatalf("header line without CRLF: %q", line)
+			}
+			if line == "\r\n" {
+				break
+			}
+			name, value, found := strings.Cut(strings.TrimSuffix(line, "\r\n"), ": ")
+			if !found {
+				t.Fatalf("invalid header line %q", line)
+			}
+			record.fields[strings.ToLower(name)] = value
+		}
+		rest, _ := io.ReadAll(lines)
+		length, err := strconv.Atoi(record.fields["content-length"])
+		if err != nil || length > len(rest) {
+			t.Fatalf("invalid content length %q", record.fields["content-length"])
+		}
+		record.block = rest[:length]
+		if trailer := string(rest[length:]); trailer != "\r\n\r\n" {
+			t.Fatalf("expected the block to end with two CRLF, got %q", trailer)
+		}
+		if digest := record.fields["warc-block-digest"]; digest != Digest(record.block) {
+			t.Fatalf("expected block digest %s, got %s", Digest(record.block), digest)
+		}
+		output = append(output, record)
+	}
+	return output
+}
+
+func TestWriterFraming(t *testing.T) {
+	var data bytes.Buffer
+	writer := NewWriter(&data)
+	date := time.Date(2023, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600))
+	records := []Record{
+		{Type: WARCINFO, ContentType: "application/warc-fields", Block: []byte("software: test\r\n")},
+		{Type: RESPONSE, Date: date, TargetURI: "https://example.com/", Block: []byte("HTTP/1.1 200 OK\r\n\r\nbody")},
+		{Type: METADATA, Fields: []Header{{Name: "WARC-Job-ID", Value: "line\r\nbreak"}}, Block: []byte{}},
+	}
+	for _, record := range records {
+		if err := writer.Write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if writer.Written() != len(records) {
+		t.Fatalf("expected %d records written, got %d", len(records), writer.Written())
+	}
+	read := readRecords(t, data.Bytes())
+	if len(read) != len(records) {
+		t.Fatalf("expected %d records, got %d", len(records), len(read))
+	}
+	for i, record := range read {
+		if record.version != Version {
+			t.Errorf("record %d: expected version %s, got %s", i, Version, record.version)
+		}
+		if record.fields["warc-type"] != records[i].Type {
+			t.Errorf("record %d: expected type %s, got %s", i, records[i].Type, record.fields["warc-type"])
+		}
+		if !strings.HasPrefix(record.fields["warc-record-id"], "<urn:uuid:") {
+			t.Errorf("record %d: unexpected id %s", i, record.fields["warc-record-id"])
+		}
+		if !bytes.Equal(record.block, records[i].Block) {
+			t.Errorf("record %d: expected block %q, got %q", i, records[i].Block, record.block)
+		}
+	}
+	if date := read[1].fields["warc-date"]; date != "2023-01-02T02:04:05Z" {
+		t.Errorf("expected the date in UTC, got %s", date)
+	}
+	if target := read[1].fields["warc-target-uri"]; target != "https://example.com/" {
+		t.Errorf("unexpected target %s", target)
+	}
+	if job := read[2].fields["warc-job-id"]; job != "line  break" {
+		t.Errorf("expected line breaks of fields written as spaces, got %q", job)
+	}
+}
+
+func TestArchiverWrite(t *testing.T) {
+	archiver := NewArchiver(Options{MaxSize: 6, Software: "test"})
+	archiver.Add(Exchange{Method: "GET", URL: "data:text/plain,ignored"})
+	archiver.Add(Exchange{
+		Method:          "POST",
+		URL:             "https://example.com/search?q=1",
+		RequestHeaders:  []Header{{Name: ":authority", Value: "example.com"}, {Name: "Content-Type", Value: "text/plain"}},
+		RequestBody:     []byte("query"),
+		Status:          200,
+		StatusText:      "OK",
+		ResponseHeaders: []Header{{Name: "Content-Encoding", Value: "gzip"}},
+		Body:            []byte("found"),
+		RemoteIP:        "127.0.0.1",
+	})
+	archiver.Add(Exchange{Method: "GET", URL: "https://example.com/image.png", Status: 200, Body: []byte("image")})
+	if archiver.Len() != 2 {
+		t.Fatalf("expected 2 archived exchanges, got %d", archiver.Len())
+	}
+
+	var data bytes.Buffer
+	written, err := archiver.Write(&data, Info{
+		JobID:    "job",
+		Fields:   []Header{{Name: "recipe", Value: "test"}},
+		Metadata: map[string]interface{}{"result": "found"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := readRecords(t, data.Bytes())
+	if written != len(read) || len(read) != 6 {
+		t.Fatalf("expected 6 records, got %d written and %d read", written, len(read))
+	}
+	types := []string{WARCINFO, REQUEST, RESPONSE, REQUEST, RESPONSE, METADATA}
+	for i, record := range read {
+		if record.fields["warc-type"] != types[i] {
+			t.Errorf("record %d: expected type %s, got %s", i, types[i], record.fields["warc-type"])
+		}
+		if i > 0 && record.fields["warc-warcinfo-id"] != read[0].fields["warc-record-id"] {
+			t.Errorf("record %d does not refer to the warcinfo record", i)
+		}
+		if record.fields["warc-job-id"] != "job" {
+			t.Errorf("record %d: expected the job id, got %q", i, record.fields["warc-job-id"])
+		}
+	}
+	if info := string(read[0].block); !strings.Contains(info, "software: test\r\n") || !strings.Contains(info, "recipe: test\r\n") {
+		t.Errorf("unexpected warcinfo %q", info)
+	}
+	request := string(read[1].block)
+	if !strings.HasPrefix(request, "POST /search?q=1 HTTP/1.1\r\nHost: example.com\r\n") || strings.Contains(request, ":authority") {
+		t.Errorf("unexpected request %q", request)
+	}
+	if !strings.HasSuffix(request, "\r\n\r\nquery") {
+		t.Errorf("expected the request body after the headers, got %q", request)
+	}
+	response := read[2]
+	if response.fields["warc-concurrent-to"] != read[1].fields["warc-record-id"] {
+		t.Error("expected the response to refer to its request")
+	}
+	if response.fields["warc-payload-digest"] != Digest([]byte("found")) {
+		t.Errorf("unexpected payload digest %s", response.fields["warc-payload-digest"])
+	}
+	if !strings.Contains(string(response.block), originalHeaderPrefix+"Content-Encoding: gzip\r\n") {
+		t.Errorf("expected the content encoding to be replaced, got %q", response.block)
+	}
+	truncated := read[4]
+	if truncated.fields["warc-truncated"] != "length" || !strings.HasSuffix(string(truncated.block), "\r\n\r\ni") {
+		t.Errorf("expected the body cut at the size cap, got %q", truncated.block)
+	}
+	if string(read[5].block) != `{"result":"found"}` {
+		t.Errorf("unexpected metadata %q", read[5].block)
+	}
+}
@@ -0,0 +1,65 @@
+package warc
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Transport - archives every exchange passing through the base transport
+type Transport struct {
+	Base     http.RoundTripper
+	Archiver *Archiver
+}
+
+func (t Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var requestBody []byte
+	if req.Body != nil && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		requestBody, err = io.ReadAll(body)
+		body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	date := time.Now()
+	res, err := t.Base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	t.Archiver.Add(Exchange{
+		Method:          req.Method,
+		URL:             req.URL.String(),
+		RequestHeaders:  headerList(req.Header),
+		RequestBody:     requestBody,
+		Status:          res.StatusCode,
+		StatusText:      http.StatusText(res.StatusCode),
+		ResponseHeaders: headerList(res.Header),
+		Body:            body,
+		Date:            date,
+	})
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	return res, nil
+}
+
+func headerList(header http.Header) []Header {
+	flattened := map[string]string{}
+	for name, values := range header {
+		for _, value := range values {
+			if previous, found := flattened[name]; found {
+				value = previous + "\n" + value
+			}
+			flattened[name] = value
+		}
+	}
+	return SortedHeaders(flattened)
+}
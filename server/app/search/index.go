@@ -0,0 +1,242 @@
+package search
+
+import (
+	"fmt"
+	"go-scrape-this/server/app/database"
+	"go-scrape-this/server/app/database/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	FTS5     = "fts5"
+	TSVECTOR = "tsvector"
+	LIKE     = "like"
+)
+
+// maxContentSize - the size of the searched content of a result, longer content is cut at a character boundary
+const maxContentSize = 65535
+
+// likeEscape - escapes the wildcards of LIKE patterns, the character is not special in any of the databases
+var likeEscape = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// Query - the full-text terms and attributes results must match, every term has to be found
+type Query struct {
+	Text    string
+	Source  string
+	Subject string
+	Make    string
+	Model   string
+	Year    *int
+	Insurer string
+	Vin     string
+	From    *time.Time
+	To      *time.Time
+	Limit   int
+	Offset  int
+}
+
+// Index - stores scrape results and searches them with the full-text search of the database, SQLite uses an
+// FTS5 table, PostgreSQL a tsvector column and the other databases fall back to LIKE queries
+type Index struct {
+	db      *database.Database
+	backend string
+}
+
+func NewIndex(db *database.Database) *Index {
+	return &Index{
+		db:      db,
+		backend: LIKE,
+	}
+}
+
+// Backend - the full-text search in use, known after setup
+func (i *Index) Backend() string {
+	return i.backend
+}
+
+// Setup - creates the full-text index after the migrations. SQLite builds without FTS5 fall back to LIKE
+// queries, which is returned as an error so it can be logged.
+func (i *Index) Setup() error {
+	db := i.db.Connection()
+	switch i.db.Type() {
+	case database.SQLITE:
+		var existing int64
+		result := db.Raw("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'scrape_results_fts'").Scan(&existing)
+		if result.Error != nil {
+			return result.Error
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			statements := []string{
+				"CREATE VIRTUAL TABLE IF NOT EXISTS scrape_results_fts USING fts5(content, content='scrape_results', content_rowid='id')",
+				`CREATE TRIGGER IF NOT EXISTS scrape_results_fts_insert AFTER INSERT ON scrape_results BEGIN
+					INSERT INTO scrape_results_fts(rowid, content) VALUES (new.id, new.content);
+				END`,
+				`CREATE TRIGGER IF NOT EXISTS scrape_results_fts_delete AFTER DELETE ON scrape_results BEGIN
+					INSERT INTO scrape_results_fts(scrape_results_fts, rowid, content) VALUES ('delete', old.id, old.content);
+				END`,
+				`CREATE TRIGGER IF NOT EXISTS scrape_results_fts_update AFTER UPDATE ON scrape_results BEGIN
+					INSERT INTO scrape_results_fts(scrape_results_fts, rowid, content) VALUES ('delete', old.id, old.content);
+					INSERT INTO scrape_results_fts(rowid, content) VALUES (new.id, new.content);
+				END`,
+			}
+			if existing == 0 {
+				// results stored while the index was unavailable
+				statements = append(statements, "INSERT INTO scrape_results_fts(scrape_results_fts) VALUES ('rebuild')")
+			}
+			for _, statement := range statements {
+				if err := tx.Exec(statement).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("full-text search falls back to LIKE queries: %w", err)
+		}
+		i.backend = FTS5
+	case database.POSTGRESQL:
+		statements := []string{
+			"ALTER TABLE scrape_results ADD COLUMN IF NOT EXISTS search_vector tsvector " +
+				"GENERATED ALWAYS AS (to_tsvector('simple', coalesce(content, ''))) STORED",
+			"CREATE INDEX IF NOT EXISTS idx_scrape_results_search_vector ON scrape_results USING GIN (search_vector)",
+		}
+		for _, statement := range statements {
+			if err := db.Exec(statement).Error; err != nil {
+				return fmt.Errorf("full-text search falls back to LIKE queries: %w", err)
+			}
+		}
+		i.backend = TSVECTOR
+	}
+	return nil
+}
+
+// Store - stores the result, a result of the same job replaces the stored one
+func (i *Index) Store(result *models.ScrapeResult) error {
+	return i.db.Connection().Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "job_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"source",
+			"subject",
+			"make",
+			"model",
+			"first_registration_year",
+			"insurer",
+			"vin",
+			"content",
+			"data",
+			"scraped_at",
+		}),
+	}).Create(result).Error
+}
+
+// Search - the matching results, newest first, and how many results match in total
+func (i *Index) Search(query Query) ([]models.ScrapeResult, int64, error) {
+	filtered := i.filter(query)
+	var total int64
+	result := filtered.Count(&total)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+	var output []models.ScrapeResult
+	result = filtered.Order("scraped_at DESC").Order("id DESC").Limit(query.Limit).Offset(query.Offset).Find(&output)
+	return output, total, result.Error
+}
+
+func (i *Index) filter(query Query) *gorm.DB {
+	db := i.db.Connection().Model(&models.ScrapeResult{})
+	if terms := strings.Fields(query.Text); len(terms) > 0 {
+		switch i.backend {
+		case FTS5:
+			quoted := make([]string, len(terms))
+			for n, term := range terms {
+				quoted[n] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+			}
+			db = db.Where(
+				"id IN (SELECT rowid FROM scrape_results_fts WHERE scrape_results_fts MATCH ?)",
+				strings.Join(quoted, " "),
+			)
+		case TSVECTOR:
+			db = db.Where("search_vector @@ plainto_tsquery('simple', ?)", strings.Join(terms, " "))
+		default:
+			for _, term := range terms {
+				db = db.Where("LOWER(content) LIKE ? ESCAPE '!'", containsPattern(term))
+			}
+		}
+	}
+	if len(query.Source) > 0 {
+		db = db.Where("source = ?", query.Source)
+	}
+	if len(query.Subject) > 0 {
+		db = db.Where("subject = ?", query.Subject)
+	}
+	if len(query.Make) > 0 {
+		db = db.Where("make = ?", strings.ToUpper(strings.TrimSpace(query.Make)))
+	}
+	if len(query.Model) > 0 {
+		db = db.Where("model = ?", strings.ToUpper(strings.TrimSpace(query.Model)))
+	}
+	if query.Year != nil {
+		db = db.Where("first_registration_year = ?", *query.Year)
+	}
+	if len(query.Insurer) > 0 {
+		db = db.Where("LOWER(insurer) LIKE ? ESCAPE '!'", containsPattern(query.Insurer))
+	}
+	if len(query.Vin) > 0 {
+		db = db.Where("vin = ?", strings.ToUpper(strings.TrimSpace(query.Vin)))
+	}
+	if query.From != nil {
+		db = db.Where("scraped_at >= ?", *query.From)
+	}
+	if query.To != nil {
+		db = db.Where("scraped_at <= ?", *query.To)
+	}
+	return db
+}
+
+func containsPattern(value string) string {
+	return "%" + likeEscape.Replace(strings.ToLower(strings.TrimSpace(value))) + "%"
+}
+
+// Content - the text of every value of the result in key order, searched by full-text queries
+func Content(result map[string]interface{}) string {
+	values := []string{}
+	collectText(&values, result)
+	content := strings.Join(values, "\n")
+	if len(content) <= maxContentSize {
+		return content
+	}
+	cut := maxContentSize
+	for cut > 0 && !utf8.RuneStart(content[cut]) {
+		cut--
+	}
+	return content[:cut]
+}
+
+func collectText(values *[]string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			collectText(values, v[key])
+		}
+	case []interface{}:
+		for _, item := range v {
+			collectText(values, item)
+		}
+	case string:
+		if trimmed := strings.TrimSpace(v); len(trimmed) > 0 {
+			*values = append(*values, trimmed)
+		}
+	case float64, int, int64:
+		*values = append(*values, fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,171 @@
+package spreadsheet
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"github.com/xuri/excelize/v2"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+const sniffLength = 4096
+
+var utf8Bom = []byte{0xEF, 0xBB, 0xBF}
+
+type Format struct {
+	value       string
+	extension   string
+	contentType string
+}
+
+func (f Format) String() string {
+	return f.value
+}
+
+func (f Format) Extension() string {
+	return f.extension
+}
+
+func (f Format) ContentType() string {
+	return f.contentType
+}
+
+func ParseFormat(value string) (*Format, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch value {
+	case CSV.String():
+		return CSV, nil
+	case XLSX.String():
+		return XLSX, nil
+	}
+	return nil, errors.New("unknown or unsupported spreadsheet format")
+}
+
+// FormatFromFilename - resolves the format from the extension of the filename
+func FormatFromFilename(filename string) (*Format, error) {
+	return ParseFormat(strings.TrimPrefix(filepath.Ext(filename), "."))
+}
+
+var (
+	CSV = &Format{
+		value:       "csv",
+		extension:   ".csv",
+		contentType: "text/csv",
+	}
+	XLSX = &Format{
+		value:       "xlsx",
+		extension:   ".xlsx",
+		contentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	}
+)
+
+// Read - reads every row of the first sheet in the spreadsheet
+func Read(format *Format, reader io.Reader) ([][]string, error) {
+	switch format {
+	case CSV:
+		return readCsv(reader)
+	case XLSX:
+		return readXlsx(reader)
+	}
+	return nil, errors.New("unknown or unsupported spreadsheet format")
+}
+
+// Write - writes the rows as a single sheet spreadsheet
+func Write(format *Format, writer io.Writer, rows [][]string) error {
+	switch format {
+	case CSV:
+		return writeCsv(writer, rows)
+	case XLSX:
+		return writeXlsx(writer, rows)
+	}
+	return errors.New("unknown or unsupported spreadsheet format")
+}
+
+func readCsv(reader io.Reader) ([][]string, error) {
+	buffered := bufio.NewReader(reader)
+	if bom, err := buffered.Peek(len(utf8Bom)); err == nil && bytes.Equal(bom, utf8Bom) {
+		_, _ = buffered.Discard(len(utf8Bom))
+	}
+	firstLine, err := buffered.Peek(sniffLength)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	csvReader := csv.NewReader(buffered)
+	csvReader.Comma = detectDelimiter(firstLine)
+	csvReader.FieldsPerRecord = -1
+	csvReader.LazyQuotes = true
+	csvReader.TrimLeadingSpace = true
+	return csvReader.ReadAll()
+}
+
+// detectDelimiter - picks the most used delimiter in the first line, spreadsheet software in
+// danish locales uses semicolons when exporting csv files
+func detectDelimiter(data []byte) rune {
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		data = data[:i]
+	}
+	delimiter := ','
+	count := bytes.Count(data, []byte{','})
+	for _, candidate := range []rune{';', '\t'} {
+		candidateCount := bytes.Count(data, []byte(string(candidate)))
+		if candidateCount > count {
+			delimiter = candidate
+			count = candidateCount
+		}
+	}
+	return delimiter
+}
+
+func readXlsx(reader io.Reader) ([][]string, error) {
+	file, err := excelize.OpenReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	sheets := file.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, errors.New("spreadsheet contains no sheets")
+	}
+	return file.GetRows(sheets[0])
+}
+
+func writeCsv(writer io.Writer, rows [][]string) error {
+	csvWriter := csv.NewWriter(writer)
+	err := csvWriter.WriteAll(rows)
+	if err != nil {
+		return err
+	}
+	return csvWriter.Error()
+}
+
+func writeXlsx(writer io.Writer, rows [][]string) error {
+	file := excelize.NewFile()
+	defer file.Close()
+	sheet := file.GetSheetName(0)
+	stream, err := file.NewStreamWriter(sheet)
+	if err != nil {
+		return err
+	}
+	for i, row := range rows {
+		cell, err := excelize.CoordinatesToCellName(1, i+1)
+		if err != nil {
+			return err
+		}
+		values := make([]interface{}, len(row))
+		for j := range row {
+			values[j] = row[j]
+		}
+		err = stream.SetRow(cell, values)
+		if err != nil {
+			return err
+		}
+	}
+	err = stream.Flush()
+	if err != nil {
+		return err
+	}
+	return file.Write(writer)
+}
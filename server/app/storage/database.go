@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"go-scrape-this/server/app/database"
+	"go-scrape-this/server/app/database/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"io"
+)
+
+// databaseStorage - stores files in the database, for deployments without a persistent disk
+type databaseStorage struct {
+	db *database.Database
+}
+
+func newDatabaseStorage(config Config) (Storage, error) {
+	if config.DB == nil {
+		return nil, errors.New("the database storage needs a database")
+	}
+	return &databaseStorage{db: config.DB}, nil
+}
+
+func (s *databaseStorage) Name() string {
+	return "database"
+}
+
+func (s *databaseStorage) Put(key string, data []byte) error {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	return s.db.Connection().
+		Clauses(clause.OnConflict{UpdateAll: true}).
+		Create(&models.StoredFile{Path: cleaned, Data: data}).
+		Error
+}
+
+func (s *databaseStorage) Open(key string) (io.ReadSeekCloser, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	var file models.StoredFile
+	result := s.db.Connection().First(&file, "path = ?", cleaned)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return storedFileReader{bytes.NewReader(file.Data)}, nil
+}
+
+func (s *databaseStorage) Delete(key string) error {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	return s.db.Connection().Delete(&models.StoredFile{}, "path = ?", cleaned).Error
+}
+
+type storedFileReader struct {
+	*bytes.Reader
+}
+
+func (r storedFileReader) Close() error {
+	return nil
+}
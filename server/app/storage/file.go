@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// fileStorage - stores files in a directory, keys are paths below it
+type fileStorage struct {
+	dir string
+}
+
+func newFileStorage(config Config) (Storage, error) {
+	if len(config.Dir) == 0 {
+		return nil, errors.New("the file storage needs a directory")
+	}
+	err := os.MkdirAll(config.Dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	return &fileStorage{dir: config.Dir}, nil
+}
+
+func (s *fileStorage) Name() string {
+	return "file"
+}
+
+// Put - writes the file through a temporary file, so a file is never seen half written
+func (s *fileStorage) Put(key string, data []byte) error {
+	filename, err := s.filename(key)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(filename), 0755)
+	if err != nil {
+		return err
+	}
+	temporary, err := os.CreateTemp(filepath.Dir(filename), ".put-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temporary.Name())
+	_, err = temporary.Write(data)
+	if closeErr := temporary.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(temporary.Name(), filename)
+}
+
+func (s *fileStorage) Open(key string) (io.ReadSeekCloser, error) {
+	filename, err := s.filename(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+func (s *fileStorage) Delete(key string) error {
+	filename, err := s.filename(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *fileStorage) filename(key string) (string, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(cleaned)), nil
+}
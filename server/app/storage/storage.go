@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"errors"
+	"go-scrape-this/server/app/database"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ErrNotFound - no file is stored under the key
+var ErrNotFound = errors.New("file not found")
+
+// Storage - keeps files under keys, keys are slash separated relative paths
+type Storage interface {
+	// Name - the name the backend is registered with
+	Name() string
+	Put(key string, data []byte) error
+	// Open - opens the file stored under the key, ErrNotFound when there is none
+	Open(key string) (io.ReadSeekCloser, error)
+	Delete(key string) error
+}
+
+// Config - the settings a backend may use
+type Config struct {
+	// Dir - the directory of backends storing files on disk
+	Dir string
+	DB  *database.Database
+}
+
+// Factory - creates a backend from the settings
+type Factory func(config Config) (Storage, error)
+
+var backends = map[string]Factory{
+	"file":     newFileStorage,
+	"database": newDatabaseStorage,
+}
+
+// Register - makes a backend available under the name, replacing a backend of the same name
+func Register(name string, factory Factory) {
+	backends[name] = factory
+}
+
+// Backends - the names of the available backends
+func Backends() []string {
+	output := []string{}
+	for name := range backends {
+		output = append(output, name)
+	}
+	sort.Strings(output)
+	return output
+}
+
+// New - creates the backend registered under the name
+func New(name string, config Config) (Storage, error) {
+	factory, found := backends[name]
+	if !found {
+		return nil, errors.New("unknown storage backend \"" + name + "\", expected one of: " + strings.Join(Backends(), ", "))
+	}
+	return factory(config)
+}
+
+// CleanKey - the key without empty or relative segments, keys leaving the root of the storage are refused
+func CleanKey(key string) (string, error) {
+	cleaned := path.Clean("/" + key)
+	if cleaned == "/" || cleaned != "/"+strings.TrimPrefix(key, "/") {
+		return "", errors.New("invalid storage key \"" + key + "\"")
+	}
+	return strings.TrimPrefix(cleaned, "/"), nil
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"errors"
+	"github.com/rs/zerolog"
+	"go-scrape-this/server/app/database"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCleanKey(t *testing.T) {
+	tests := []struct {
+		key      string
+		expected string
+		valid    bool
+	}{
+		{"dmr/vin/archive.warc.gz", "dmr/vin/archive.warc.gz", true},
+		{"/dmr/archive.warc.gz", "dmr/archive.warc.gz", true},
+		{"archive.warc.gz", "archive.warc.gz", true},
+		{"", "", false},
+		{"/", "", false},
+		{"..", "", false},
+		{"../archive.warc.gz", "", false},
+		{"dmr/../../archive.warc.gz", "", false},
+		{"dmr/../archive.warc.gz", "", false},
+		{"/../etc/passwd", "", false},
+		{"./archive.warc.gz", "", false},
+		{"dmr//archive.warc.gz", "", false},
+		{"dmr/", "", false},
+		{"dmr/.", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.key, func(t *testing.T) {
+			cleaned, err := CleanKey(test.key)
+			if test.valid != (err == nil) {
+				t.Fatalf("expected valid %v, got error %v", test.valid, err)
+			}
+			if cleaned != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, cleaned)
+			}
+		})
+	}
+}
+
+func TestBackends(t *testing.T) {
+	logger := zerolog.Nop()
+	db, err := database.NewDatabase(database.SQLITE, filepath.Join(t.TempDir(), "test.db"), &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RunMigrations(); err != nil {
+		t.Fatal(err)
+	}
+	root := t.TempDir()
+	dir := filepath.Join(root, "files")
+	for _, name := range Backends() {
+		t.Run(name, func(t *testing.T) {
+			backend, err := New(name, Config{Dir: dir, DB: &db})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if backend.Name() != name {
+				t.Errorf("expected name %s, got %s", name, backend.Name())
+			}
+			if err := backend.Put("dmr/file.txt", []byte("first")); err != nil {
+				t.Fatal(err)
+			}
+			if err := backend.Put("dmr/file.txt", []byte("second")); err != nil {
+				t.Fatal(err)
+			}
+			file, err := backend.Open("/dmr/file.txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := io.ReadAll(file)
+			file.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != "second" {
+				t.Errorf("expected the replaced file, got %q", data)
+			}
+			if err := backend.Put("../outside.txt", []byte("escaped")); err == nil {
+				t.Error("expected a key leaving the storage to be refused")
+			}
+			if _, err := os.Stat(filepath.Join(root, "outside.txt")); !errors.Is(err, os.ErrNotExist) {
+				t.Error("expected no file outside the storage directory")
+			}
+			if _, err := backend.Open("dmr/../../outside.txt"); err == nil {
+				t.Error("expected opening a key leaving the storage to be refused")
+			}
+			if err := backend.Delete("dmr/file.txt"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := backend.Open("dmr/file.txt"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("expected the deleted file to be gone, got %v", err)
+			}
+			if err := backend.Delete("dmr/file.txt"); err != nil {
+				t.Errorf("expected deleting a missing file to succeed, got %v", err)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/gorilla/mux"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
 func ToInteger(val string, defaultValue int) int {
@@ -22,3 +23,21 @@ func GetIntOption(r *http.Request, name string, defaultValue int) int {
 	}
 	return ToInteger(value, defaultValue)
 }
+
+func GetQueryIntOption(r *http.Request, name string, defaultValue int) int {
+	value := r.URL.Query().Get(name)
+	if len(value) == 0 {
+		return defaultValue
+	}
+	return ToInteger(value, defaultValue)
+}
+
+func GetQueryBoolOption(r *http.Request, name string, defaultValue bool) bool {
+	switch strings.ToLower(r.URL.Query().Get(name)) {
+	case "true", "1", "yes", "y":
+		return true
+	case "false", "0", "no", "n":
+		return false
+	}
+	return defaultValue
+}
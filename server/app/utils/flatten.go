@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Flatten - flattens nested maps into a single level of string values, nested keys are joined by the separator
+func Flatten(data map[string]interface{}, separator string) map[string]string {
+	output := map[string]string{}
+	flattenInto(output, "", data, separator)
+	return output
+}
+
+func flattenInto(output map[string]string, prefix string, data map[string]interface{}, separator string) {
+	for key, value := range data {
+		if len(prefix) > 0 {
+			key = prefix + separator + key
+		}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			flattenInto(output, key, v, separator)
+		case nil:
+			output[key] = ""
+		case string:
+			output[key] = v
+		case []interface{}:
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				output[key] = fmt.Sprint(v)
+				continue
+			}
+			output[key] = string(encoded)
+		default:
+			output[key] = fmt.Sprint(v)
+		}
+	}
+}
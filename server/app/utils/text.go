@@ -0,0 +1,10 @@
+package utils
+
+// TruncateText - the text cut to at most the given number of characters, a character is never split
+func TruncateText(value string, max int) string {
+	runes := []rune(value)
+	if len(runes) <= max {
+		return value
+	}
+	return string(runes[:max])
+}
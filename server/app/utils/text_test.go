@@ -0,0 +1,23 @@
+package utils
+
+import "testing"
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		value    string
+		max      int
+		expected string
+	}{
+		{"", 3, ""},
+		{"abc", 3, "abc"},
+		{"abcd", 3, "abc"},
+		{"blå bil", 3, "blå"},
+		{"æøå", 2, "æø"},
+		{"Søndergård", 20, "Søndergård"},
+	}
+	for _, test := range tests {
+		if output := TruncateText(test.value, test.max); output != test.expected {
+			t.Errorf("expected %q for %q, got %q", test.expected, test.value, output)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go-scrape-this/server/app/database/models"
+	"go-scrape-this/server/app/scrape"
+	"go-scrape-this/server/app/utils"
+	"gorm.io/gorm"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+)
+
+type watchlistRequest struct {
+	Name     string   `json:"name"`
+	Interval int      `json:"interval"`
+	Fields   []string `json:"fields"`
+}
+
+type watchlistVehicleRequest struct {
+	SearchType string `json:"search_type"`
+	Value      string `json:"value"`
+}
+
+func (a *Application) watchlistListAction(w http.ResponseWriter, r *http.Request) {
+	limit := utils.GetQueryIntOption(r, "limit", 10)
+	offset := utils.GetQueryIntOption(r, "offset", 0)
+	if limit > 100 {
+		limit = 100
+	}
+	db := a.Database().Connection()
+	var watchlists []models.Watchlist
+	var count int64
+	db.Model(models.Watchlist{}).Count(&count)
+	db.Order("created_at").Limit(limit).Offset(offset).Find(&watchlists)
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"data":   watchlists,
+		"total":  count,
+		"count":  len(watchlists),
+		"offset": offset,
+		"limit":  limit,
+	})
+}
+
+func (a *Application) watchlistCreateAction(w http.ResponseWriter, r *http.Request) {
+	var request watchlistRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if len(request.Name) == 0 || len(request.Name) > 255 {
+		errorResponse(w, http.StatusUnprocessableEntity, "name must be between 1 and 255 characters")
+		return
+	}
+	if request.Interval == 0 {
+		request.Interval = 86400
+	}
+	if request.Interval < a.minWatchlistInterval {
+		errorResponse(w, http.StatusUnprocessableEntity, "interval must be at least "+strconv.Itoa(a.minWatchlistInterval)+" seconds")
+		return
+	}
+	for _, field := range request.Fields {
+		if _, err := path.Match(field, ""); err != nil {
+			errorResponse(w, http.StatusUnprocessableEntity, "invalid field pattern: \""+field+"\"")
+			return
+		}
+	}
+	watchlist := models.NewWatchlist(request.Name, request.Interval, request.Fields)
+	result := a.Database().Connection().Create(&watchlist)
+	if result.Error != nil {
+		panic(result.Error)
+	}
+	jsonResponse(w, http.StatusCreated, watchlist)
+}
+
+func (a *Application) watchlistAction(w http.ResponseWriter, r *http.Request) {
+	watchlist, found := a.findWatchlist(w, r)
+	if !found {
+		return
+	}
+	var vehicles []models.WatchlistVehicle
+	result := a.Database().Connection().Where("watchlist_id = ?", watchlist.ID.String()).Order("id").Find(&vehicles)
+	if result.Error != nil {
+		panic(result.Error)
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"watchlist": watchlist,
+		"vehicles":  vehicles,
+	})
+}
+
+func (a *Application) watchlistDeleteAction(w http.ResponseWriter, r *http.Request) {
+	watchlist, found := a.findWatchlist(w, r)
+	if !found {
+		return
+	}
+	err := a.Database().Connection().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("watchlist_id = ?", watchlist.ID.String()).Delete(&models.WatchlistVehicle{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&watchlist).Error
+	})
+	if err != nil {
+		panic(err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *Application) watchlistVehicleAddAction(w http.ResponseWriter, r *http.Request) {
+	watchlist, found := a.findWatchlist(w, r)
+	if !found {
+		return
+	}
+	var request watchlistVehicleRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	query, err := scrape.NewVehicleQuery(request.SearchType, request.Value, nil)
+	if err != nil {
+		errorResponse(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	vehicle := models.WatchlistVehicle{
+		WatchlistID: watchlist.ID,
+		SearchType:  query.SearchType.String(),
+		Value:       query.Value,
+		NextCheckAt: time.Now(),
+	}
+	db := a.Database().Connection()
+	var existing int64
+	db.Model(&models.WatchlistVehicle{}).
+		Where(models.WatchlistVehicle{WatchlistID: vehicle.WatchlistID, SearchType: vehicle.SearchType, Value: vehicle.Value}).
+		Count(&existing)
+	if existing > 0 {
+		errorResponse(w, http.StatusConflict, "vehicle is already on the watchlist")
+		return
+	}
+	result := db.Create(&vehicle)
+	if result.Error != nil {
+		panic(result.Error)
+	}
+	jsonResponse(w, http.StatusCreated, vehicle)
+}
+
+func (a *Application) watchlistVehicleDeleteAction(w http.ResponseWriter, r *http.Request) {
+	watchlist, found := a.findWatchlist(w, r)
+	if !found {
+		return
+	}
+	result := a.Database().Connection().
+		Where("watchlist_id = ? AND id = ?", watchlist.ID.String(), mux.Vars(r)["vehicle"]).
+		Delete(&models.WatchlistVehicle{})
+	if result.Error != nil {
+		panic(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		errorResponse(w, http.StatusNotFound, "vehicle not found on watchlist")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *Application) watchlistChangesAction(w http.ResponseWriter, r *http.Request) {
+	watchlist, found := a.findWatchlist(w, r)
+	if !found {
+		return
+	}
+	limit := utils.GetQueryIntOption(r, "limit", 50)
+	offset := utils.GetQueryIntOption(r, "offset", 0)
+	if limit > 500 {
+		limit = 500
+	}
+	query := a.Database().Connection().Model(&models.VehicleChange{}).Where("watchlist_id = ?", watchlist.ID.String())
+	if vehicle, found := mux.Vars(r)["vehicle"]; found {
+		query = query.Where("watchlist_vehicle_id = ?", utils.ToInteger(vehicle, 0))
+	}
+	var changes []models.VehicleChange
+	var count int64
+	query.Count(&count)
+	result := query.Order("detected_at DESC").Order("id").Limit(limit).Offset(offset).Find(&changes)
+	if result.Error != nil {
+		panic(result.Error)
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"data":   changes,
+		"total":  count,
+		"count":  len(changes),
+		"offset": offset,
+		"limit":  limit,
+	})
+}
+
+func (a *Application) findWatchlist(w http.ResponseWriter, r *http.Request) (models.Watchlist, bool) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "invalid watchlist id")
+		return models.Watchlist{}, false
+	}
+	var watchlist models.Watchlist
+	result := a.Database().Connection().First(&watchlist, "id = ?", id.String())
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		errorResponse(w, http.StatusNotFound, "watchlist not found")
+		return models.Watchlist{}, false
+	}
+	if result.Error != nil {
+		panic(result.Error)
+	}
+	return watchlist, true
+}
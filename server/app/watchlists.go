@@ -0,0 +1,180 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"go-scrape-this/server/app/database/models"
+	"go-scrape-this/server/app/diff"
+	"go-scrape-this/server/app/scrape"
+	"go-scrape-this/server/app/utils"
+	"go-scrape-this/server/app/webhook"
+	"gorm.io/gorm"
+	"path"
+	"strings"
+	"time"
+)
+
+// checkWatchlists - submits lookups for every watched vehicle that is due for a check
+func (a *Application) checkWatchlists() {
+	db := a.Database().Connection()
+	var vehicles []models.WatchlistVehicle
+	result := db.
+		Where("next_check_at <= ? AND pending_job_id IS NULL", time.Now()).
+		Order("next_check_at").
+		Limit(a.maxWatchlistChecks).
+		Find(&vehicles)
+	if result.Error != nil {
+		a.DefaultLogger().Error().Err(result.Error).Msg("failed to find watched vehicles to check")
+		return
+	}
+	for _, vehicle := range vehicles {
+		var watchlist models.Watchlist
+		result = db.First(&watchlist, "id = ?", vehicle.WatchlistID.String())
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			db.Delete(&vehicle)
+			continue
+		}
+		if result.Error != nil {
+			a.DefaultLogger().Error().Err(result.Error).Msg("failed to find watchlist")
+			continue
+		}
+		query, err := scrape.NewVehicleQuery(vehicle.SearchType, vehicle.Value, nil)
+		if err != nil {
+			a.DefaultLogger().Error().Err(err).Uint("vehicle", vehicle.ID).Msg("invalid watched vehicle")
+			continue
+		}
+		// the pending job is stored before the lookup is queued, a lookup finishing right away would otherwise not
+		// find the vehicle it was made for
+		_, err = a.submitVehicleLookup(query, func(tx *gorm.DB, job models.Job) error {
+			vehicle.PendingJobID = &job.ID
+			vehicle.NextCheckAt = time.Now().Add(time.Second * time.Duration(watchlist.Interval))
+			return tx.Save(&vehicle).Error
+		})
+		if err != nil {
+			a.DefaultLogger().Error().Err(err).Uint("vehicle", vehicle.ID).Msg("failed to submit watched vehicle lookup")
+		}
+	}
+}
+
+// detectWatchlistChanges - job listener that diffs finished lookups of watched vehicles against their previous snapshot
+func (a *Application) detectWatchlistChanges(job models.Job) {
+	if job.Type != vehicleLookupJobType {
+		return
+	}
+	db := a.Database().Connection()
+	var vehicles []models.WatchlistVehicle
+	result := db.Where("pending_job_id = ?", job.ID.String()).Find(&vehicles)
+	if result.Error != nil {
+		a.DefaultLogger().Error().Err(result.Error).Msg("failed to find watched vehicles for job")
+		return
+	}
+	for _, vehicle := range vehicles {
+		err := a.recordWatchlistSnapshot(vehicle, job)
+		if err != nil {
+			a.DefaultLogger().Error().Err(err).Uint("vehicle", vehicle.ID).Msg("failed to record watched vehicle snapshot")
+		}
+	}
+}
+
+func (a *Application) recordWatchlistSnapshot(vehicle models.WatchlistVehicle, job models.Job) error {
+	db := a.Database().Connection()
+	vehicle.PendingJobID = nil
+	if job.Status != models.JobSucceeded {
+		vehicle.LastError = job.Error
+		return db.Save(&vehicle).Error
+	}
+
+	var watchlist models.Watchlist
+	result := db.Unscoped().First(&watchlist, "id = ?", vehicle.WatchlistID.String())
+	if result.Error != nil {
+		return result.Error
+	}
+
+	changes := []models.VehicleChange{}
+	if vehicle.LastJobID != nil {
+		previous, err := a.jobs.Get(*vehicle.LastJobID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil {
+			changes = watchedChanges(vehicle, watchlist.Fields, previous, job)
+		}
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if len(changes) > 0 {
+			if err := tx.Create(&changes).Error; err != nil {
+				return err
+			}
+		}
+		vehicle.LastJobID = &job.ID
+		vehicle.LastError = ""
+		vehicle.LastCheckedAt = job.FinishedAt
+		return tx.Save(&vehicle).Error
+	})
+	if err != nil {
+		return err
+	}
+	if len(changes) > 0 {
+		a.webhooks.Publish(webhook.NewEvent(webhook.VEHICLE_CHANGED, map[string]interface{}{
+			"watchlist_id": watchlist.ID,
+			"vehicle":      vehicle,
+			"changes":      changes,
+		}))
+	}
+	return nil
+}
+
+// watchedChanges - compares the flattened results of two lookups and keeps the changes of watched fields
+func watchedChanges(vehicle models.WatchlistVehicle, fields []string, previous models.Job, current models.Job) []models.VehicleChange {
+	detectedAt := time.Now()
+	if current.FinishedAt != nil {
+		detectedAt = *current.FinishedAt
+	}
+	changes := diff.Compare(
+		flattenedSnapshot(previous.Result),
+		flattenedSnapshot(current.Result),
+		func(p []string) bool {
+			return !isWatchedField(fields, strings.Join(p, "."))
+		},
+	)
+	output := []models.VehicleChange{}
+	for _, change := range changes {
+		output = append(output, models.VehicleChange{
+			WatchlistID:        vehicle.WatchlistID,
+			WatchlistVehicleID: vehicle.ID,
+			Field:              change.Field(),
+			Op:                 change.Op,
+			OldValue:           snapshotValue(change.Old),
+			NewValue:           snapshotValue(change.New),
+			PreviousJobID:      previous.ID,
+			JobID:              current.ID,
+			DetectedAt:         detectedAt,
+		})
+	}
+	return output
+}
+
+func flattenedSnapshot(result map[string]interface{}) map[string]interface{} {
+	output := map[string]interface{}{}
+	for key, value := range utils.Flatten(withoutImages(result), ".") {
+		output[key] = value
+	}
+	return output
+}
+
+func isWatchedField(fields []string, field string) bool {
+	for _, pattern := range fields {
+		if matched, err := path.Match(pattern, field); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+func snapshotValue(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return utils.TruncateText(fmt.Sprint(value), 1024)
+}
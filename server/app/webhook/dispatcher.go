@@ -0,0 +1,231 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/rs/zerolog"
+	"go-scrape-this/server/app/database"
+	"go-scrape-this/server/app/database/models"
+	"go-scrape-this/server/app/utils"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const maxErrorLength = 1024
+
+// maxPublishing - the events waiting to be published, events published while it is reached are dropped
+const maxPublishing = 1000
+
+type delivery struct {
+	subscription models.WebhookSubscription
+	event        Event
+	body         []byte
+	attempt      int
+}
+
+// Dispatcher - delivers events to the subscriptions that accept them, failed deliveries are retried with
+// an exponential backoff and every attempt is logged in the database
+type Dispatcher struct {
+	db             *database.Database
+	client         *http.Client
+	logger         *zerolog.Logger
+	workers        int
+	maxAttempts    int
+	initialBackoff time.Duration
+	allowPrivate   bool
+	events         chan Event
+	deliveries     chan delivery
+	quit           chan bool
+	stopped        *sync.WaitGroup
+}
+
+// NewDispatcher - creates a new webhook dispatcher, every delivery attempt is bounded by the timeout. Subscriptions
+// may only target public addresses unless private targets are allowed.
+func NewDispatcher(db *database.Database, workers int, maxAttempts int, initialBackoff time.Duration, timeout time.Duration, allowPrivate bool, logger *zerolog.Logger) *Dispatcher {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !allowPrivate {
+		dialer := &net.Dialer{Timeout: timeout, Control: publicOnly}
+		transport.DialContext = dialer.DialContext
+		// a proxy would make the connections, hiding the addresses of the targets from the dialer
+		transport.Proxy = nil
+	}
+	return &Dispatcher{
+		db: db,
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+		},
+		logger:         logger,
+		workers:        workers,
+		maxAttempts:    maxAttempts,
+		initialBackoff: initialBackoff,
+		allowPrivate:   allowPrivate,
+		events:         make(chan Event, maxPublishing),
+		deliveries:     make(chan delivery, 1000),
+		quit:           make(chan bool),
+		stopped:        &sync.WaitGroup{},
+	}
+}
+
+// ValidateURL - checks that the url may be the target of a subscription
+func (d *Dispatcher) ValidateURL(raw string) error {
+	return ValidateURL(raw, d.allowPrivate)
+}
+
+// Start - starts the publishing and delivery routines
+func (d *Dispatcher) Start() {
+	d.stopped.Add(1)
+	go func() {
+		defer d.stopped.Done()
+		for {
+			select {
+			case event := <-d.events:
+				d.publish(event)
+			case <-d.quit:
+				return
+			}
+		}
+	}()
+	for i := 0; i < d.workers; i++ {
+		d.stopped.Add(1)
+		go func() {
+			defer d.stopped.Done()
+			for {
+				select {
+				case next := <-d.deliveries:
+					d.deliver(next)
+				case <-d.quit:
+					return
+				}
+			}
+		}()
+	}
+}
+
+// Stop - stops the delivery routines, retries that have not been attempted yet are dropped
+func (d *Dispatcher) Stop() {
+	close(d.quit)
+	d.stopped.Wait()
+}
+
+// Publish - queues the event for delivery to every subscription accepting it, without waiting for the
+// subscriptions to be found, so callers like job workers are never held up by the deliveries
+func (d *Dispatcher) Publish(event Event) {
+	select {
+	case d.events <- event:
+	default:
+		d.logger.Warn().Str("event", event.Type).Msg("dropped webhook event, too many events are waiting to be published")
+	}
+}
+
+func (d *Dispatcher) publish(event Event) {
+	var subscriptions []models.WebhookSubscription
+	result := d.db.Connection().Find(&subscriptions)
+	if result.Error != nil {
+		d.logger.Error().Err(result.Error).Str("event", event.Type).Msg("failed to find webhook subscriptions")
+		return
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		d.logger.Error().Err(err).Str("event", event.Type).Msg("failed to encode webhook event")
+		return
+	}
+	for _, subscription := range subscriptions {
+		if subscription.Accepts(event.Type) {
+			d.enqueue(delivery{
+				subscription: subscription,
+				event:        event,
+				body:         body,
+				attempt:      1,
+			})
+		}
+	}
+}
+
+// Send - delivers the event to the subscription once and returns the logged attempt
+func (d *Dispatcher) Send(subscription models.WebhookSubscription, event Event) (models.WebhookDelivery, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	return d.attempt(delivery{
+		subscription: subscription,
+		event:        event,
+		body:         body,
+		attempt:      1,
+	})
+}
+
+func (d *Dispatcher) enqueue(next delivery) {
+	select {
+	case d.deliveries <- next:
+	case <-d.quit:
+		d.logger.Warn().Str("event", next.event.Type).Msg("dropped webhook delivery during shutdown")
+	}
+}
+
+func (d *Dispatcher) deliver(next delivery) {
+	logged, err := d.attempt(next)
+	if err != nil {
+		d.logger.Error().Err(err).Str("event", next.event.Type).Msg("failed to log webhook delivery")
+	}
+	if logged.Succeeded || next.attempt >= d.maxAttempts {
+		return
+	}
+	backoff := d.initialBackoff * time.Duration(1<<(next.attempt-1))
+	next.attempt++
+	time.AfterFunc(backoff, func() {
+		d.enqueue(next)
+	})
+}
+
+func (d *Dispatcher) attempt(next delivery) (models.WebhookDelivery, error) {
+	logged := models.WebhookDelivery{
+		SubscriptionID: next.subscription.ID,
+		EventID:        next.event.ID,
+		Event:          next.event.Type,
+		Attempt:        next.attempt,
+	}
+	startTime := time.Now()
+	statusCode, err := d.post(next)
+	logged.Duration = time.Since(startTime).Milliseconds()
+	logged.StatusCode = statusCode
+	if err != nil {
+		logged.Error = utils.TruncateText(err.Error(), maxErrorLength)
+	}
+	logged.Succeeded = err == nil && statusCode >= 200 && statusCode < 300
+	d.logger.Info().
+		Str("event", next.event.Type).
+		Str("subscription", next.subscription.ID.String()).
+		Int("attempt", next.attempt).
+		Int("code", statusCode).
+		Bool("succeeded", logged.Succeeded).
+		Msg("webhook delivered")
+	return logged, d.db.Connection().Create(&logged).Error
+}
+
+func (d *Dispatcher) post(next delivery) (int, error) {
+	request, err := http.NewRequest(http.MethodPost, next.subscription.URL, bytes.NewReader(next.body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "go-scrape-this-webhook")
+	request.Header.Set("X-Webhook-Id", next.event.ID.String())
+	request.Header.Set("X-Webhook-Event", next.event.Type)
+	request.Header.Set("X-Webhook-Attempt", strconv.Itoa(next.attempt))
+	request.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	request.Header.Set("X-Webhook-Signature", Sign(next.subscription.Secret, timestamp, next.body))
+	response, err := d.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 64*1024))
+	return response.StatusCode, nil
+}
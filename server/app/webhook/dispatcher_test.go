@@ -0,0 +1,220 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"errors"
+	"github.com/rs/zerolog"
+	"go-scrape-this/server/app/database"
+	"go-scrape-this/server/app/database/models"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testDatabase(t *testing.T) *database.Database {
+	logger := zerolog.Nop()
+	db, err := database.NewDatabase(database.SQLITE, filepath.Join(t.TempDir(), "test.db"), &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RunMigrations(); err != nil {
+		t.Fatal(err)
+	}
+	return &db
+}
+
+// receiver - a webhook endpoint answering with the given status codes in turn, the last one is repeated
+type receiver struct {
+	t        *testing.T
+	secret   string
+	statuses []int
+	lock     sync.Mutex
+	attempts []string
+	received chan struct{}
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, request *http.Request) {
+	body, _ := io.ReadAll(request.Body)
+	timestamp, err := strconv.ParseInt(request.Header.Get("X-Webhook-Timestamp"), 10, 64)
+	if err != nil {
+		r.t.Errorf("invalid timestamp: %v", err)
+	}
+	signature := request.Header.Get("X-Webhook-Signature")
+	if !hmac.Equal([]byte(signature), []byte(Sign(r.secret, timestamp, body))) {
+		r.t.Errorf("invalid signature %q", signature)
+	}
+	r.lock.Lock()
+	r.attempts = append(r.attempts, request.Header.Get("X-Webhook-Attempt"))
+	status := r.statuses[len(r.statuses)-1]
+	if len(r.attempts) <= len(r.statuses) {
+		status = r.statuses[len(r.attempts)-1]
+	}
+	r.lock.Unlock()
+	w.WriteHeader(status)
+	r.received <- struct{}{}
+}
+
+func (r *receiver) wait(t *testing.T, count int) {
+	for i := 0; i < count; i++ {
+		select {
+		case <-r.received:
+		case <-time.After(time.Second * 5):
+			t.Fatalf("received %d of %d deliveries", i, count)
+		}
+	}
+}
+
+func subscribe(t *testing.T, db *database.Database, url string, secret string) models.WebhookSubscription {
+	subscription := models.NewWebhookSubscription(url, []string{JOB_SUCCEEDED}, secret)
+	if err := db.Connection().Create(&subscription).Error; err != nil {
+		t.Fatal(err)
+	}
+	return subscription
+}
+
+// deliveries - waits for the given number of logged attempts of the subscription
+func deliveries(t *testing.T, db *database.Database, subscription models.WebhookSubscription, count int) []models.WebhookDelivery {
+	deadline := time.Now().Add(time.Second * 5)
+	for {
+		var logged []models.WebhookDelivery
+		err := db.Connection().Where("subscription_id = ?", subscription.ID.String()).Order("attempt").Find(&logged).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(logged) >= count || time.Now().After(deadline) {
+			return logged
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+func TestSign(t *testing.T) {
+	signature := Sign("secret", 1700000000, []byte(`{"id":1}`))
+	expected := "sha256=3dd1b9aef568d75f6790a84bd2e5dfa1f44409eef3cbdbd3f10b837376100c11"
+	if signature != expected {
+		t.Fatalf("expected %s, got %s", expected, signature)
+	}
+	if Sign("other", 1700000000, []byte(`{"id":1}`)) == expected {
+		t.Fatal("signature does not depend on the secret")
+	}
+	if Sign("secret", 1700000001, []byte(`{"id":1}`)) == expected {
+		t.Fatal("signature does not depend on the timestamp")
+	}
+}
+
+func TestPublishRetriesUntilDelivered(t *testing.T) {
+	db := testDatabase(t)
+	endpoint := &receiver{t: t, secret: "s3cret", statuses: []int{500, 502, 204}, received: make(chan struct{}, 10)}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+	subscription := subscribe(t, db, server.URL, "s3cret")
+
+	logger := zerolog.Nop()
+	dispatcher := NewDispatcher(db, 1, 5, time.Millisecond*10, time.Second, true, &logger)
+	dispatcher.Start()
+	defer dispatcher.Stop()
+	dispatcher.Publish(NewEvent(JOB_SUCCEEDED, map[string]interface{}{"id": 1}))
+	// events of other types are not delivered to the subscription
+	dispatcher.Publish(NewEvent(JOB_FAILED, map[string]interface{}{"id": 2}))
+	endpoint.wait(t, 3)
+
+	logged := deliveries(t, db, subscription, 3)
+	if len(logged) != 3 {
+		t.Fatalf("expected 3 logged attempts, got %d", len(logged))
+	}
+	for i, attempt := range logged {
+		if attempt.Attempt != i+1 || attempt.Event != JOB_SUCCEEDED {
+			t.Errorf("unexpected attempt %d: %+v", i+1, attempt)
+		}
+		if attempt.Succeeded != (i == 2) {
+			t.Errorf("attempt %d succeeded: %v", i+1, attempt.Succeeded)
+		}
+	}
+	if endpoint.attempts[0] != "1" || endpoint.attempts[2] != "3" {
+		t.Errorf("unexpected attempt headers %v", endpoint.attempts)
+	}
+}
+
+func TestPublishGivesUpAfterMaxAttempts(t *testing.T) {
+	db := testDatabase(t)
+	endpoint := &receiver{t: t, secret: "s3cret", statuses: []int{500}, received: make(chan struct{}, 10)}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+	subscription := subscribe(t, db, server.URL, "s3cret")
+
+	logger := zerolog.Nop()
+	dispatcher := NewDispatcher(db, 2, 2, time.Millisecond*10, time.Second, true, &logger)
+	dispatcher.Start()
+	defer dispatcher.Stop()
+	dispatcher.Publish(NewEvent(JOB_SUCCEEDED, nil))
+	endpoint.wait(t, 2)
+
+	select {
+	case <-endpoint.received:
+		t.Fatal("delivered more often than the max attempts")
+	case <-time.After(time.Millisecond * 100):
+	}
+	logged := deliveries(t, db, subscription, 2)
+	if len(logged) != 2 || logged[0].Succeeded || logged[1].Succeeded || logged[1].StatusCode != 500 {
+		t.Fatalf("unexpected logged attempts %+v", logged)
+	}
+}
+
+func TestPrivateTargetsAreRefused(t *testing.T) {
+	db := testDatabase(t)
+	endpoint := &receiver{t: t, statuses: []int{204}, received: make(chan struct{}, 10)}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	logger := zerolog.Nop()
+	dispatcher := NewDispatcher(db, 1, 1, time.Millisecond, time.Second, false, &logger)
+	if err := dispatcher.ValidateURL(server.URL); !errors.Is(err, ErrPrivateTarget) {
+		t.Fatalf("expected the loopback url to be refused, got %v", err)
+	}
+	// a subscription stored before its host resolved to a private address is refused when dialing
+	logged, err := dispatcher.Send(subscribe(t, db, server.URL, ""), NewEvent(WEBHOOK_TEST, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logged.Succeeded || len(logged.Error) == 0 {
+		t.Fatalf("expected the delivery to fail, got %+v", logged)
+	}
+	if len(endpoint.received) > 0 {
+		t.Fatal("the private target was reached")
+	}
+	if err := ValidateURL("ftp://example.com/hook", true); err == nil {
+		t.Fatal("expected a non http url to be refused")
+	}
+}
+
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		ip     string
+		public bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false},
+	}
+	for _, test := range tests {
+		if IsPublicIP(net.ParseIP(test.ip)) != test.public {
+			t.Errorf("%s: expected public %v", test.ip, test.public)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/google/uuid"
+	"strconv"
+	"time"
+)
+
+const (
+	JOB_SUCCEEDED    = "job.succeeded"
+	JOB_FAILED       = "job.failed"
+	VEHICLE_CHANGED  = "vehicle.changed"
+	SOURCE_DEGRADED  = "source.degraded"
+	SOURCE_RECOVERED = "source.recovered"
+	WEBHOOK_TEST     = "webhook.test"
+)
+
+// Events - the events a subscription can filter on
+var Events = []string{
+	JOB_SUCCEEDED,
+	JOB_FAILED,
+	VEHICLE_CHANGED,
+	SOURCE_DEGRADED,
+	SOURCE_RECOVERED,
+}
+
+type Event struct {
+	ID        uuid.UUID   `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+func NewEvent(eventType string, data interface{}) Event {
+	return Event{
+		ID:        uuid.New(),
+		Type:      eventType,
+		CreatedAt: time.Now(),
+		Data:      data,
+	}
+}
+
+// Sign - signs the timestamp and body with HMAC-SHA256, receivers verify the signature by computing
+// the HMAC of "<timestamp>.<body>" with their copy of the secret
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,70 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// resolveTimeout - how long resolving the host of a subscription url is waited for
+const resolveTimeout = time.Second * 5
+
+// ErrPrivateTarget - the url targets an address that is not reachable from the public internet
+var ErrPrivateTarget = errors.New("url must not target a private, loopback or link-local address")
+
+// sharedAddressSpace - the carrier-grade NAT range, which net.IP does not count as private
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// IsPublicIP - if the ip is routable on the public internet
+func IsPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	if ip4 := ip.To4(); ip4 != nil && (ip4[0] == 0 || sharedAddressSpace.Contains(ip4)) {
+		return false
+	}
+	return true
+}
+
+// ValidateURL - checks that the url is an absolute http(s) url, and unless private targets are allowed, that
+// every address its host resolves to is public
+func ValidateURL(raw string, allowPrivate bool) error {
+	target, err := url.Parse(raw)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || len(target.Hostname()) == 0 {
+		return errors.New("url must be an absolute http or https url")
+	}
+	if allowPrivate {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, target.Hostname())
+	if err != nil {
+		return fmt.Errorf("failed to resolve \"%s\": %w", target.Hostname(), err)
+	}
+	for _, address := range addresses {
+		if !IsPublicIP(address.IP) {
+			return ErrPrivateTarget
+		}
+	}
+	return nil
+}
+
+// publicOnly - refuses connections to addresses that are not public, checked when dialing so a host resolving
+// to another address after its subscription was validated is refused as well
+func publicOnly(_ string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !IsPublicIP(ip) {
+		return ErrPrivateTarget
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package app
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go-scrape-this/server/app/database/models"
+	"go-scrape-this/server/app/utils"
+	"go-scrape-this/server/app/webhook"
+	"golang.org/x/exp/slices"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+type webhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+}
+
+// publishJobEvent - job listener that publishes finished jobs to the webhook subscriptions
+func (a *Application) publishJobEvent(job models.Job) {
+	eventType := webhook.JOB_SUCCEEDED
+	if job.Status == models.JobFailed {
+		eventType = webhook.JOB_FAILED
+	}
+	if job.Result != nil {
+		job.Result = withoutImages(job.Result)
+	}
+	a.webhooks.Publish(webhook.NewEvent(eventType, map[string]interface{}{
+		"job": job,
+	}))
+}
+
+func (a *Application) webhookListAction(w http.ResponseWriter, r *http.Request) {
+	limit := utils.GetQueryIntOption(r, "limit", 10)
+	offset := utils.GetQueryIntOption(r, "offset", 0)
+	if limit > 100 {
+		limit = 100
+	}
+	db := a.Database().Connection()
+	var subscriptions []models.WebhookSubscription
+	var count int64
+	db.Model(models.WebhookSubscription{}).Count(&count)
+	db.Order("created_at").Limit(limit).Offset(offset).Find(&subscriptions)
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"data":   subscriptions,
+		"total":  count,
+		"count":  len(subscriptions),
+		"offset": offset,
+		"limit":  limit,
+	})
+}
+
+func (a *Application) webhookCreateAction(w http.ResponseWriter, r *http.Request) {
+	var request webhookRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if len(request.URL) > 2048 {
+		errorResponse(w, http.StatusUnprocessableEntity, "url must be at most 2048 characters")
+		return
+	}
+	err = a.webhooks.ValidateURL(request.URL)
+	if err != nil {
+		errorResponse(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if len(request.Events) == 0 {
+		errorResponse(w, http.StatusUnprocessableEntity, "at least one event is required")
+		return
+	}
+	for _, event := range request.Events {
+		if !slices.Contains(webhook.Events, event) {
+			errorResponse(w, http.StatusUnprocessableEntity, "unknown event: \""+event+"\"")
+			return
+		}
+	}
+	if len(request.Secret) == 0 {
+		secret := make([]byte, 32)
+		_, err = rand.Read(secret)
+		if err != nil {
+			panic(err)
+		}
+		request.Secret = hex.EncodeToString(secret)
+	}
+	if len(request.Secret) > 255 {
+		errorResponse(w, http.StatusUnprocessableEntity, "secret must be at most 255 characters")
+		return
+	}
+	subscription := models.NewWebhookSubscription(request.URL, request.Events, request.Secret)
+	result := a.Database().Connection().Create(&subscription)
+	if result.Error != nil {
+		panic(result.Error)
+	}
+	// the secret is only ever returned when the subscription is created
+	jsonResponse(w, http.StatusCreated, map[string]interface{}{
+		"subscription": subscription,
+		"secret":       subscription.Secret,
+	})
+}
+
+func (a *Application) webhookAction(w http.ResponseWriter, r *http.Request) {
+	subscription, found := a.findWebhook(w, r)
+	if !found {
+		return
+	}
+	jsonResponse(w, http.StatusOK, subscription)
+}
+
+func (a *Application) webhookDeleteAction(w http.ResponseWriter, r *http.Request) {
+	subscription, found := a.findWebhook(w, r)
+	if !found {
+		return
+	}
+	result := a.Database().Connection().Delete(&subscription)
+	if result.Error != nil {
+		panic(result.Error)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *Application) webhookDeliveriesAction(w http.ResponseWriter, r *http.Request) {
+	subscription, found := a.findWebhook(w, r)
+	if !found {
+		return
+	}
+	limit := utils.GetQueryIntOption(r, "limit", 50)
+	offset := utils.GetQueryIntOption(r, "offset", 0)
+	if limit > 500 {
+		limit = 500
+	}
+	query := a.Database().Connection().Model(&models.WebhookDelivery{}).Where("subscription_id = ?", subscription.ID.String())
+	var deliveries []models.WebhookDelivery
+	var count int64
+	query.Count(&count)
+	result := query.Order("created_at DESC").Order("id DESC").Limit(limit).Offset(offset).Find(&deliveries)
+	if result.Error != nil {
+		panic(result.Error)
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"data":   deliveries,
+		"total":  count,
+		"count":  len(deliveries),
+		"offset": offset,
+		"limit":  limit,
+	})
+}
+
+func (a *Application) webhookTestAction(w http.ResponseWriter, r *http.Request) {
+	subscription, found := a.findWebhook(w, r)
+	if !found {
+		return
+	}
+	delivery, err := a.webhooks.Send(subscription, webhook.NewEvent(webhook.WEBHOOK_TEST, map[string]interface{}{
+		"subscription_id": subscription.ID,
+		"message":         "test event",
+	}))
+	if err != nil {
+		panic(err)
+	}
+	jsonResponse(w, http.StatusOK, delivery)
+}
+
+func (a *Application) findWebhook(w http.ResponseWriter, r *http.Request) (models.WebhookSubscription, bool) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "invalid webhook id")
+		return models.WebhookSubscription{}, false
+	}
+	var subscription models.WebhookSubscription
+	result := a.Database().Connection().First(&subscription, "id = ?", id.String())
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		errorResponse(w, http.StatusNotFound, "webhook not found")
+		return models.WebhookSubscription{}, false
+	}
+	if result.Error != nil {
+		panic(result.Error)
+	}
+	return subscription, true
+}
@@ -16,6 +16,10 @@ var version = "0.0.0"
 var content embed.FS
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		os.Exit(app.ReplayCommand(os.Args[2:]))
+	}
+
 	subFs, err := fs.Sub(content, "dist")
 	if err != nil {
 		panic(err)